@@ -2,10 +2,19 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
 
 	"github.com/bakape/hydron/common"
 	"github.com/bakape/hydron/db"
 	"github.com/bakape/hydron/files"
+	imp "github.com/bakape/hydron/import"
+	"github.com/bakape/hydron/metadata"
+	"github.com/bakape/hydron/sidecar"
 	"github.com/bakape/hydron/tags"
 )
 
@@ -20,22 +29,59 @@ func removeFiles(ids []string) (err error) {
 	return
 }
 
+// Run fn with a new tag change batch originating from the CLI
+func inCLIBatch(fn func(b db.Batch) error) error {
+	b, err := db.NewBatch(db.ActorCLI)
+	if err != nil {
+		return err
+	}
+	return fn(b)
+}
+
 // Add tags to the target file from the CLI
-func addTags(sha1 string, tagStr string) error {
+func addTags(b db.Batch, sha1 string, tagStr string) error {
 	id, err := db.GetImageID(sha1)
 	if err != nil {
 		return err
 	}
-	return db.AddTags(id, tags.FromString(tagStr, common.User))
+	return db.AddTags(b, id, tags.FromString(tagStr, common.User))
 }
 
 // Remove tags from the target file from the CLI
-func removeTags(sha1 string, tagStr string) error {
+func removeTags(b db.Batch, sha1 string, tagStr string) error {
 	id, err := db.GetImageID(sha1)
 	if err != nil {
 		return err
 	}
-	return db.RemoveTags(id, tags.FromString(tagStr, common.User))
+	return db.RemoveTags(b, id, tags.FromString(tagStr, common.User))
+}
+
+/*
+Revert tag changes from the CLI
+args: optional number of last tag changes to revert
+batch: ID of batch to revert. Takes precedence over args.
+If neither is set, the last batch is reverted.
+*/
+func undoTagChanges(args []string, batch int64) (err error) {
+	var n int
+	switch {
+	case batch != 0:
+		n, err = db.UndoBatch(batch)
+	case len(args) != 0:
+		var last uint64
+		last, err = strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			return
+		}
+		n, err = db.UndoLast(last)
+	default:
+		n, err = db.UndoLastBatch()
+	}
+	if err != nil {
+		return
+	}
+	fmt.Printf("reverted %d tag changes\n", n)
+	return
 }
 
 // Set the target file's name from the CLI
@@ -56,8 +102,186 @@ func searchImages(params string) (err error) {
 	if err != nil {
 		return
 	}
-	return db.SearchImages(&page, false, func(i common.CompactImage) error {
+	found := false
+	err = db.SearchImages(&page, false, func(i common.CompactImage) error {
+		found = true
 		fmt.Println(files.SourcePath(i.SHA1, i.Type))
 		return nil
 	})
+	if err != nil || found {
+		return
+	}
+
+	corrected, err := db.SuggestFilterCorrections(page.Filters.Tag)
+	if err != nil || corrected == nil {
+		return
+	}
+	page.Filters.Tag = corrected
+	stderr.Printf("did you mean: %s\n", page.Filters)
+	return
+}
+
+// Copy files that match params into dir and write sidecar files with their
+// metadata next to them.
+// formats: comma-separated list of sidecar formats to write
+func exportImages(dir, formatStr, params string) (err error) {
+	var formats []sidecar.Format
+	for _, s := range strings.Split(formatStr, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		f, ok := sidecar.ParseFormat(s)
+		if !ok {
+			return fmt.Errorf("unknown sidecar format: %s", s)
+		}
+		formats = append(formats, f)
+	}
+
+	var page common.Page
+	err = tags.ParseFilters(params, &page)
+	if err != nil {
+		return
+	}
+	// Collect hashes first, as the database can not be queried, while reading
+	// the search results
+	var hashes []string
+	err = db.SearchImages(&page, false, func(i common.CompactImage) error {
+		hashes = append(hashes, i.SHA1)
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return
+	}
+	for _, sha1 := range hashes {
+		var img common.Image
+		img, err = db.GetImage(sha1)
+		if err != nil {
+			return
+		}
+		dst := filepath.Join(dir, sha1+"."+common.Extensions[img.Type])
+		err = copyFile(files.SourcePath(sha1, img.Type), dst)
+		if err != nil {
+			return
+		}
+		err = sidecar.Write(dst, img, formats)
+		if err != nil {
+			return
+		}
+		fmt.Println(dst)
+	}
+	return
+}
+
+// Copy a file from src to dst
+func copyFile(src, dst string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return
+}
+
+// Read embedded metadata of all stored files again. Used to add metadata to
+// files imported before metadata was read.
+func readAllMetadata() (err error) {
+	images, err := db.GetImagesOfTypes(metadata.FileTypes)
+	if err != nil {
+		return
+	}
+
+	p := progressLogger{
+		header: "reading metadata",
+		total:  len(images),
+	}
+	for _, img := range images {
+		err = func() (err error) {
+			f, err := os.Open(files.SourcePath(img.SHA1, img.Type))
+			if err != nil {
+				return
+			}
+			defer f.Close()
+
+			m, err := metadata.Read(f, img.Type)
+			if err != nil {
+				return
+			}
+			return db.SetMetadata(img.ID, m.TakenUnix(), m.Fields())
+		}()
+		if err != nil {
+			p.Err(err)
+		} else {
+			p.Done()
+		}
+	}
+	p.Close()
+	return nil
+}
+
+// Generate thumbnails of all images matching params again in parallel with the
+// current thumbnail settings
+func regenerateThumbnails(params string) (err error) {
+	var page common.Page
+	err = tags.ParseFilters(params, &page)
+	if err != nil {
+		return
+	}
+	// Collect images first, as the database can not be written to, while
+	// reading the search results
+	var images []common.CompactImage
+	err = db.SearchImages(&page, false, func(i common.CompactImage) error {
+		images = append(images, i)
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	pass := make(chan common.CompactImage, len(images))
+	for _, img := range images {
+		pass <- img
+	}
+	close(pass)
+	type result struct {
+		sha1 string
+		err  error
+	}
+	ch := make(chan result)
+	n := runtime.NumCPU() + 1
+	for i := 0; i < n; i++ {
+		go func() {
+			for img := range pass {
+				ch <- result{img.SHA1, imp.RegenerateThumbnail(img)}
+			}
+		}()
+	}
+
+	p := progressLogger{
+		header: "regenerating thumbnails",
+		total:  len(images),
+	}
+	for range images {
+		res := <-ch
+		if res.err != nil {
+			p.Err(fmt.Errorf("%s: %s", res.sha1, res.err))
+		} else {
+			p.Done()
+		}
+	}
+	p.Close()
+	return nil
 }
@@ -5,7 +5,8 @@ const browser = document.getElementById("browser");
 	const form = document.getElementById("import");
 	
 	document.getElementById("submit").addEventListener("click", async () => {
-        if (!confirm("Generic confirmation message.")) {
+		const dryRun = form.querySelector("#dry-run").checked;
+        if (!dryRun && !confirm("Generic confirmation message.")) {
             return;
 		}
 		input = form.querySelector("#path").value;
@@ -14,20 +15,39 @@ const browser = document.getElementById("browser");
 			return;
 		}
 
-        const body = "path=" + input +
+        const body = "path=" + encodeURIComponent(input) +
             "&del=" + form.querySelector("#delete").checked +
             "&fetchTags=" + form.querySelector("#fetch-tags").checked +
 			"&storeName=" + form.querySelector("#store-name").checked +
-			"&tagStr=" + form.querySelector("#input-tags").value;
+			"&archiveTags=" + form.querySelector("#archive-tags").checked +
+			"&link=" + form.querySelector("#link").checked +
+			"&dryRun=" + dryRun +
+			"&tagStr=" +
+			encodeURIComponent(form.querySelector("#input-tags").value);
 
 		let r = await fetch("/api/import", { body, method: "POST",
 			headers: { "Content-Type": "application/x-www-form-urlencoded" } } );
+		if (dryRun) {
+			if (r.status !== 200) {
+				alert(await r.text());
+				return;
+			}
+			renderDryRun(await r.json());
+			return;
+		}
 		const reader = r.body.getReader();
 		const decoder = new TextDecoder("utf-8");
 
 		// Recursively read from stream and process chunks,
 		// until "done" message
+		let job = 0;
+		const errors = [];
 		await read();
+		if (errors.length) {
+			alert(`${errors.length} files failed to import:\n`
+				+ errors.join("\n")
+				+ `\n\nSee /api/jobs/${job} for details.`);
+		}
 		async function read() {
 			let chunk = await reader.read();
 			if (chunk.done) {
@@ -38,12 +58,90 @@ const browser = document.getElementById("browser");
 			s = decoder.decode(chunk.value).split("-");
 			for (let i = 0; i < s.length - 1; i++) {
 				let obj = JSON.parse(s[i]);
-				await addThumb(obj.SHA1);
+				job = obj.Job;
+				if (obj.Error) {
+					errors.push(obj.Error);
+				} else {
+					await addThumb(obj.SHA1);
+				}
 				renderProgress(obj.Current / obj.Total);
 			}
 			await read();
 		}
     }, { passive: true });
+
+	// Render the report of what an import would do
+	function renderDryRun({ results, counts, new_bytes }) {
+		const summary = [`${results.length} files`, `${new_bytes} new bytes`];
+		for (const status in counts) {
+			summary.push(`${counts[status]} ${status}`);
+		}
+		document.getElementById("dry-run-summary").textContent =
+			summary.join(", ");
+
+		const table = document.getElementById("dry-run-report");
+		table.innerHTML = "";
+		for (const { path, status, sha1, size, tags, error } of results) {
+			const tr = table.insertRow();
+			tr.insertCell().textContent = status;
+			tr.insertCell().textContent = path;
+			tr.insertCell().textContent = error || sha1 || "";
+			tr.insertCell().textContent = size || "";
+			tr.insertCell().textContent = tags || "";
+		}
+	}
+})();
+
+// Path rules
+(() => {
+	const rules = document.getElementById("path-rules");
+	const preview = document.getElementById("rules-preview");
+
+	(async () => {
+		const r = await fetch("/api/path_rules");
+		if (r.status !== 200) {
+			alert(await r.text());
+			return;
+		}
+		rules.value = JSON.stringify(await r.json(), null, "\t");
+	})();
+
+	function formBody() {
+		return "rules=" + encodeURIComponent(rules.value || "[]") +
+			"&path=" + encodeURIComponent(
+				document.getElementById("path").value);
+	}
+
+	async function send(url, method) {
+		const r = await fetch(url, { body: formBody(), method,
+			headers: { "Content-Type": "application/x-www-form-urlencoded" } });
+		if (r.status !== 200) {
+			throw await r.text();
+		}
+		return r;
+	}
+
+	document.getElementById("save-rules").addEventListener("click",
+		() => send("/api/path_rules", "PUT").catch(alert),
+		{ passive: true });
+
+	document.getElementById("preview-rules").addEventListener("click",
+		async () => {
+			let r;
+			try {
+				r = await send("/api/path_rules/preview", "POST");
+			} catch (err) {
+				alert(err);
+				return;
+			}
+			preview.innerHTML = "";
+			for (const { path, tags } of await r.json()) {
+				const tr = preview.insertRow();
+				tr.insertCell().textContent = path;
+				tr.insertCell().textContent = tags;
+			}
+		},
+		{ passive: true });
 })();
 
 // Drag and drop import
@@ -1,7 +1,6 @@
 const browser = document.getElementById("browser");
 const imageView = document.getElementById("image-view");
 const search = document.getElementById("search");
-const figureWidth = 200 + 4; // With margin
 
 // Search bar and suggestions
 (() => {
@@ -46,8 +45,12 @@ const figureWidth = 200 + 4; // With margin
 				text += " ";
 			}
 			let s = "";
-			for (const tag of tags) {
+			for (const { tag, count } of tags) {
 				s += `<option value="${text}${tag}">`;
+				if (count) {
+					s += count;
+				}
+				s += "</option>";
 			}
 			sugg.innerHTML = s;
 		} catch (err) {
@@ -56,6 +59,71 @@ const figureWidth = 200 + 4; // With margin
 	}, { passive: true });
 })();
 
+// Thumbnail zoom
+(() => {
+	const zoom = document.getElementById("zoom");
+	if (!zoom) {
+		return;
+	}
+
+	const stored = parseInt(localStorage.getItem("thumbSize"));
+	if (stored) {
+		zoom.value = stored;
+	}
+	setThumbSize(parseInt(zoom.value));
+
+	zoom.addEventListener("input", () => {
+		const size = parseInt(zoom.value);
+		localStorage.setItem("thumbSize", size);
+		setThumbSize(size);
+	}, { passive: true });
+
+	// Resize figures and let the browser pick the thumbnail size from srcset
+	// matching the displayed width
+	function setThumbSize(size) {
+		browser.style.setProperty("--thumb-size", size + "px");
+		for (const img of browser.querySelectorAll("figure img")) {
+			const w = parseInt(img.getAttribute("width"));
+			const h = parseInt(img.getAttribute("height"));
+			const scale = Math.min(1, size / Math.max(w, h));
+			img.setAttribute("sizes", Math.floor(w * scale) + "px");
+		}
+	}
+})();
+
+// Animated previews of GIFs and videos on hover
+(() => {
+	browser.addEventListener("mouseover", e => {
+		const img = previewImage(e.target, e.relatedTarget);
+		if (img) {
+			img.setAttribute("data-srcset", img.getAttribute("srcset"));
+			img.removeAttribute("srcset");
+			img.setAttribute("data-src", img.getAttribute("src"));
+			img.setAttribute("src", img.getAttribute("data-preview"));
+		}
+	}, { passive: true });
+
+	browser.addEventListener("mouseout", e => {
+		const img = previewImage(e.target, e.relatedTarget);
+		if (img && img.hasAttribute("data-src")) {
+			img.setAttribute("src", img.getAttribute("data-src"));
+			img.setAttribute("srcset", img.getAttribute("data-srcset"));
+			img.removeAttribute("data-src");
+			img.removeAttribute("data-srcset");
+		}
+	}, { passive: true });
+
+	// Returns the thumbnail with a preview of the figure the cursor entered
+	// or left, if any
+	function previewImage(target, related) {
+		const fig = target.closest && target.closest("figure");
+		if (!fig || (related && fig.contains(related))) {
+			return null;
+		}
+		return fig.querySelector("img[data-preview]");
+	}
+})();
+
 // Drag and drop
 (() => {
 	// Prevent defaults
@@ -142,13 +210,30 @@ const figureWidth = 200 + 4; // With margin
 			return;
 		}
 
+		// Group all tag changes into one batch, so they can be reverted
+		// together
+		let body = params[2];
+		if (params[0].startsWith("/tags/")) {
+			try {
+				const r = await fetch("/api/batches", { method: "POST" });
+				if (r.status !== 200) {
+					throw await r.text();
+				}
+				body = "batch=" + await r.json()
+					+ (body.length ? "&" + body : "");
+			} catch (err) {
+				alert(err);
+				return;
+			}
+		}
+
 		let n = checked.length;
 		let i = 0;
 		while (i < n) {
 			try {
 				let path = "/api/images/" + checked[i] + params[0];
 				await fetch( path, { method: params[1],
-					 body: params[2],
+					 body,
 					 headers: {
 						 "Content-Type": "application/x-www-form-urlencoded"
 						} });
@@ -253,12 +338,18 @@ function renderProgress(val) {
 	document.getElementById("progress-bar").style.width = val * 100 + "%";
 }
 
+// Width of a figure including margins
+function figureWidth() {
+	const f = browser.querySelector("figure");
+	return f ? f.offsetWidth + 4 : 204;
+}
+
 function browserWidth() {
-	return Math.floor(browser.offsetWidth / figureWidth);
+	return Math.floor(browser.offsetWidth / figureWidth());
 }
 
 function browserHeight() {
-	return Math.floor(browser.offsetHeight / figureWidth);
+	return Math.floor(browser.offsetHeight / figureWidth());
 }
 
 // Returns browser grid as 2D array and the position of the highlighted figure
@@ -0,0 +1,13 @@
+package common
+
+// Image imported from an archive and its position in the archive
+type ArchiveMember struct {
+	// Path to the archive
+	Archive string `json:"archive"`
+	// Normalized name of the archive without directories and extension, as
+	// used in the "archive:" tag of its members
+	Name     string `json:"name"`
+	Member   string `json:"member"`
+	Position int    `json:"position"`
+	SHA1     string `json:"sha1"`
+}
@@ -20,6 +20,10 @@ const (
 	MOV
 	WMV
 	FLV
+	AVIF
+	HEIC
+	JXL
+	SVG
 )
 
 type MediaType uint8
@@ -50,6 +54,10 @@ var (
 		"video/quicktime":  MOV,
 		"video/x-ms-wmv":   WMV,
 		"video/x-flv":      FLV,
+		"image/avif":       AVIF,
+		"image/heic":       HEIC,
+		"image/jxl":        JXL,
+		"image/svg+xml":    SVG,
 	}
 
 	// MIME types allowed to be imported
@@ -70,6 +78,10 @@ var (
 		"video/quicktime":  true,
 		"video/x-ms-wmv":   true,
 		"video/x-flv":      true,
+		"image/avif":       true,
+		"image/heic":       true,
+		"image/jxl":        true,
+		"image/svg+xml":    true,
 	}
 
 	// Canonical MIME type extensions
@@ -90,6 +102,10 @@ var (
 		MOV:  "mov",
 		WMV:  "wmv",
 		FLV:  "flv",
+		AVIF: "avif",
+		HEIC: "heic",
+		JXL:  "jxl",
+		SVG:  "svg",
 	}
 
 	// Mapping from canonical extensions to internal enum
@@ -110,13 +126,17 @@ var (
 		"mov":  MOV,
 		"wmv":  WMV,
 		"flv":  FLV,
+		"avif": AVIF,
+		"heic": HEIC,
+		"jxl":  JXL,
+		"svg":  SVG,
 	}
 )
 
 // Map file type to media container type
 func GetMediaType(t FileType) MediaType {
 	switch t {
-	case JPEG, PNG, GIF, WEBP, BMP, TIFF:
+	case JPEG, PNG, GIF, WEBP, BMP, TIFF, AVIF, HEIC, JXL, SVG:
 		return MediaImage
 	case WEBM, OGG, MKV, MP4, AVI, MOV, WMV:
 		return MediaVideo
@@ -6,20 +6,80 @@ import (
 	"database/sql/driver"
 	"encoding/hex"
 	"fmt"
+	"strconv"
 )
 
 // Record of an image stored in the database
 type Image struct {
 	CompactImage
 	Dims
-	ID         int64  `json:"-"`
 	ImportTime int64  `json:"import_time"`
 	Size       int    `json:"size"`
 	Duration   uint64 `json:"duration,omitempty"`
 	MD5        string `json:"md5"`
-	Name       string `json:"name"`
+	// Not defined for files imported before SHA256 hashes were stored, until
+	// they are hashed in the background
+	SHA256 string `json:"sha256,omitempty"`
+	Name   string `json:"name"`
+	// Unix time the file was taken according to its embedded metadata or 0,
+	// if unknown
+	Taken int64 `json:"taken,omitempty"`
 	// Not always defined for performance reasons
-	Tags []Tag `json:"tags,omitempty"`
+	Tags     []Tag           `json:"tags,omitempty"`
+	Sources  []Source        `json:"sources,omitempty"`
+	Metadata []MetadataField `json:"metadata,omitempty"`
+}
+
+// Field of metadata embedded in a file like the camera model or a keyword
+type MetadataField struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Origin of a source URL of an image
+type SourceKind uint8
+
+const (
+	// URL the file was imported from
+	ImportSource SourceKind = iota
+	// Booru post tags were fetched from
+	PostSource
+	// Original source of the file listed on a booru post
+	OriginalSource
+	// Added manually by the user
+	UserSource
+)
+
+var sourceKindStr = [...]string{"import", "post", "original", "user"}
+
+func (k SourceKind) String() string {
+	return sourceKindStr[int(k)]
+}
+
+func (k SourceKind) MarshalJSON() ([]byte, error) {
+	return strconv.AppendQuote(nil, k.String()), nil
+}
+
+func (k *SourceKind) UnmarshalJSON(buf []byte) error {
+	s, err := strconv.Unquote(string(buf))
+	if err != nil {
+		return err
+	}
+	for i, str := range sourceKindStr {
+		if str == s {
+			*k = SourceKind(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown source kind: %s", s)
+}
+
+// Source URL of an image
+type Source struct {
+	URL  string     `json:"url"`
+	Kind SourceKind `json:"kind"`
+	// Unix time the source was added
+	Time int64 `json:"time"`
 }
 
 // Common fields
@@ -30,9 +90,12 @@ type Dims struct {
 
 // Only provides the most minimal of fields. Optimal for thumbnail views.
 type CompactImage struct {
+	ID    int64    `json:"-"`
 	Type  FileType `json:"type"`
 	SHA1  string   `json:"sha1"`
 	Thumb Dims     `json:"thumb"`
+	// Has an animated hover preview
+	Preview bool `json:"preview"`
 }
 
 // TODO: Use these types instead of hex strings
@@ -17,7 +17,7 @@ var (
 	_ easyjson.Marshaler
 )
 
-func easyjson220accf5DecodeGithubComBakapeHydronCommon(in *jlexer.Lexer, out *Image) {
+func easyjson220accf5DecodeGithubComBakapeHydronCommon(in *jlexer.Lexer, out *Source) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -28,7 +28,162 @@ func easyjson220accf5DecodeGithubComBakapeHydronCommon(in *jlexer.Lexer, out *Im
 	}
 	in.Delim('{')
 	for !in.IsDelim('}') {
-		key := in.UnsafeString()
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "url":
+			out.URL = string(in.String())
+		case "kind":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.Kind).UnmarshalJSON(data))
+			}
+		case "time":
+			out.Time = int64(in.Int64())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson220accf5EncodeGithubComBakapeHydronCommon(out *jwriter.Writer, in Source) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"url\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.URL))
+	}
+	{
+		const prefix string = ",\"kind\":"
+		out.RawString(prefix)
+		out.Raw((in.Kind).MarshalJSON())
+	}
+	{
+		const prefix string = ",\"time\":"
+		out.RawString(prefix)
+		out.Int64(int64(in.Time))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v Source) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson220accf5EncodeGithubComBakapeHydronCommon(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v Source) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson220accf5EncodeGithubComBakapeHydronCommon(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *Source) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson220accf5DecodeGithubComBakapeHydronCommon(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *Source) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson220accf5DecodeGithubComBakapeHydronCommon(l, v)
+}
+func easyjson220accf5DecodeGithubComBakapeHydronCommon1(in *jlexer.Lexer, out *MetadataField) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "key":
+			out.Key = string(in.String())
+		case "value":
+			out.Value = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson220accf5EncodeGithubComBakapeHydronCommon1(out *jwriter.Writer, in MetadataField) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"key\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Key))
+	}
+	{
+		const prefix string = ",\"value\":"
+		out.RawString(prefix)
+		out.String(string(in.Value))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v MetadataField) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson220accf5EncodeGithubComBakapeHydronCommon1(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v MetadataField) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson220accf5EncodeGithubComBakapeHydronCommon1(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *MetadataField) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson220accf5DecodeGithubComBakapeHydronCommon1(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *MetadataField) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson220accf5DecodeGithubComBakapeHydronCommon1(l, v)
+}
+func easyjson220accf5DecodeGithubComBakapeHydronCommon2(in *jlexer.Lexer, out *Image) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
 		in.WantColon()
 		if in.IsNull() {
 			in.Skip()
@@ -44,8 +199,12 @@ func easyjson220accf5DecodeGithubComBakapeHydronCommon(in *jlexer.Lexer, out *Im
 			out.Duration = uint64(in.Uint64())
 		case "md5":
 			out.MD5 = string(in.String())
+		case "sha256":
+			out.SHA256 = string(in.String())
 		case "name":
 			out.Name = string(in.String())
+		case "taken":
+			out.Taken = int64(in.Int64())
 		case "tags":
 			if in.IsNull() {
 				in.Skip()
@@ -63,12 +222,58 @@ func easyjson220accf5DecodeGithubComBakapeHydronCommon(in *jlexer.Lexer, out *Im
 				}
 				for !in.IsDelim(']') {
 					var v1 Tag
-					easyjson220accf5DecodeGithubComBakapeHydronCommon1(in, &v1)
+					easyjson220accf5DecodeGithubComBakapeHydronCommon3(in, &v1)
 					out.Tags = append(out.Tags, v1)
 					in.WantComma()
 				}
 				in.Delim(']')
 			}
+		case "sources":
+			if in.IsNull() {
+				in.Skip()
+				out.Sources = nil
+			} else {
+				in.Delim('[')
+				if out.Sources == nil {
+					if !in.IsDelim(']') {
+						out.Sources = make([]Source, 0, 2)
+					} else {
+						out.Sources = []Source{}
+					}
+				} else {
+					out.Sources = (out.Sources)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v2 Source
+					(v2).UnmarshalEasyJSON(in)
+					out.Sources = append(out.Sources, v2)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		case "metadata":
+			if in.IsNull() {
+				in.Skip()
+				out.Metadata = nil
+			} else {
+				in.Delim('[')
+				if out.Metadata == nil {
+					if !in.IsDelim(']') {
+						out.Metadata = make([]MetadataField, 0, 2)
+					} else {
+						out.Metadata = []MetadataField{}
+					}
+				} else {
+					out.Metadata = (out.Metadata)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v3 MetadataField
+					(v3).UnmarshalEasyJSON(in)
+					out.Metadata = append(out.Metadata, v3)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
 		case "width":
 			out.Width = uint64(in.Uint64())
 		case "height":
@@ -79,6 +284,8 @@ func easyjson220accf5DecodeGithubComBakapeHydronCommon(in *jlexer.Lexer, out *Im
 			out.SHA1 = string(in.String())
 		case "thumb":
 			(out.Thumb).UnmarshalEasyJSON(in)
+		case "preview":
+			out.Preview = bool(in.Bool())
 		default:
 			in.SkipRecursive()
 		}
@@ -89,156 +296,144 @@ func easyjson220accf5DecodeGithubComBakapeHydronCommon(in *jlexer.Lexer, out *Im
 		in.Consumed()
 	}
 }
-func easyjson220accf5EncodeGithubComBakapeHydronCommon(out *jwriter.Writer, in Image) {
+func easyjson220accf5EncodeGithubComBakapeHydronCommon2(out *jwriter.Writer, in Image) {
 	out.RawByte('{')
 	first := true
 	_ = first
 	{
 		const prefix string = ",\"import_time\":"
-		if first {
-			first = false
-			out.RawString(prefix[1:])
-		} else {
-			out.RawString(prefix)
-		}
+		out.RawString(prefix[1:])
 		out.Int64(int64(in.ImportTime))
 	}
 	{
 		const prefix string = ",\"size\":"
-		if first {
-			first = false
-			out.RawString(prefix[1:])
-		} else {
-			out.RawString(prefix)
-		}
+		out.RawString(prefix)
 		out.Int(int(in.Size))
 	}
 	if in.Duration != 0 {
 		const prefix string = ",\"duration\":"
-		if first {
-			first = false
-			out.RawString(prefix[1:])
-		} else {
-			out.RawString(prefix)
-		}
+		out.RawString(prefix)
 		out.Uint64(uint64(in.Duration))
 	}
 	{
 		const prefix string = ",\"md5\":"
-		if first {
-			first = false
-			out.RawString(prefix[1:])
-		} else {
-			out.RawString(prefix)
-		}
+		out.RawString(prefix)
 		out.String(string(in.MD5))
 	}
+	if in.SHA256 != "" {
+		const prefix string = ",\"sha256\":"
+		out.RawString(prefix)
+		out.String(string(in.SHA256))
+	}
 	{
 		const prefix string = ",\"name\":"
-		if first {
-			first = false
-			out.RawString(prefix[1:])
-		} else {
-			out.RawString(prefix)
-		}
+		out.RawString(prefix)
 		out.String(string(in.Name))
 	}
+	if in.Taken != 0 {
+		const prefix string = ",\"taken\":"
+		out.RawString(prefix)
+		out.Int64(int64(in.Taken))
+	}
 	if len(in.Tags) != 0 {
 		const prefix string = ",\"tags\":"
-		if first {
-			first = false
-			out.RawString(prefix[1:])
-		} else {
-			out.RawString(prefix)
+		out.RawString(prefix)
+		{
+			out.RawByte('[')
+			for v4, v5 := range in.Tags {
+				if v4 > 0 {
+					out.RawByte(',')
+				}
+				easyjson220accf5EncodeGithubComBakapeHydronCommon3(out, v5)
+			}
+			out.RawByte(']')
+		}
+	}
+	if len(in.Sources) != 0 {
+		const prefix string = ",\"sources\":"
+		out.RawString(prefix)
+		{
+			out.RawByte('[')
+			for v6, v7 := range in.Sources {
+				if v6 > 0 {
+					out.RawByte(',')
+				}
+				(v7).MarshalEasyJSON(out)
+			}
+			out.RawByte(']')
 		}
+	}
+	if len(in.Metadata) != 0 {
+		const prefix string = ",\"metadata\":"
+		out.RawString(prefix)
 		{
 			out.RawByte('[')
-			for v2, v3 := range in.Tags {
-				if v2 > 0 {
+			for v8, v9 := range in.Metadata {
+				if v8 > 0 {
 					out.RawByte(',')
 				}
-				easyjson220accf5EncodeGithubComBakapeHydronCommon1(out, v3)
+				(v9).MarshalEasyJSON(out)
 			}
 			out.RawByte(']')
 		}
 	}
 	{
 		const prefix string = ",\"width\":"
-		if first {
-			first = false
-			out.RawString(prefix[1:])
-		} else {
-			out.RawString(prefix)
-		}
+		out.RawString(prefix)
 		out.Uint64(uint64(in.Width))
 	}
 	{
 		const prefix string = ",\"height\":"
-		if first {
-			first = false
-			out.RawString(prefix[1:])
-		} else {
-			out.RawString(prefix)
-		}
+		out.RawString(prefix)
 		out.Uint64(uint64(in.Height))
 	}
 	{
 		const prefix string = ",\"type\":"
-		if first {
-			first = false
-			out.RawString(prefix[1:])
-		} else {
-			out.RawString(prefix)
-		}
+		out.RawString(prefix)
 		out.Uint8(uint8(in.Type))
 	}
 	{
 		const prefix string = ",\"sha1\":"
-		if first {
-			first = false
-			out.RawString(prefix[1:])
-		} else {
-			out.RawString(prefix)
-		}
+		out.RawString(prefix)
 		out.String(string(in.SHA1))
 	}
 	{
 		const prefix string = ",\"thumb\":"
-		if first {
-			first = false
-			out.RawString(prefix[1:])
-		} else {
-			out.RawString(prefix)
-		}
+		out.RawString(prefix)
 		(in.Thumb).MarshalEasyJSON(out)
 	}
+	{
+		const prefix string = ",\"preview\":"
+		out.RawString(prefix)
+		out.Bool(bool(in.Preview))
+	}
 	out.RawByte('}')
 }
 
 // MarshalJSON supports json.Marshaler interface
 func (v Image) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson220accf5EncodeGithubComBakapeHydronCommon(&w, v)
+	easyjson220accf5EncodeGithubComBakapeHydronCommon2(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v Image) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson220accf5EncodeGithubComBakapeHydronCommon(w, v)
+	easyjson220accf5EncodeGithubComBakapeHydronCommon2(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *Image) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson220accf5DecodeGithubComBakapeHydronCommon(&r, v)
+	easyjson220accf5DecodeGithubComBakapeHydronCommon2(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *Image) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson220accf5DecodeGithubComBakapeHydronCommon(l, v)
+	easyjson220accf5DecodeGithubComBakapeHydronCommon2(l, v)
 }
-func easyjson220accf5DecodeGithubComBakapeHydronCommon1(in *jlexer.Lexer, out *Tag) {
+func easyjson220accf5DecodeGithubComBakapeHydronCommon3(in *jlexer.Lexer, out *Tag) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -249,7 +444,7 @@ func easyjson220accf5DecodeGithubComBakapeHydronCommon1(in *jlexer.Lexer, out *T
 	}
 	in.Delim('{')
 	for !in.IsDelim('}') {
-		key := in.UnsafeString()
+		key := in.UnsafeFieldName(false)
 		in.WantColon()
 		if in.IsNull() {
 			in.Skip()
@@ -273,43 +468,28 @@ func easyjson220accf5DecodeGithubComBakapeHydronCommon1(in *jlexer.Lexer, out *T
 		in.Consumed()
 	}
 }
-func easyjson220accf5EncodeGithubComBakapeHydronCommon1(out *jwriter.Writer, in Tag) {
+func easyjson220accf5EncodeGithubComBakapeHydronCommon3(out *jwriter.Writer, in Tag) {
 	out.RawByte('{')
 	first := true
 	_ = first
 	{
 		const prefix string = ",\"source\":"
-		if first {
-			first = false
-			out.RawString(prefix[1:])
-		} else {
-			out.RawString(prefix)
-		}
+		out.RawString(prefix[1:])
 		out.Uint8(uint8(in.Source))
 	}
 	{
 		const prefix string = ",\"type\":"
-		if first {
-			first = false
-			out.RawString(prefix[1:])
-		} else {
-			out.RawString(prefix)
-		}
+		out.RawString(prefix)
 		out.Uint8(uint8(in.Type))
 	}
 	{
 		const prefix string = ",\"tag\":"
-		if first {
-			first = false
-			out.RawString(prefix[1:])
-		} else {
-			out.RawString(prefix)
-		}
+		out.RawString(prefix)
 		out.String(string(in.Tag))
 	}
 	out.RawByte('}')
 }
-func easyjson220accf5DecodeGithubComBakapeHydronCommon2(in *jlexer.Lexer, out *Dims) {
+func easyjson220accf5DecodeGithubComBakapeHydronCommon4(in *jlexer.Lexer, out *Dims) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -320,7 +500,7 @@ func easyjson220accf5DecodeGithubComBakapeHydronCommon2(in *jlexer.Lexer, out *D
 	}
 	in.Delim('{')
 	for !in.IsDelim('}') {
-		key := in.UnsafeString()
+		key := in.UnsafeFieldName(false)
 		in.WantColon()
 		if in.IsNull() {
 			in.Skip()
@@ -342,28 +522,18 @@ func easyjson220accf5DecodeGithubComBakapeHydronCommon2(in *jlexer.Lexer, out *D
 		in.Consumed()
 	}
 }
-func easyjson220accf5EncodeGithubComBakapeHydronCommon2(out *jwriter.Writer, in Dims) {
+func easyjson220accf5EncodeGithubComBakapeHydronCommon4(out *jwriter.Writer, in Dims) {
 	out.RawByte('{')
 	first := true
 	_ = first
 	{
 		const prefix string = ",\"width\":"
-		if first {
-			first = false
-			out.RawString(prefix[1:])
-		} else {
-			out.RawString(prefix)
-		}
+		out.RawString(prefix[1:])
 		out.Uint64(uint64(in.Width))
 	}
 	{
 		const prefix string = ",\"height\":"
-		if first {
-			first = false
-			out.RawString(prefix[1:])
-		} else {
-			out.RawString(prefix)
-		}
+		out.RawString(prefix)
 		out.Uint64(uint64(in.Height))
 	}
 	out.RawByte('}')
@@ -372,27 +542,27 @@ func easyjson220accf5EncodeGithubComBakapeHydronCommon2(out *jwriter.Writer, in
 // MarshalJSON supports json.Marshaler interface
 func (v Dims) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson220accf5EncodeGithubComBakapeHydronCommon2(&w, v)
+	easyjson220accf5EncodeGithubComBakapeHydronCommon4(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v Dims) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson220accf5EncodeGithubComBakapeHydronCommon2(w, v)
+	easyjson220accf5EncodeGithubComBakapeHydronCommon4(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *Dims) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson220accf5DecodeGithubComBakapeHydronCommon2(&r, v)
+	easyjson220accf5DecodeGithubComBakapeHydronCommon4(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *Dims) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson220accf5DecodeGithubComBakapeHydronCommon2(l, v)
+	easyjson220accf5DecodeGithubComBakapeHydronCommon4(l, v)
 }
-func easyjson220accf5DecodeGithubComBakapeHydronCommon3(in *jlexer.Lexer, out *CompactImage) {
+func easyjson220accf5DecodeGithubComBakapeHydronCommon5(in *jlexer.Lexer, out *CompactImage) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -403,7 +573,7 @@ func easyjson220accf5DecodeGithubComBakapeHydronCommon3(in *jlexer.Lexer, out *C
 	}
 	in.Delim('{')
 	for !in.IsDelim('}') {
-		key := in.UnsafeString()
+		key := in.UnsafeFieldName(false)
 		in.WantColon()
 		if in.IsNull() {
 			in.Skip()
@@ -417,6 +587,8 @@ func easyjson220accf5DecodeGithubComBakapeHydronCommon3(in *jlexer.Lexer, out *C
 			out.SHA1 = string(in.String())
 		case "thumb":
 			(out.Thumb).UnmarshalEasyJSON(in)
+		case "preview":
+			out.Preview = bool(in.Bool())
 		default:
 			in.SkipRecursive()
 		}
@@ -427,7 +599,7 @@ func easyjson220accf5DecodeGithubComBakapeHydronCommon3(in *jlexer.Lexer, out *C
 		in.Consumed()
 	}
 }
-func easyjson220accf5EncodeGithubComBakapeHydronCommon3(out *jwriter.Writer, in CompactImage) {
+func easyjson220accf5EncodeGithubComBakapeHydronCommon5(out *jwriter.Writer, in CompactImage) {
 	out.RawByte('{')
 	first := true
 	_ = first
@@ -443,47 +615,42 @@ func easyjson220accf5EncodeGithubComBakapeHydronCommon3(out *jwriter.Writer, in
 	}
 	{
 		const prefix string = ",\"sha1\":"
-		if first {
-			first = false
-			out.RawString(prefix[1:])
-		} else {
-			out.RawString(prefix)
-		}
+		out.RawString(prefix)
 		out.String(string(in.SHA1))
 	}
 	{
 		const prefix string = ",\"thumb\":"
-		if first {
-			first = false
-			out.RawString(prefix[1:])
-		} else {
-			out.RawString(prefix)
-		}
+		out.RawString(prefix)
 		(in.Thumb).MarshalEasyJSON(out)
 	}
+	{
+		const prefix string = ",\"preview\":"
+		out.RawString(prefix)
+		out.Bool(bool(in.Preview))
+	}
 	out.RawByte('}')
 }
 
 // MarshalJSON supports json.Marshaler interface
 func (v CompactImage) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson220accf5EncodeGithubComBakapeHydronCommon3(&w, v)
+	easyjson220accf5EncodeGithubComBakapeHydronCommon5(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
 func (v CompactImage) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson220accf5EncodeGithubComBakapeHydronCommon3(w, v)
+	easyjson220accf5EncodeGithubComBakapeHydronCommon5(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *CompactImage) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson220accf5DecodeGithubComBakapeHydronCommon3(&r, v)
+	easyjson220accf5DecodeGithubComBakapeHydronCommon5(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *CompactImage) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson220accf5DecodeGithubComBakapeHydronCommon3(l, v)
+	easyjson220accf5DecodeGithubComBakapeHydronCommon5(l, v)
 }
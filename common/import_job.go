@@ -0,0 +1,85 @@
+package common
+
+import (
+	"strconv"
+)
+
+// Result of importing a single path of an import job
+type ImportStatus uint8
+
+const (
+	ImportPending ImportStatus = iota
+	ImportImported
+	ImportDuplicate
+	ImportUnsupported
+	ImportError
+)
+
+var importStatusStr = [...]string{
+	"pending", "imported", "duplicate", "unsupported", "error",
+}
+
+func (s ImportStatus) String() string {
+	return importStatusStr[int(s)]
+}
+
+func (s ImportStatus) MarshalJSON() ([]byte, error) {
+	return strconv.AppendQuote(nil, s.String()), nil
+}
+
+// Persistent import of a set of file paths and URLs
+type ImportJob struct {
+	ID int64 `json:"id"`
+	// Unix time the job was created
+	Created int64 `json:"created"`
+	// Unix time the job finished or 0, if not finished
+	Finished    int64  `json:"finished"`
+	Delete      bool   `json:"delete"`
+	FetchTags   bool   `json:"fetch_tags"`
+	StoreName   bool   `json:"store_name"`
+	ArchiveTags bool   `json:"archive_tags"`
+	Link        bool   `json:"link"`
+	Tags        string `json:"tags"`
+	// Number of paths by import status
+	Counts ImportCounts `json:"counts"`
+	// Paths, that failed to import. Only defined, when retrieving a single
+	// job.
+	Failed []ImportJobPath `json:"failed,omitempty"`
+}
+
+// Number of paths of an import job by import status
+type ImportCounts struct {
+	Pending     int `json:"pending"`
+	Imported    int `json:"imported"`
+	Duplicate   int `json:"duplicate"`
+	Unsupported int `json:"unsupported"`
+	Error       int `json:"error"`
+}
+
+// Add n paths with status s to the counts
+func (c *ImportCounts) Add(s ImportStatus, n int) {
+	switch s {
+	case ImportPending:
+		c.Pending += n
+	case ImportImported:
+		c.Imported += n
+	case ImportDuplicate:
+		c.Duplicate += n
+	case ImportUnsupported:
+		c.Unsupported += n
+	case ImportError:
+		c.Error += n
+	}
+}
+
+// Total number of paths
+func (c ImportCounts) Total() int {
+	return c.Pending + c.Imported + c.Duplicate + c.Unsupported + c.Error
+}
+
+// Path of an import job and the result of importing it
+type ImportJobPath struct {
+	Path   string       `json:"path"`
+	Status ImportStatus `json:"status"`
+	Error  string       `json:"error,omitempty"`
+}
@@ -67,6 +67,7 @@ const (
 	ByDuration
 	ByTagCount
 	Random
+	ByTaken
 )
 
 // Types of option
@@ -0,0 +1,20 @@
+package common
+
+// Booru tag query, that is periodically searched for new posts to import
+type Subscription struct {
+	ID int64 `json:"id"`
+	// Name of the tag fetcher to search with
+	Fetcher string `json:"fetcher"`
+	// Booru tag query
+	Query string `json:"query"`
+	// Seconds between syncs
+	Interval int64 `json:"interval"`
+	// ID of the newest imported post
+	Cursor uint64 `json:"cursor"`
+	// Unix time of the last sync
+	LastSync int64 `json:"last_sync"`
+	// Error of the last sync, if any
+	Error string `json:"error,omitempty"`
+	// Number of files imported
+	Imported uint64 `json:"imported"`
+}
@@ -3,8 +3,12 @@ package common
 import (
 	"bytes"
 	"strconv"
+	"time"
 )
 
+// Format of dates in system:taken filters
+const DateFormat = "2006-01-02"
+
 // Able to stingify itself into a bytes.Buffer
 type WriterTo interface {
 	WriteTo(*bytes.Buffer)
@@ -26,6 +30,10 @@ const (
 	Hydrus
 )
 
+func (s TagSource) String() string {
+	return tagSourceStr[int(s)]
+}
+
 type TagType uint8
 
 const (
@@ -39,13 +47,16 @@ const (
 	MD5Field
 	SHA1Field
 	Name
+	SourceURL
+	Exif
 )
 
 var (
-	tagTypeStr = [...]string{"undefined", "author", "character", "series",
-		"rating", "system", "meta", "md5", "sha1", "name"}
+	tagSourceStr = [...]string{"user", "gelbooru", "danbooru", "hydrus"}
+	tagTypeStr   = [...]string{"undefined", "author", "character", "series",
+		"rating", "system", "meta", "md5", "sha1", "name", "source_url", "exif"}
 	systemTagStr = [...]string{"size", "width", "height", "duration",
-		"tag_count", "type"}
+		"tag_count", "type", "taken"}
 )
 
 func (t TagType) WriteTo(w *bytes.Buffer) {
@@ -74,6 +85,23 @@ type Tag struct {
 	ID     uint64    `json:"-"` // Not defined on freshly-parsed tags
 }
 
+// Suggested completion of a tag with the number of images using it, if the
+// suggestion is an existing tag
+type TagSuggestion struct {
+	Tag   string `json:"tag"`
+	Count uint64 `json:"count,omitempty"`
+}
+
+// Record of a tag being added to or removed from an image
+type TagChange struct {
+	Tag
+	Added    bool   `json:"added"`
+	Reverted bool   `json:"reverted"`
+	Time     int64  `json:"time"`
+	Batch    int64  `json:"batch"`
+	Actor    string `json:"actor"`
+}
+
 // Types of system values to retrieve
 type SystemTagType uint8
 
@@ -84,6 +112,7 @@ const (
 	Duration
 	TagCount
 	Type
+	Taken
 )
 
 func (t SystemTagType) WriteTo(w *bytes.Buffer) {
@@ -103,9 +132,13 @@ func (t SystemTag) WriteTo(w *bytes.Buffer) {
 	w.WriteString(t.Comparator)
 	// system:type needs to be converted back to its extension string
 	// from its internal enum representation
-	if t.Type == Type {
+	switch t.Type {
+	case Type:
 		w.WriteString(Extensions[FileType(t.Value)])
-	} else {
+	case Taken:
+		w.WriteString(
+			time.Unix(int64(t.Value), 0).UTC().Format(DateFormat))
+	default:
 		w.WriteString(strconv.FormatUint(t.Value, 10))
 	}
 }
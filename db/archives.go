@@ -0,0 +1,44 @@
+package db
+
+import (
+	"github.com/bakape/hydron/common"
+)
+
+// Record the position of an image among the members of an archive
+func AddArchiveMember(imageID int64, m common.ArchiveMember) (err error) {
+	_, err = sq.Insert("archive_members").
+		Columns("image_id", "archive", "name", "member", "position").
+		Values(imageID, m.Archive, m.Name, m.Member, m.Position).
+		Suffix(`on conflict (archive, member) do update
+			set image_id = excluded.image_id,
+				position = excluded.position`).
+		Exec()
+	return
+}
+
+// Return the images imported from all archives with the passed name in archive
+// order
+func GetArchiveMembers(name string) (members []common.ArchiveMember, err error) {
+	r, err := sq.Select("a.archive", "a.member", "a.position", "i.sha1").
+		From("archive_members as a").
+		Join("images as i on i.id = a.image_id").
+		Where("a.name = ?", name).
+		OrderBy("a.archive", "a.position").
+		Query()
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	for r.Next() {
+		var m common.ArchiveMember
+		err = r.Scan(&m.Archive, &m.Member, &m.Position, &m.SHA1)
+		if err != nil {
+			return
+		}
+		m.Name = name
+		members = append(members, m)
+	}
+	err = r.Err()
+	return
+}
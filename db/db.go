@@ -2,17 +2,15 @@ package db
 
 import (
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/bakape/hydron/files"
-	_ "github.com/lib/pq"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
 )
 
 var (
@@ -26,16 +24,8 @@ func Open() (err error) {
 	var conf struct {
 		Driver, Connection string
 	}
-	buf, err := ioutil.ReadFile(filepath.Join(files.RootPath, "db_conf.json"))
-	switch {
-	case os.IsNotExist(err):
-		err = nil
-	case err == nil:
-		err = json.Unmarshal(buf, &conf)
-		if err != nil {
-			return
-		}
-	default:
+	err = files.ReadConfig("db_conf.json", &conf)
+	if err != nil {
 		return
 	}
 	if conf.Driver == "" || conf.Connection == "" {
@@ -81,3 +71,12 @@ func Open() (err error) {
 func Close() error {
 	return db.Close()
 }
+
+// Returns, if err was returned by the database driver
+func IsDriverError(err error) bool {
+	var (
+		sqliteErr sqlite3.Error
+		pqErr     *pq.Error
+	)
+	return errors.As(err, &sqliteErr) || errors.As(err, &pqErr)
+}
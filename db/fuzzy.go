@@ -0,0 +1,200 @@
+package db
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/bakape/hydron/common"
+)
+
+// Minimum trigram similarity of a fuzzy tag match. Same as the pg_trgm
+// default.
+const minSimilarity = 0.3
+
+// In-process trigram index of all tags for DBMS without pg_trgm.
+// Built on first use and caught up with newly inserted tags on each use.
+// Tags are only modified or deleted by migrations, which run before the index
+// is first used.
+var trigrams trigramIndex
+
+type trigramIndex struct {
+	sync.Mutex
+	// Highest tag ID read into the index
+	lastID int64
+	tags   []common.TagBase
+	// Number of distinct trigrams of each tag
+	counts []int
+	// Trigram to tag positions in tags
+	index map[string][]uint32
+}
+
+// Extract the set of trigrams of a string the same way pg_trgm does.
+// Non-alphanumeric characters separate words and each word is padded with
+// two spaces in front and one at the end.
+func extractTrigrams(s string) map[string]struct{} {
+	set := make(map[string]struct{}, len(s)+3)
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, w := range words {
+		r := []rune("  " + w + " ")
+		for i := 0; i < len(r)-2; i++ {
+			set[string(r[i:i+3])] = struct{}{}
+		}
+	}
+	return set
+}
+
+// Read all tags inserted since the last update into the index
+func (t *trigramIndex) update() (err error) {
+	r, err := sq.Select("id", "type", "tag").
+		From("tags").
+		Where("id > ?", t.lastID).
+		OrderBy("id").
+		Query()
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	if t.index == nil {
+		t.index = make(map[string][]uint32, 1<<10)
+	}
+	var tag common.TagBase
+	for r.Next() {
+		err = r.Scan(&t.lastID, &tag.Type, &tag.Tag)
+		if err != nil {
+			return
+		}
+		pos := uint32(len(t.tags))
+		tri := extractTrigrams(tag.Tag)
+		t.tags = append(t.tags, tag)
+		t.counts = append(t.counts, len(tri))
+		for k := range tri {
+			t.index[k] = append(t.index[k], pos)
+		}
+	}
+	return r.Err()
+}
+
+// Return up to max distinct tag strings most similar to s.
+// typ: only match tags of this type, if not negative
+func (t *trigramIndex) match(s string, typ int, max int) (
+	matches []string, err error,
+) {
+	t.Lock()
+	defer t.Unlock()
+
+	err = t.update()
+	if err != nil {
+		return
+	}
+
+	query := extractTrigrams(s)
+	shared := make(map[uint32]int)
+	for k := range query {
+		for _, pos := range t.index[k] {
+			shared[pos]++
+		}
+	}
+
+	type candidate struct {
+		tag        string
+		similarity float64
+	}
+	best := make(map[string]float64)
+	for pos, n := range shared {
+		tag := t.tags[pos]
+		if typ >= 0 && tag.Type != common.TagType(typ) {
+			continue
+		}
+		sim := float64(n) / float64(len(query)+t.counts[pos]-n)
+		if sim >= minSimilarity && sim > best[tag.Tag] {
+			best[tag.Tag] = sim
+		}
+	}
+
+	candidates := make([]candidate, 0, len(best))
+	for tag, sim := range best {
+		candidates = append(candidates, candidate{tag, sim})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].similarity != candidates[j].similarity {
+			return candidates[i].similarity > candidates[j].similarity
+		}
+		return candidates[i].tag < candidates[j].tag
+	})
+	if len(candidates) > max {
+		candidates = candidates[:max]
+	}
+	matches = make([]string, len(candidates))
+	for i, c := range candidates {
+		matches[i] = c.tag
+	}
+	return
+}
+
+/*
+Suggest up to max existing tags similar to s ranked by similarity and usage.
+Uses pg_trgm on PostgreSQL and an in-process trigram index otherwise.
+typ: only match tags of this type, if not negative
+*/
+func fuzzyMatchTags(s string, typ int, max int) (
+	tags []common.TagSuggestion, err error,
+) {
+	q := sq.Select("tag", "sum(use_count)").From("tags")
+	if typ >= 0 {
+		q = q.Where("type = ?", typ)
+	}
+
+	var matches []string
+	switch driver {
+	case "postgres":
+		q = q.Where("tag % ?", s).
+			GroupBy("tag").
+			OrderByClause(
+				"max(similarity(tag, ?)) desc, sum(use_count) desc",
+				s,
+			).
+			Limit(uint64(max))
+	default:
+		matches, err = trigrams.match(s, typ, max)
+		if err != nil || len(matches) == 0 {
+			return
+		}
+		q = q.Where(squirrel.Eq{"tag": matches}).GroupBy("tag")
+	}
+
+	r, err := q.Query()
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	tags = make([]common.TagSuggestion, 0, max)
+	var t common.TagSuggestion
+	for r.Next() {
+		err = r.Scan(&t.Tag, &t.Count)
+		if err != nil {
+			return
+		}
+		tags = append(tags, t)
+	}
+	err = r.Err()
+	if err != nil || matches == nil {
+		return
+	}
+
+	// Restore similarity ranking of the in-process index
+	rank := make(map[string]int, len(matches))
+	for i, m := range matches {
+		rank[m] = i
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return rank[tags[i].Tag] < rank[tags[j].Tag]
+	})
+	return
+}
@@ -0,0 +1,218 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/bakape/hydron/common"
+)
+
+// Interfaces a batch of tag changes can originate from
+const (
+	ActorCLI          = "cli"
+	ActorHTTP         = "http"
+	ActorImport       = "import"
+	ActorFetch        = "fetch_tags"
+	ActorRules        = "tag_rules"
+	ActorSubscription = "subscription"
+	ActorHydrus       = "import_hydrus"
+	ActorHydrusAPI    = "hydrus_api"
+)
+
+// Groups tag changes, that can be reverted together
+type Batch struct {
+	ID    int64
+	Actor string
+}
+
+// Create a new batch of tag changes originating from actor
+func NewBatch(actor string) (b Batch, err error) {
+	b.Actor = actor
+	err = InTransaction(func(tx *sql.Tx) (err error) {
+		b.ID, err = getLastID(tx, sq.
+			Insert("tag_batches").
+			Columns("actor", "time").
+			Values(actor, time.Now().Unix()))
+		return
+	})
+	return
+}
+
+// Remove a batch, if no tag changes were recorded in it
+func DiscardEmptyBatch(id int64) error {
+	_, err := sq.Delete("tag_batches").
+		Where("id = ?", id).
+		Where("not exists (select 1 from tag_history where batch_id = ?)", id).
+		Exec()
+	return err
+}
+
+// Record a tag being added to or removed from an image
+func recordTagChange(tx *sql.Tx, b Batch, imageID, tagID int64,
+	source common.TagSource, added bool,
+) (err error) {
+	_, err = sq.
+		Insert("tag_history").
+		Columns("batch_id", "image_id", "tag_id", "source", "added", "time").
+		Values(b.ID, imageID, tagID, source, added, time.Now().Unix()).
+		RunWith(tx).
+		Exec()
+	return
+}
+
+// Retrieve the tag edit history of an image ordered from newest to oldest
+func GetTagHistory(imageID int64) (changes []common.TagChange, err error) {
+	r, err := sq.
+		Select(
+			"h.batch_id", "b.actor", "h.time", "h.added", "h.reverted",
+			"h.source", "t.type", "t.tag",
+		).
+		From("tag_history as h").
+		Join("tag_batches as b on b.id = h.batch_id").
+		Join("tags as t on t.id = h.tag_id").
+		Where("h.image_id = ?", imageID).
+		OrderBy("h.id desc").
+		Query()
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	changes = make([]common.TagChange, 0, 64)
+	var c common.TagChange
+	for r.Next() {
+		err = r.Scan(&c.Batch, &c.Actor, &c.Time, &c.Added, &c.Reverted,
+			&c.Source, &c.Type, &c.TagBase.Tag)
+		if err != nil {
+			return
+		}
+		changes = append(changes, c)
+	}
+	err = r.Err()
+	return
+}
+
+// Revert the last n tag changes, that have not been reverted yet.
+// Returns the number of reverted changes.
+func UndoLast(n uint64) (int, error) {
+	return undo(func(q squirrel.SelectBuilder) squirrel.SelectBuilder {
+		return q.Limit(n)
+	})
+}
+
+// Revert all tag changes of a batch.
+// Returns the number of reverted changes.
+func UndoBatch(id int64) (int, error) {
+	return undo(func(q squirrel.SelectBuilder) squirrel.SelectBuilder {
+		return q.Where("batch_id = ?", id)
+	})
+}
+
+// Revert the latest batch of tag changes, that has not been reverted yet.
+// Returns the number of reverted changes.
+func UndoLastBatch() (int, error) {
+	return undo(func(q squirrel.SelectBuilder) squirrel.SelectBuilder {
+		return q.Where(`batch_id = (
+			select max(batch_id)
+			from tag_history
+			where reverted = ?)`,
+			false,
+		)
+	})
+}
+
+// Revert all tag changes matched by the query modified with filter in reverse
+// chronological order
+func undo(filter func(squirrel.SelectBuilder) squirrel.SelectBuilder,
+) (reverted int, err error) {
+	type change struct {
+		id, imageID, tagID int64
+		source             common.TagSource
+		added              bool
+	}
+
+	err = InTransaction(func(tx *sql.Tx) (err error) {
+		r, err := filter(sq.
+			Select("id", "image_id", "tag_id", "source", "added").
+			From("tag_history").
+			Where("reverted = ?", false).
+			OrderBy("id desc")).
+			RunWith(tx).
+			Query()
+		if err != nil {
+			return
+		}
+		var changes []change
+		for r.Next() {
+			var c change
+			err = r.Scan(&c.id, &c.imageID, &c.tagID, &c.source, &c.added)
+			if err != nil {
+				r.Close()
+				return
+			}
+			changes = append(changes, c)
+		}
+		err = r.Err()
+		r.Close()
+		if err != nil {
+			return
+		}
+
+		for _, c := range changes {
+			match := squirrel.Eq{
+				"image_id": c.imageID,
+				"tag_id":   c.tagID,
+				"source":   c.source,
+			}
+			if c.added {
+				_, err = sq.Delete("image_tags").
+					Where(match).
+					RunWith(tx).
+					Exec()
+			} else {
+				// Tag might have been added back since
+				var exists bool
+				err = sq.Select("count(*) != 0").
+					From("image_tags").
+					Where(match).
+					RunWith(tx).
+					QueryRow().
+					Scan(&exists)
+				if err == nil && !exists {
+					_, err = sq.Insert("image_tags").
+						Columns("image_id", "tag_id", "source").
+						Values(c.imageID, c.tagID, c.source).
+						RunWith(tx).
+						Exec()
+				}
+			}
+			if err != nil {
+				return
+			}
+
+			_, err = sq.Update("tag_history").
+				Set("reverted", true).
+				Where("id = ?", c.id).
+				RunWith(tx).
+				Exec()
+			if err != nil {
+				return
+			}
+		}
+		reverted = len(changes)
+		return
+	})
+	return
+}
+
+// Retrieve an existing batch by ID
+func GetBatch(id int64) (b Batch, err error) {
+	b.ID = id
+	err = sq.Select("actor").
+		From("tag_batches").
+		Where("id = ?", id).
+		QueryRow().
+		Scan(&b.Actor)
+	return
+}
@@ -0,0 +1,32 @@
+package db
+
+import (
+	"database/sql"
+)
+
+// Return, if a file from a Hydrus client with the hex-encoded SHA256 hash
+// has already been imported
+func IsHydrusImported(sha256 string) (imported bool, err error) {
+	err = sq.Select("1").
+		From("hydrus_imports").
+		Where("sha256 = ?", sha256).
+		QueryRow().
+		Scan(new(int))
+	switch err {
+	case nil:
+		imported = true
+	case sql.ErrNoRows:
+		err = nil
+	}
+	return
+}
+
+// Record a file from a Hydrus client as imported
+func SetHydrusImported(sha256 string, imageID int64) (err error) {
+	_, err = sq.Insert("hydrus_imports").
+		Columns("sha256", "image_id").
+		Values(sha256, imageID).
+		Suffix("on conflict (sha256) do nothing").
+		Exec()
+	return
+}
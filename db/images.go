@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/bakape/hydron/common"
@@ -12,8 +14,9 @@ import (
 )
 
 type IDAndMD5 struct {
-	ID  int64
-	MD5 string
+	ID   int64
+	MD5  string
+	Type common.FileType
 }
 
 func selectTagID() squirrel.SelectBuilder {
@@ -84,7 +87,8 @@ func SearchImages(page *common.Page, paginate bool,
 
 	// Build queries
 
-	q := sq.Select("sha1", "type", "thumb_width", "thumb_height").
+	q := sq.Select("id", "sha1", "type", "thumb_width", "thumb_height",
+		"preview").
 		From("images as  i")
 	count := sq.Select("count(*)").From("images as  i")
 
@@ -131,12 +135,50 @@ func SearchImages(page *common.Page, paginate bool,
 				where it.image_id = i.id)`
 		case common.Type:
 			p = "type"
+		case common.Taken:
+			p = "taken"
+			if s.Comparator == "=" {
+				// Match the entire day
+				apply("taken >= %d and taken < %d", s.Value, s.Value+24*3600)
+				continue
+			}
 		}
 		apply("%s %s %d", p, s.Comparator, s.Value)
 	}
 	for _, s := range page.Filters.SystemStr {
 		var p string
 		switch s.Type {
+		case common.SourceURL:
+			// Glob match with * wildcards
+			pattern := strings.Replace(
+				escapeLike(strings.ToLower(s.Tag)), "*", "%", -1)
+			where := `exists (
+				select 1
+				from sources as s
+				where s.image_id = i.id
+					and lower(s.url) like ? escape '$')`
+			q = q.Where(where, pattern)
+			count = count.Where(where, pattern)
+			continue
+		case common.Exif:
+			// Match a field or a field and value like "model=canon_eos_5d"
+			// with * wildcards. Spaces in values are matched by underscores.
+			field, val := s.Tag, "*"
+			if i := strings.IndexByte(field, '='); i != -1 {
+				field, val = field[:i], field[i+1:]
+			}
+			pattern := strings.Replace(
+				escapeLike(strings.ToLower(val)), "*", "%", -1)
+			where := `exists (
+				select 1
+				from image_metadata as m
+				where m.image_id = i.id
+					and m.field = ?
+					and lower(replace(m.val, ' ', '_')) like ? escape '$')`
+			args := []interface{}{strings.ToLower(field), pattern}
+			q = q.Where(where, args...)
+			count = count.Where(where, args...)
+			continue
 		case common.MD5Field:
 			p = "md5"
 		case common.SHA1Field:
@@ -174,6 +216,10 @@ func SearchImages(page *common.Page, paginate bool,
 				where it.image_id = i.id)`
 		case common.Random:
 			by = "random()"
+		case common.ByTaken:
+			// Always sort files without a known date last
+			q = q.OrderBy("taken is null")
+			by = "taken"
 		}
 		q = q.OrderBy(fmt.Sprintf("%s %s", by, mode))
 	}
@@ -196,7 +242,8 @@ func SearchImages(page *common.Page, paginate bool,
 	defer r.Close()
 	var rec common.CompactImage
 	for r.Next() {
-		err = r.Scan(&rec.SHA1, &rec.Type, &rec.Thumb.Width, &rec.Thumb.Height)
+		err = r.Scan(&rec.ID, &rec.SHA1, &rec.Type, &rec.Thumb.Width,
+			&rec.Thumb.Height, &rec.Preview)
 		if err != nil {
 			return
 		}
@@ -271,10 +318,12 @@ func RemoveImage(id string) (err error) {
 	}
 
 	// Remove files
-	for _, p := range [...]string{
-		files.SourcePath(id, srcType),
-		files.ThumbPath(id),
-	} {
+	paths, err := files.AllThumbPaths(id)
+	if err != nil {
+		return
+	}
+	paths = append(paths, files.PreviewPath(id), files.SourcePath(id, srcType))
+	for _, p := range paths {
 		err = os.Remove(p)
 		switch {
 		case err == nil:
@@ -288,22 +337,41 @@ func RemoveImage(id string) (err error) {
 	return
 }
 
-// Retrieve an image and all it's tags by SHA1 hash
-func GetImage(sha1 string) (img common.Image, err error) {
+// Retrieve an image and all it's tags, sources and metadata by SHA1 hash
+func GetImage(sha1 string) (common.Image, error) {
+	return getImage("sha1 = ?", sha1)
+}
+
+// Retrieve an image and all it's tags, sources and metadata by ID
+func GetImageByID(id int64) (common.Image, error) {
+	return getImage("id = ?", id)
+}
+
+// Retrieve an image and all it's tags, sources and metadata by SHA256 hash
+func GetImageBySHA256(sha256 string) (common.Image, error) {
+	return getImage("sha256 = ?", sha256)
+}
+
+// Retrieve the first image matching the where clause and all it's tags,
+// sources and metadata
+func getImage(where string, arg interface{}) (img common.Image, err error) {
 	err = InTransaction(func(tx *sql.Tx) (err error) {
 		err = sq.
 			Select(
 				"type", "sha1", "thumb_width", "thumb_height",
 				"width", "height", "import_time", "size", "duration", "md5",
-				"id", "name").
+				"coalesce(sha256, '')", "id", "name", "coalesce(taken, 0)",
+				"preview",
+			).
 			From("images").
-			Where("sha1 = ?", sha1).
+			Where(where, arg).
 			RunWith(tx).
 			QueryRow().
 			Scan(
 				&img.Type, &img.SHA1, &img.Thumb.Width, &img.Thumb.Height,
 				&img.Width, &img.Height, &img.ImportTime, &img.Size,
-				&img.Duration, &img.MD5, &img.ID, &img.Name,
+				&img.Duration, &img.MD5, &img.SHA256, &img.ID, &img.Name,
+				&img.Taken, &img.Preview,
 			)
 		if err != nil {
 			return
@@ -331,6 +399,14 @@ func GetImage(sha1 string) (img common.Image, err error) {
 
 		return
 	})
+	if err != nil {
+		return
+	}
+	img.Sources, err = GetSources(img.ID)
+	if err != nil {
+		return
+	}
+	img.Metadata, err = GetMetadata(img.ID)
 	return
 }
 
@@ -343,23 +419,43 @@ func GetImageID(sha1 string) (id int64, err error) {
 	return
 }
 
+// Get the ID of an image by its MD5 hash
+func GetImageIDByMD5(md5 string) (id int64, err error) {
+	err = sq.Select("id").
+		From("images").
+		Where("md5 = ?", md5).
+		QueryRow().
+		Scan(&id)
+	return
+}
+
 func GetImageIDAndMD5(sha1 string) (pair IDAndMD5, err error) {
-	err = sq.Select("id", "md5").
+	err = sq.Select("id", "md5", "type").
 		From("images").
 		Where("sha1 = ?", sha1).
 		QueryRow().
-		Scan(&pair.ID, &pair.MD5)
+		Scan(&pair.ID, &pair.MD5, &pair.Type)
 	return
 }
 
-// Get IDs and MD5 hashes of all images that can have tags on danbooru
-func GetDanbooruTaggable() (pairs []IDAndMD5, err error) {
-	r, err := sq.Select("id", "md5").
-		From("images").
-		Where(
-			"type in (?,?,?,?)",
-			common.JPEG, common.PNG, common.GIF, common.WEBM,
-		).
+/*
+Get IDs, MD5 hashes and types of all images of the passed types, that can have
+tags fetched.
+checkedBefore: skip images tags were fetched for after this time
+retryMissing: include images no post was found for on the last fetch
+*/
+func GetTaggable(types []common.FileType, checkedBefore time.Time,
+	retryMissing bool,
+) (pairs []IDAndMD5, err error) {
+	stale := squirrel.And{squirrel.Lt{"f.time": checkedBefore.Unix()}}
+	if !retryMissing {
+		stale = append(stale, squirrel.Eq{"f.found": true})
+	}
+	r, err := sq.Select("i.id", "i.md5", "i.type").
+		From("images as i").
+		LeftJoin("tag_fetches as f on f.image_id = i.id").
+		Where(squirrel.Eq{"i.type": types}).
+		Where(squirrel.Or{squirrel.Eq{"f.image_id": nil}, stale}).
 		Query()
 	if err != nil {
 		return
@@ -369,7 +465,7 @@ func GetDanbooruTaggable() (pairs []IDAndMD5, err error) {
 	pairs = make([]IDAndMD5, 0, 1<<15)
 	var pair IDAndMD5
 	for r.Next() {
-		err = r.Scan(&pair.ID, &pair.MD5)
+		err = r.Scan(&pair.ID, &pair.MD5, &pair.Type)
 		if err != nil {
 			return
 		}
@@ -379,6 +475,62 @@ func GetDanbooruTaggable() (pairs []IDAndMD5, err error) {
 	return
 }
 
+// Get IDs of all images, that have tags from source
+func GetImagesWithTagsFrom(source common.TagSource) (ids []int64, err error) {
+	r, err := sq.Select("image_id").
+		Distinct().
+		From("image_tags").
+		Where("source = ?", source).
+		OrderBy("image_id").
+		Query()
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	ids = make([]int64, 0, 1<<10)
+	var id int64
+	for r.Next() {
+		err = r.Scan(&id)
+		if err != nil {
+			return
+		}
+		ids = append(ids, id)
+	}
+	err = r.Err()
+	return
+}
+
+// Get tags of an image from a specific source
+func GetImageTagsFrom(imageID int64, source common.TagSource) (
+	tags []common.Tag, err error,
+) {
+	r, err := sq.Select("type", "tag").
+		From("image_tags").
+		Join("tags on image_tags.tag_id = tags.id").
+		Where(squirrel.Eq{
+			"image_id": imageID,
+			"source":   source,
+		}).
+		Query()
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	tags = make([]common.Tag, 0, 32)
+	t := common.Tag{Source: source}
+	for r.Next() {
+		err = r.Scan(&t.Type, &t.Tag)
+		if err != nil {
+			return
+		}
+		tags = append(tags, t)
+	}
+	err = r.Err()
+	return
+}
+
 // Return, if images is already imported into the database
 func IsImported(sha1 string) (imported bool, err error) {
 	inner, args, err := sq.Select("1").
@@ -393,24 +545,33 @@ func IsImported(sha1 string) (imported bool, err error) {
 	return
 }
 
-// Write image and its tags to database and return the image ID
-func WriteImage(i common.Image) (id int64, err error) {
+// Write image and its tags to database and return the image ID.
+// The tag additions are recorded in b.
+func WriteImage(b Batch, i common.Image) (id int64, err error) {
 	err = InTransaction(func(tx *sql.Tx) (err error) {
 		q := sq.Insert("images").
 			Columns(
 				"type", "width", "height", "import_time", "size", "duration",
-				"md5", "sha1", "thumb_width", "thumb_height", "name",
+				"md5", "sha1", "sha256", "thumb_width", "thumb_height", "name",
+				"preview",
 			).
 			Values(
 				i.Type, i.Width, i.Height, i.ImportTime, i.Size, i.Duration,
-				i.MD5, i.SHA1, i.Thumb.Width, i.Thumb.Height, i.Name,
+				i.MD5, i.SHA1, i.SHA256, i.Thumb.Width, i.Thumb.Height, i.Name,
+				i.Preview,
 			)
 		id, err = getLastID(tx, q)
 		if err != nil {
 			return
 		}
 
-		err = AddTagsTx(tx, id, i.Tags)
+		err = AddTagsTx(tx, b, id, i.Tags)
+		if err != nil {
+			return
+		}
+		if i.Taken != 0 || len(i.Metadata) != 0 {
+			err = setMetadataTx(tx, id, i.Taken, i.Metadata)
+		}
 		return
 	})
 	return
@@ -425,3 +586,70 @@ func SetName(id int64, name string) error {
 
 	return err
 }
+
+// Set the dimensions of an image's thumbnail and, if it has an animated hover
+// preview
+func SetThumbnail(id int64, dims common.Dims, preview bool) error {
+	_, err := sq.Update("images").
+		Set("thumb_width", dims.Width).
+		Set("thumb_height", dims.Height).
+		Set("preview", preview).
+		Where("id = ?", id).
+		Exec()
+	return err
+}
+
+// Record the time tags were fetched for an image and, if a matching post was
+// found
+func SetFetchState(imageID int64, found bool) (err error) {
+	_, err = sq.Insert("tag_fetches").
+		Columns("image_id", "time", "found").
+		Values(imageID, time.Now().Unix(), found).
+		Suffix(`on conflict (image_id) do update
+			set time = excluded.time, found = excluded.found`).
+		Exec()
+	return
+}
+
+// Return all images without a stored SHA256 hash
+func GetUnhashedImages() (images []common.CompactImage, err error) {
+	r, err := sq.Select("id", "sha1", "type").
+		From("images").
+		Where("sha256 is null").
+		OrderBy("id").
+		Query()
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	var img common.CompactImage
+	for r.Next() {
+		err = r.Scan(&img.ID, &img.SHA1, &img.Type)
+		if err != nil {
+			return
+		}
+		images = append(images, img)
+	}
+	err = r.Err()
+	return
+}
+
+// Return the SHA256 hash of an image or "", if not yet hashed
+func GetSHA256(id int64) (sha256 string, err error) {
+	err = sq.Select("coalesce(sha256, '')").
+		From("images").
+		Where("id = ?", id).
+		QueryRow().
+		Scan(&sha256)
+	return
+}
+
+// Set the SHA256 hash of an image
+func SetSHA256(id int64, sha256 string) (err error) {
+	_, err = sq.Update("images").
+		Set("sha256", sha256).
+		Where("id = ?", id).
+		Exec()
+	return
+}
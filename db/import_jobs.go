@@ -0,0 +1,260 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/bakape/hydron/common"
+)
+
+// Create a new import job owned by owner with pending paths and return its ID.
+// Duplicate paths are ignored.
+func CreateImportJob(j common.ImportJob, paths []string, owner string) (
+	id int64, err error,
+) {
+	err = InTransaction(func(tx *sql.Tx) (err error) {
+		now := time.Now().Unix()
+		id, err = getLastID(tx, sq.
+			Insert("import_jobs").
+			Columns("created", "del", "fetch_tags", "store_name",
+				"archive_tags", "link", "tags", "owner", "heartbeat").
+			Values(now, j.Delete, j.FetchTags, j.StoreName, j.ArchiveTags,
+				j.Link, j.Tags, owner, now),
+		)
+		if err != nil {
+			return
+		}
+		for _, p := range paths {
+			_, err = sq.Insert("import_job_paths").
+				Columns("job_id", "path").
+				Values(id, p).
+				Suffix("on conflict (job_id, path) do nothing").
+				RunWith(tx).
+				Exec()
+			if err != nil {
+				return
+			}
+		}
+		return
+	})
+	return
+}
+
+func selectImportJobs() squirrel.SelectBuilder {
+	return sq.Select(
+		"id", "created", "finished", "del", "fetch_tags", "store_name",
+		"archive_tags", "link", "tags",
+	).
+		From("import_jobs").
+		OrderBy("id")
+}
+
+// Read import jobs matched by q and the counts of their paths by status
+func scanImportJobs(q squirrel.SelectBuilder) (
+	jobs []common.ImportJob, err error,
+) {
+	r, err := q.Query()
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	for r.Next() {
+		var j common.ImportJob
+		err = r.Scan(&j.ID, &j.Created, &j.Finished, &j.Delete, &j.FetchTags,
+			&j.StoreName, &j.ArchiveTags, &j.Link, &j.Tags)
+		if err != nil {
+			return
+		}
+		jobs = append(jobs, j)
+	}
+	err = r.Err()
+	if err != nil {
+		return
+	}
+	r.Close()
+
+	for i := range jobs {
+		err = readImportCounts(&jobs[i])
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func readImportCounts(j *common.ImportJob) (err error) {
+	r, err := sq.Select("status", "count(*)").
+		From("import_job_paths").
+		Where("job_id = ?", j.ID).
+		GroupBy("status").
+		Query()
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	for r.Next() {
+		var (
+			s common.ImportStatus
+			n int
+		)
+		err = r.Scan(&s, &n)
+		if err != nil {
+			return
+		}
+		j.Counts.Add(s, n)
+	}
+	return r.Err()
+}
+
+// Retrieve an import job with the paths, that failed to import
+func GetImportJob(id int64) (j common.ImportJob, err error) {
+	jobs, err := scanImportJobs(selectImportJobs().Where("id = ?", id))
+	if err != nil {
+		return
+	}
+	if len(jobs) == 0 {
+		err = sql.ErrNoRows
+		return
+	}
+	j = jobs[0]
+
+	r, err := sq.Select("path", "status", "error").
+		From("import_job_paths").
+		Where("job_id = ? and status in (?, ?)", id,
+			common.ImportUnsupported, common.ImportError).
+		OrderBy("path").
+		Query()
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	for r.Next() {
+		var p common.ImportJobPath
+		err = r.Scan(&p.Path, &p.Status, &p.Error)
+		if err != nil {
+			return
+		}
+		j.Failed = append(j.Failed, p)
+	}
+	err = r.Err()
+	return
+}
+
+// Retrieve all import jobs
+func GetImportJobs() ([]common.ImportJob, error) {
+	return scanImportJobs(selectImportJobs())
+}
+
+// Retrieve all unfinished import jobs, whose heartbeat has not been updated
+// since before
+func GetStaleImportJobs(before time.Time) ([]common.ImportJob, error) {
+	return scanImportJobs(selectImportJobs().
+		Where("finished = 0 and heartbeat < ?", before.Unix()))
+}
+
+// Take over an unfinished import job, whose heartbeat has not been updated
+// since before. Returns false, if the job has finished or was claimed
+// concurrently.
+func ClaimImportJob(id int64, owner string, before time.Time) (
+	claimed bool, err error,
+) {
+	err = InTransaction(func(tx *sql.Tx) (err error) {
+		var heartbeat int64
+		err = sq.Select("heartbeat").
+			From("import_jobs").
+			Where("id = ? and finished = 0", id).
+			RunWith(tx).
+			QueryRow().
+			Scan(&heartbeat)
+		switch {
+		case err == sql.ErrNoRows:
+			return nil
+		case err != nil:
+			return
+		case heartbeat >= before.Unix():
+			return
+		}
+
+		// Only succeeds, if no other process claimed the job since reading
+		// the heartbeat
+		res, err := sq.Update("import_jobs").
+			SetMap(map[string]interface{}{
+				"owner":     owner,
+				"heartbeat": time.Now().Unix(),
+			}).
+			Where("id = ? and heartbeat = ?", id, heartbeat).
+			RunWith(tx).
+			Exec()
+		if err != nil {
+			return
+		}
+		n, err := res.RowsAffected()
+		claimed = n != 0
+		return
+	})
+	return
+}
+
+// Signal, that the import job is still being run by owner.
+// Returns false, if the job has been claimed by another owner.
+func UpdateImportJobHeartbeat(id int64, owner string) (owned bool, err error) {
+	res, err := sq.Update("import_jobs").
+		Set("heartbeat", time.Now().Unix()).
+		Where("id = ? and owner = ?", id, owner).
+		Exec()
+	if err != nil {
+		return
+	}
+	n, err := res.RowsAffected()
+	owned = n != 0
+	return
+}
+
+// Return the paths of an import job, that have not been imported yet
+func GetPendingImportPaths(jobID int64) (paths []string, err error) {
+	r, err := sq.Select("path").
+		From("import_job_paths").
+		Where("job_id = ? and status = ?", jobID, common.ImportPending).
+		OrderBy("path").
+		Query()
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	for r.Next() {
+		var p string
+		err = r.Scan(&p)
+		if err != nil {
+			return
+		}
+		paths = append(paths, p)
+	}
+	err = r.Err()
+	return
+}
+
+// Record the result of importing a path of an import job
+func SetImportStatus(jobID int64, p common.ImportJobPath) (err error) {
+	_, err = sq.Update("import_job_paths").
+		SetMap(map[string]interface{}{
+			"status": p.Status,
+			"error":  p.Error,
+		}).
+		Where("job_id = ? and path = ?", jobID, p.Path).
+		Exec()
+	return
+}
+
+// Mark an import job as finished
+func FinishImportJob(id int64) (err error) {
+	_, err = sq.Update("import_jobs").
+		Set("finished", time.Now().Unix()).
+		Where("id = ?", id).
+		Exec()
+	return
+}
@@ -0,0 +1,100 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/bakape/hydron/common"
+)
+
+// Replace the embedded metadata and date taken of an image
+func SetMetadata(id int64, taken int64, fields []common.MetadataField) error {
+	return InTransaction(func(tx *sql.Tx) error {
+		return setMetadataTx(tx, id, taken, fields)
+	})
+}
+
+func setMetadataTx(tx *sql.Tx, id int64, taken int64,
+	fields []common.MetadataField,
+) (err error) {
+	var t interface{}
+	if taken != 0 {
+		t = taken
+	}
+	_, err = sq.Update("images").
+		Set("taken", t).
+		Where("id = ?", id).
+		RunWith(tx).
+		Exec()
+	if err != nil {
+		return
+	}
+	_, err = sq.Delete("image_metadata").
+		Where("image_id = ?", id).
+		RunWith(tx).
+		Exec()
+	if err != nil {
+		return
+	}
+	for _, f := range fields {
+		_, err = sq.Insert("image_metadata").
+			Columns("image_id", "field", "val").
+			Values(id, f.Key, f.Value).
+			RunWith(tx).
+			Exec()
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Retrieve the embedded metadata fields of an image
+func GetMetadata(id int64) (fields []common.MetadataField, err error) {
+	r, err := sq.Select("field", "val").
+		From("image_metadata").
+		Where("image_id = ?", id).
+		OrderBy("field", "val").
+		Query()
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	for r.Next() {
+		var f common.MetadataField
+		err = r.Scan(&f.Key, &f.Value)
+		if err != nil {
+			return
+		}
+		fields = append(fields, f)
+	}
+	err = r.Err()
+	return
+}
+
+// Return the IDs and types of all images of the passed types
+func GetImagesOfTypes(types []common.FileType) (
+	images []common.CompactImage, err error,
+) {
+	r, err := sq.Select("id", "sha1", "type").
+		From("images").
+		Where(squirrel.Eq{"type": types}).
+		OrderBy("id").
+		Query()
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	for r.Next() {
+		var img common.CompactImage
+		err = r.Scan(&img.ID, &img.SHA1, &img.Type)
+		if err != nil {
+			return
+		}
+		images = append(images, img)
+	}
+	err = r.Err()
+	return
+}
@@ -4,6 +4,13 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bakape/hydron/common"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
 )
 
 var version = len(migrations)
@@ -87,6 +94,225 @@ var migrations = []func(*sql.Tx) error{
 		}
 		return
 	},
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`create table tag_batches (
+				id `+autoIncrement+`,
+				actor text not null,
+				time bigint not null
+			)`,
+			`create table tag_history (
+				id `+autoIncrement+`,
+				batch_id int not null
+					references tag_batches on delete cascade,
+				image_id int not null references images on delete cascade,
+				tag_id int not null references tags,
+				source smallint not null,
+				added boolean not null,
+				reverted boolean not null default false,
+				time bigint not null
+			)`,
+			`create index i_tag_history_image on tag_history(image_id)`,
+			`create index i_tag_history_batch on tag_history(batch_id)`,
+		)
+	},
+	func(tx *sql.Tx) (err error) {
+		// Number of image_tags rows referencing a tag. Kept up to date with
+		// triggers to rank tag autocompletion suggestions.
+		err = execAll(tx,
+			`alter table tags add column use_count int not null default 0`,
+			`update tags set use_count = (
+				select count(*)
+				from image_tags
+				where tag_id = tags.id
+			)`,
+			`create index i_tag_use_count on tags(use_count)`,
+		)
+		if err != nil {
+			return
+		}
+		switch driver {
+		case "postgres":
+			return execAll(tx,
+				`create or replace function update_tag_use_count()
+				returns trigger as $$
+				begin
+					if TG_OP = 'INSERT' then
+						update tags set use_count = use_count + 1
+							where id = new.tag_id;
+						return new;
+					else
+						update tags set use_count = use_count - 1
+							where id = old.tag_id;
+						return old;
+					end if;
+				end;
+				$$ language plpgsql`,
+				`create trigger t_image_tags_use_count
+				after insert or delete on image_tags
+				for each row execute procedure update_tag_use_count()`,
+			)
+		default:
+			return execAll(tx,
+				`create trigger t_image_tags_insert
+				after insert on image_tags
+				begin
+					update tags set use_count = use_count + 1
+						where id = new.tag_id;
+				end`,
+				`create trigger t_image_tags_delete
+				after delete on image_tags
+				begin
+					update tags set use_count = use_count - 1
+						where id = old.tag_id;
+				end`,
+			)
+		}
+	},
+	func(tx *sql.Tx) (err error) {
+		// Other DBMS use an in-process trigram index for fuzzy tag matching
+		if driver != "postgres" {
+			return
+		}
+		return execAll(tx,
+			`create extension if not exists pg_trgm`,
+			`create index i_tags_trgm on tags using gin (tag gin_trgm_ops)`,
+		)
+	},
+	renormalizeTags,
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`create table tag_fetches (
+				image_id int not null primary key
+					references images on delete cascade,
+				time bigint not null,
+				found boolean not null
+			)`,
+			`create index i_tag_fetches_time on tag_fetches(time)`,
+		)
+	},
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`create table sources (
+				image_id int not null references images on delete cascade,
+				url text not null,
+				kind smallint not null,
+				time bigint not null
+			)`,
+			`create unique index i_sources_image_url on sources(image_id, url)`,
+		)
+	},
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`create table subscriptions (
+				id `+autoIncrement+`,
+				fetcher text not null,
+				query text not null,
+				sync_interval bigint not null,
+				cursor bigint not null default 0,
+				last_sync bigint not null default 0,
+				error text not null default '',
+				imported bigint not null default 0
+			)`,
+		)
+	},
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`create table hydrus_imports (
+				sha256 text not null primary key,
+				image_id int not null references images on delete cascade
+			)`,
+		)
+	},
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`alter table images add column sha256 text`,
+			`create index i_images_sha256 on images(sha256)`,
+			`update images
+			set sha256 = (
+				select h.sha256
+				from hydrus_imports as h
+				where h.image_id = images.id
+			)`,
+		)
+	},
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`create table import_jobs (
+				id `+autoIncrement+`,
+				created bigint not null,
+				finished bigint not null default 0,
+				del boolean not null,
+				fetch_tags boolean not null,
+				store_name boolean not null,
+				tags text not null
+			)`,
+			`create table import_job_paths (
+				job_id int not null references import_jobs on delete cascade,
+				path text not null,
+				status smallint not null default 0,
+				error text not null default ''
+			)`,
+			`create unique index i_import_job_paths_job_path
+				on import_job_paths(job_id, path)`,
+			`create index i_import_job_paths_status
+				on import_job_paths(job_id, status)`,
+		)
+	},
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`alter table import_jobs
+				add column archive_tags boolean not null default false`,
+			`create table archive_members (
+				image_id int not null references images on delete cascade,
+				archive text not null,
+				name text not null,
+				member text not null,
+				position int not null
+			)`,
+			`create unique index i_archive_members_archive_member
+				on archive_members(archive, member)`,
+			`create index i_archive_members_name on archive_members(name)`,
+			`create index i_archive_members_image_id
+				on archive_members(image_id)`,
+		)
+	},
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`alter table images add column taken bigint`,
+			`create index i_images_taken on images(taken)`,
+			`create table image_metadata (
+				image_id int not null references images on delete cascade,
+				field text not null,
+				val text not null
+			)`,
+			`create index i_image_metadata_image_id
+				on image_metadata(image_id)`,
+			`create index i_image_metadata_field on image_metadata(field)`,
+		)
+	},
+	func(tx *sql.Tx) (err error) {
+		_, err = tx.Exec(
+			`alter table import_jobs
+			add column link boolean not null default false`,
+		)
+		return
+	},
+	func(tx *sql.Tx) (err error) {
+		_, err = tx.Exec(
+			`alter table images
+			add column preview boolean not null default false`,
+		)
+		return
+	},
+	func(tx *sql.Tx) (err error) {
+		return execAll(tx,
+			`alter table import_jobs
+				add column owner text not null default ''`,
+			`alter table import_jobs
+				add column heartbeat bigint not null default 0`,
+		)
+	},
 }
 
 // Run migrations from version `from`to version `to`
@@ -131,6 +357,161 @@ func runMigrations(from, to int) (err error) {
 	return
 }
 
+// Apply the tag normalization introduced with this migration to all stored
+// tags and merge tags, that become identical
+func renormalizeTags(tx *sql.Tx) (err error) {
+	type row struct {
+		id  int64
+		tag string
+	}
+
+	groups := make(map[common.TagBase][]row)
+	r, err := tx.Query(`select id, type, tag from tags order by id`)
+	if err != nil {
+		return
+	}
+	for r.Next() {
+		var (
+			rw  row
+			typ common.TagType
+		)
+		err = r.Scan(&rw.id, &typ, &rw.tag)
+		if err != nil {
+			r.Close()
+			return
+		}
+		key := common.TagBase{
+			Type: typ,
+			Tag:  normalizeTagV1(rw.tag),
+		}
+		groups[key] = append(groups[key], rw)
+	}
+	err = r.Err()
+	r.Close()
+	if err != nil {
+		return
+	}
+
+	for key, rows := range groups {
+		if len(rows) == 1 && rows[0].tag == key.Tag {
+			continue
+		}
+
+		if key.Tag == "" {
+			// Nothing left of these tags
+			for _, rw := range rows {
+				for _, q := range [...]string{
+					`delete from image_tags where tag_id = ?`,
+					`delete from tag_history where tag_id = ?`,
+					`delete from tags where id = ?`,
+				} {
+					err = execRaw(tx, q, rw.id)
+					if err != nil {
+						return
+					}
+				}
+			}
+			continue
+		}
+
+		// Prefer merging into a tag, that is already normalized
+		canonical := rows[0]
+		for _, rw := range rows {
+			if rw.tag == key.Tag {
+				canonical = rw
+				break
+			}
+		}
+
+		for _, rw := range rows {
+			if rw.id == canonical.id {
+				continue
+			}
+			// Drop duplicates, that would be created by the merge
+			err = execRaw(tx,
+				`delete from image_tags
+				where tag_id = ?
+					and exists (
+						select 1
+						from image_tags as it
+						where it.tag_id = ?
+							and it.image_id = image_tags.image_id
+							and it.source = image_tags.source
+					)`,
+				rw.id, canonical.id,
+			)
+			if err != nil {
+				return
+			}
+			for _, q := range [...]string{
+				`update image_tags set tag_id = ? where tag_id = ?`,
+				`update tag_history set tag_id = ? where tag_id = ?`,
+			} {
+				err = execRaw(tx, q, canonical.id, rw.id)
+				if err != nil {
+					return
+				}
+			}
+			err = execRaw(tx, `delete from tags where id = ?`, rw.id)
+			if err != nil {
+				return
+			}
+		}
+
+		err = execRaw(tx,
+			`update tags
+			set tag = ?,
+				use_count = (
+					select count(*)
+					from image_tags
+					where tag_id = tags.id
+				)
+			where id = ?`,
+			key.Tag, canonical.id,
+		)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Copy of tags.NormalizeString at the time of the renormalizeTags migration.
+// Must not be changed, so the migration always produces the same result.
+func normalizeTagV1(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r != '\x00' && !unicode.IsSpace(r) &&
+			(unicode.Is(unicode.Cc, r) || unicode.Is(unicode.Cf, r)) {
+			return -1
+		}
+		return r
+	}, s)
+
+	ascii := true
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			ascii = false
+			break
+		}
+	}
+	if !ascii {
+		s = norm.NFKC.String(cases.Fold().String(norm.NFKC.String(s)))
+	}
+
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\x00' || unicode.IsSpace(r):
+			return '_'
+		case r == '"':
+			return '\''
+		case 'A' <= r && r <= 'Z':
+			return r + 'a' - 'A'
+		default:
+			return r
+		}
+	}, s)
+}
+
 func rollBack(tx *sql.Tx, err error) error {
 	if rbErr := tx.Rollback(); rbErr != nil {
 		err = fmt.Errorf("%s: %s", err.Error(), rbErr)
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/bakape/hydron/common"
+)
+
+// Add source URLs to an image. Adding an existing URL is a NOP.
+func AddSources(imageID int64, sources ...common.Source) error {
+	now := time.Now().Unix()
+	return InTransaction(func(tx *sql.Tx) (err error) {
+		for _, s := range sources {
+			_, err = sq.Insert("sources").
+				Columns("image_id", "url", "kind", "time").
+				Values(imageID, s.URL, s.Kind, now).
+				Suffix("on conflict (image_id, url) do nothing").
+				RunWith(tx).
+				Exec()
+			if err != nil {
+				return
+			}
+		}
+		return
+	})
+}
+
+// Retrieve all source URLs of an image in the order they were added
+func GetSources(imageID int64) (sources []common.Source, err error) {
+	r, err := sq.Select("url", "kind", "time").
+		From("sources").
+		Where("image_id = ?", imageID).
+		OrderBy("time", "url").
+		Query()
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	var s common.Source
+	for r.Next() {
+		err = r.Scan(&s.URL, &s.Kind, &s.Time)
+		if err != nil {
+			return
+		}
+		sources = append(sources, s)
+	}
+	err = r.Err()
+	return
+}
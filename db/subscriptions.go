@@ -0,0 +1,117 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/bakape/hydron/common"
+)
+
+func selectSubscriptions() squirrel.SelectBuilder {
+	return sq.Select(
+		"id", "fetcher", "query", "sync_interval", "cursor", "last_sync",
+		"error", "imported",
+	).
+		From("subscriptions").
+		OrderBy("id")
+}
+
+func scanSubscriptions(q squirrel.SelectBuilder) (
+	subs []common.Subscription, err error,
+) {
+	r, err := q.Query()
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	var s common.Subscription
+	for r.Next() {
+		err = r.Scan(&s.ID, &s.Fetcher, &s.Query, &s.Interval, &s.Cursor,
+			&s.LastSync, &s.Error, &s.Imported)
+		if err != nil {
+			return
+		}
+		subs = append(subs, s)
+	}
+	err = r.Err()
+	return
+}
+
+// Add a new subscription and return its ID
+func AddSubscription(s common.Subscription) (id int64, err error) {
+	err = InTransaction(func(tx *sql.Tx) (err error) {
+		id, err = getLastID(tx, sq.
+			Insert("subscriptions").
+			Columns("fetcher", "query", "sync_interval").
+			Values(s.Fetcher, s.Query, s.Interval))
+		return
+	})
+	return
+}
+
+// Remove a subscription by ID.
+// Returns sql.ErrNoRows, if no such subscription exists.
+func RemoveSubscription(id int64) (err error) {
+	res, err := sq.Delete("subscriptions").Where("id = ?", id).Exec()
+	if err != nil {
+		return
+	}
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		err = sql.ErrNoRows
+	}
+	return
+}
+
+// Retrieve a subscription by ID
+func GetSubscription(id int64) (s common.Subscription, err error) {
+	subs, err := scanSubscriptions(selectSubscriptions().Where("id = ?", id))
+	switch {
+	case err != nil:
+	case len(subs) == 0:
+		err = sql.ErrNoRows
+	default:
+		s = subs[0]
+	}
+	return
+}
+
+// Retrieve all subscriptions
+func GetSubscriptions() ([]common.Subscription, error) {
+	return scanSubscriptions(selectSubscriptions())
+}
+
+// Retrieve all subscriptions, that are due for syncing at time t
+func GetDueSubscriptions(t time.Time) ([]common.Subscription, error) {
+	return scanSubscriptions(selectSubscriptions().
+		Where("last_sync + sync_interval <= ?", t.Unix()))
+}
+
+// Set the ID of the newest imported post of a subscription and add to the
+// number of imported files
+func SetSubscriptionCursor(id int64, cursor uint64, imported int) (
+	err error,
+) {
+	_, err = sq.Update("subscriptions").
+		Set("cursor", cursor).
+		Set("imported", squirrel.Expr("imported + ?", imported)).
+		Where("id = ?", id).
+		Exec()
+	return
+}
+
+// Record a subscription sync and the error it failed with, if any
+func SetSubscriptionSynced(id int64, syncErr error) (err error) {
+	var s string
+	if syncErr != nil {
+		s = syncErr.Error()
+	}
+	_, err = sq.Update("subscriptions").
+		Set("last_sync", time.Now().Unix()).
+		Set("error", s).
+		Where("id = ?", id).
+		Exec()
+	return
+}
@@ -11,17 +11,26 @@ import (
 	"github.com/bakape/hydron/common"
 )
 
-// A little different from tags/filters.go
-var systemRegex = regexp.MustCompile(`^([\w_]+)((=|>|>=|<|<=)(\w+)?)?$`)
+// Maximum number of tag suggestions returned by CompleteTag
+const maxSuggestions = 20
+
+var (
+	// A little different from tags/filters.go
+	systemRegex = regexp.MustCompile(`^([\w_]+)((=|>|>=|<|<=)([\w-]+)?)?$`)
+
+	// Escapes LIKE pattern special characters
+	likeEscaper = strings.NewReplacer("$", "$$", "_", "$_", "%", "$%")
+)
 
 /*
 Add tags to an image. All tags must be of same TagSource.
+b: batch to record the changes in
 imageID: internal ID of image
 tags: tags to add
 */
-func AddTags(imageID int64, tags []common.Tag) error {
+func AddTags(b Batch, imageID int64, tags []common.Tag) error {
 	return InTransaction(func(tx *sql.Tx) (err error) {
-		return AddTagsTx(tx, imageID, tags)
+		return AddTagsTx(tx, b, imageID, tags)
 	})
 }
 
@@ -29,11 +38,36 @@ func AddTags(imageID int64, tags []common.Tag) error {
 Add tags to an image in an exisiting transaction. All tags must be of same
 TagSource.
 tx: transaction to use
+b: batch to record the changes in
 imageID: internal ID of image
 tags: tags to add
 */
-func AddTagsTx(tx *sql.Tx, imageID int64, tags []common.Tag) (
+func AddTagsTx(tx *sql.Tx, b Batch, imageID int64, tags []common.Tag) (
 	err error,
+) {
+	inserted, err := insertImageTags(tx, imageID, tags)
+	if err != nil {
+		return
+	}
+	for _, t := range inserted {
+		err = recordTagChange(tx, b, imageID, t.id, t.source, true)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Tag added to an image by insertImageTags
+type insertedTag struct {
+	id     int64
+	source common.TagSource
+}
+
+// Add tags to an image without recording the changes and return the tags
+// actually added. Tags the image already has from the same source are skipped.
+func insertImageTags(tx *sql.Tx, imageID int64, tags []common.Tag) (
+	inserted []insertedTag, err error,
 ) {
 	if driver == "postgres" {
 		// Because SERIAL tag IDs can collide
@@ -42,7 +76,11 @@ func AddTagsTx(tx *sql.Tx, imageID int64, tags []common.Tag) (
 			return
 		}
 	}
-	var tagID int64
+	inserted = make([]insertedTag, 0, len(tags))
+	var (
+		tagID  int64
+		exists bool
+	)
 	for _, t := range tags {
 		err = selectTagID().
 			Where("tag = ? and type = ?", t.Tag, int(t.Type)).
@@ -64,6 +102,23 @@ func AddTagsTx(tx *sql.Tx, imageID int64, tags []common.Tag) (
 			return
 		}
 
+		err = sq.Select("count(*) != 0").
+			From("image_tags").
+			Where(squirrel.Eq{
+				"image_id": imageID,
+				"tag_id":   tagID,
+				"source":   t.Source,
+			}).
+			RunWith(tx).
+			QueryRow().
+			Scan(&exists)
+		if err != nil {
+			return
+		}
+		if exists {
+			continue
+		}
+
 		_, err = sq.
 			Insert("image_tags").
 			Columns("image_id", "tag_id", "source").
@@ -73,30 +128,58 @@ func AddTagsTx(tx *sql.Tx, imageID int64, tags []common.Tag) (
 		if err != nil {
 			return
 		}
+		inserted = append(inserted, insertedTag{tagID, t.Source})
 	}
 
 	return
 }
 
 // Remove specific tags from an image
-func RemoveTags(imageID int64, tags []common.Tag) error {
+func RemoveTags(b Batch, imageID int64, tags []common.Tag) error {
 	return InTransaction(func(tx *sql.Tx) (err error) {
+		var (
+			tagID int64
+			res   sql.Result
+			n     int64
+		)
 		for _, t := range tags {
-			_, err = sq.
+			err = selectTagID().
+				Where("tag = ? and type = ?", t.Tag, int(t.Type)).
+				RunWith(tx).
+				QueryRow().
+				Scan(&tagID)
+			switch err {
+			case nil:
+			case sql.ErrNoRows:
+				err = nil
+				continue
+			default:
+				return
+			}
+
+			res, err = sq.
 				Delete("image_tags").
-				Where(
-					`image_id = ?
-						and source = ?
-						and tag_id = (
-							select id from tags
-							where tag = ? and type = ?)`,
-					imageID, t.Source, t.Tag, t.Type,
-				).
+				Where(squirrel.Eq{
+					"image_id": imageID,
+					"source":   t.Source,
+					"tag_id":   tagID,
+				}).
 				RunWith(tx).
 				Exec()
 			if err != nil {
 				return
 			}
+			n, err = res.RowsAffected()
+			if err != nil {
+				return
+			}
+			if n == 0 {
+				continue
+			}
+			err = recordTagChange(tx, b, imageID, tagID, t.Source, false)
+			if err != nil {
+				return
+			}
 		}
 		return
 	})
@@ -106,7 +189,7 @@ func RemoveTags(imageID int64, tags []common.Tag) error {
 func matchPost(
 	s string, pre string, i int, postfixes []string,
 ) (
-	matches []string, err error,
+	matches []common.TagSuggestion, err error,
 ) {
 	re, err := regexp.Compile(`^` + regexp.QuoteMeta(s[i:]) + `.*`)
 	if err != nil {
@@ -115,17 +198,21 @@ func matchPost(
 	for _, p := range postfixes {
 		matched := re.FindString(p)
 		if len(matched) != 0 {
-			matches = append(matches, pre+s[:i]+matched)
+			matches = append(matches, common.TagSuggestion{
+				Tag: pre + s[:i] + matched,
+			})
 		}
 	}
 	return
 }
 
-// Attempt to complete a tag by suggesting up to 20 possible tags for a prefix
-func CompleteTag(s string) (tags []string, err error) {
-	const maxCap = 20
-	tags = make([]string, 0, maxCap)
+// Attempt to complete a tag by suggesting up to 20 possible tags for a prefix.
+// Tags starting with the prefix are suggested first, followed by tags
+// containing it. Both are ranked by the number of images using the tag.
+func CompleteTag(s string) (tags []common.TagSuggestion, err error) {
+	tags = make([]common.TagSuggestion, 0, maxSuggestions)
 	typeQ := ""
+	typ := -1
 	prefix := ""
 	if s[0] == '-' {
 		prefix = "-"
@@ -140,6 +227,7 @@ func CompleteTag(s string) (tags []string, err error) {
 			prefix += s[:i]
 			s = s[i:]
 			typeQ = fmt.Sprintf(" and type = %d", t)
+			typ = int(t)
 		}
 
 		switch s[:i-1] {
@@ -163,10 +251,11 @@ func CompleteTag(s string) (tags []string, err error) {
 			if m != nil {
 				if m[3] != "" {
 					switch m[1] {
-					case "size", "width", "height", "duration", "tag_count":
+					case "size", "width", "height", "duration", "tag_count",
+						"taken":
 						// If we have a valid tag but nothing to autocomplete,
 						// still return it to show it's valid
-						tags = []string{prefix + s}
+						tags = []common.TagSuggestion{{Tag: prefix + s}}
 					case "type":
 						if m[3] != "=" {
 							return
@@ -179,7 +268,9 @@ func CompleteTag(s string) (tags []string, err error) {
 						for _, ext := range common.Extensions {
 							matched := re.FindString(ext)
 							if matched != "" {
-								tags = append(tags, prefix+s[:i]+m[1]+m[3]+matched)
+								tags = append(tags, common.TagSuggestion{
+									Tag: prefix + s[:i] + m[1] + m[3] + matched,
+								})
 							}
 						}
 					}
@@ -189,12 +280,22 @@ func CompleteTag(s string) (tags []string, err error) {
 			}
 			tags, err = matchPost(substr, prefix+s[:i], 0, []string{
 				"size", "width", "height", "duration", "tag_count", "type",
+				"taken",
 			})
 			return
-		case "md5", "sha1", "name":
+		case "md5", "sha1", "name", "source_url":
 			// If we have a valid tag but nothing to autocomplete,
 			// still return it to show it's valid
-			tags = []string{prefix + s}
+			tags = []common.TagSuggestion{{Tag: prefix + s}}
+		case "exif":
+			if strings.IndexByte(s[i:], '=') != -1 {
+				tags = []common.TagSuggestion{{Tag: prefix + s}}
+				return
+			}
+			tags, err = matchPost(s, prefix, i, []string{
+				"make", "model", "orientation", "keyword",
+			})
+			return
 		case "order":
 			if prefix != "" {
 				// This tag category doesn't work with the "-" prefix
@@ -206,7 +307,7 @@ func CompleteTag(s string) (tags []string, err error) {
 			}
 			tags, err = matchPost(s, prefix, i, []string{
 				"size", "width", "height", "duration",
-				"tag_count", "random"})
+				"tag_count", "random", "taken"})
 			return
 		case "limit":
 			if prefix != "" {
@@ -221,7 +322,7 @@ func CompleteTag(s string) (tags []string, err error) {
 					return
 				}
 			}
-			tags = []string{s}
+			tags = []common.TagSuggestion{{Tag: s}}
 			return
 		default:
 			// Continue as regular tag
@@ -231,7 +332,7 @@ func CompleteTag(s string) (tags []string, err error) {
 		categories := []string{
 			"undefined", "artist", "author", "character",
 			"copyright", "series", "meta", "rating", "system",
-			"md5", "sha1", "name",
+			"md5", "sha1", "name", "source_url", "exif",
 		}
 		// These categories don't work with a prefix of "-"
 		if prefix == "" {
@@ -246,44 +347,164 @@ func CompleteTag(s string) (tags []string, err error) {
 		for _, t := range categories {
 			matched := re.FindString(t)
 			if matched != "" {
-				tags = append(tags, prefix+matched+":")
+				tags = append(tags, common.TagSuggestion{
+					Tag: prefix + matched + ":",
+				})
 			}
 		}
 	}
 
-	r, err := sq.Select("tag").
-		Distinct().
+	// Prefix matches
+	nonDB := len(tags)
+	tags, err = appendTagSuggestions(tags, prefix, typeQ,
+		"tag like ? || '%' escape '$'", escapeLike(s))
+	if err != nil || len(tags) == maxSuggestions {
+		return
+	}
+
+	// Substring matches, that are not prefix matches
+	tags, err = appendTagSuggestions(tags, prefix, typeQ,
+		`tag like '%' || ? || '%' escape '$'
+			and tag not like ? || '%' escape '$'`,
+		escapeLike(s), escapeLike(s))
+	if err != nil || len(tags) != nonDB || s == "" {
+		return
+	}
+
+	// Fall back to typo-tolerant matching
+	fuzzy, err := fuzzyMatchTags(s, typ, maxSuggestions-len(tags))
+	if err != nil {
+		return
+	}
+	for _, t := range fuzzy {
+		t.Tag = prefix + t.Tag
+		tags = append(tags, t)
+	}
+	return
+}
+
+/*
+Suggest corrections for positive tag filters, that do not match any existing
+tag.
+Returns nil, if all tags exist or no correction could be found.
+*/
+func SuggestFilterCorrections(filters []common.TagFilter) (
+	corrected []common.TagFilter, err error,
+) {
+	for i, f := range filters {
+		if f.Negative {
+			continue
+		}
+
+		// Undefined tag type matches any tag type in SearchImages
+		q := sq.Select("count(*) != 0").
+			From("tags").
+			Where("tag = ?", f.Tag)
+		typ := -1
+		if f.Type != common.Undefined {
+			q = q.Where("type = ?", f.Type)
+			typ = int(f.Type)
+		}
+		var exists bool
+		err = q.QueryRow().Scan(&exists)
+		if err != nil {
+			return
+		}
+		if exists {
+			continue
+		}
+
+		var fuzzy []common.TagSuggestion
+		fuzzy, err = fuzzyMatchTags(f.Tag, typ, 1)
+		if err != nil {
+			return
+		}
+		if len(fuzzy) == 0 {
+			continue
+		}
+		if corrected == nil {
+			corrected = append([]common.TagFilter(nil), filters...)
+		}
+		corrected[i].Tag = fuzzy[0].Tag
+	}
+	return
+}
+
+// Escape special characters of a string used in a LIKE pattern with escape
+// character '$'
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// Append tags matching the where clause ranked by usage to the suggestions
+// until maxSuggestions is reached
+func appendTagSuggestions(
+	tags []common.TagSuggestion, prefix, typeQ, where string,
+	args ...interface{},
+) (
+	[]common.TagSuggestion, error,
+) {
+	r, err := sq.Select("tag", "sum(use_count)").
 		From("tags").
-		Where("tag like ? || '%' escape '$'"+typeQ, // Escape underscores
-			strings.Replace(s, "_", "$_", -1)).
-		OrderBy("tag").
-		Limit(maxCap).
+		Where(where+typeQ, args...).
+		GroupBy("tag").
+		OrderBy("sum(use_count) desc", "tag").
+		Limit(uint64(maxSuggestions - len(tags))).
 		Query()
 	if err != nil {
-		return
+		return tags, err
 	}
 	defer r.Close()
 
-	var tag string
+	var t common.TagSuggestion
 	for r.Next() {
-		err = r.Scan(&tag)
+		err = r.Scan(&t.Tag, &t.Count)
 		if err != nil {
-			return
+			return tags, err
 		}
-		tags = append(tags, prefix+tag)
-		if len(tags) == maxCap {
+		t.Tag = prefix + t.Tag
+		tags = append(tags, t)
+		if len(tags) == maxSuggestions {
 			break
 		}
 	}
-	err = r.Err()
-	return
+	return tags, r.Err()
 }
 
 // Update tags for a given image and TagSource.
 // All tags must be of same TagSource.
-func UpdateTags(imageID int64, tags []common.Tag, source common.TagSource,
+// Only the difference between the old and new tags is recorded in b.
+func UpdateTags(b Batch, imageID int64, tags []common.Tag,
+	source common.TagSource,
 ) error {
 	return InTransaction(func(tx *sql.Tx) (err error) {
+		old := make(map[int64]bool)
+		r, err := sq.Select("tag_id").
+			From("image_tags").
+			Where(squirrel.Eq{
+				"image_id": imageID,
+				"source":   source,
+			}).
+			RunWith(tx).
+			Query()
+		if err != nil {
+			return
+		}
+		var id int64
+		for r.Next() {
+			err = r.Scan(&id)
+			if err != nil {
+				r.Close()
+				return
+			}
+			old[id] = true
+		}
+		err = r.Err()
+		r.Close()
+		if err != nil {
+			return
+		}
+
 		// Remove old tags
 		_, err = sq.
 			Delete("image_tags").
@@ -297,7 +518,27 @@ func UpdateTags(imageID int64, tags []common.Tag, source common.TagSource,
 			return
 		}
 
-		err = AddTagsTx(tx, imageID, tags)
+		inserted, err := insertImageTags(tx, imageID, tags)
+		if err != nil {
+			return
+		}
+		for _, t := range inserted {
+			id := t.id
+			if old[id] {
+				delete(old, id)
+				continue
+			}
+			err = recordTagChange(tx, b, imageID, id, source, true)
+			if err != nil {
+				return
+			}
+		}
+		for id := range old {
+			err = recordTagChange(tx, b, imageID, id, source, false)
+			if err != nil {
+				return
+			}
+		}
 		return
 	})
 }
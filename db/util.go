@@ -16,6 +16,18 @@ func execAll(tx *sql.Tx, q ...string) error {
 	return nil
 }
 
+// Execute a raw SQL statement with ? placeholders in a transaction
+func execRaw(tx *sql.Tx, q string, args ...interface{}) (err error) {
+	if driver == "postgres" {
+		q, err = squirrel.Dollar.ReplacePlaceholders(q)
+		if err != nil {
+			return
+		}
+	}
+	_, err = tx.Exec(q, args...)
+	return
+}
+
 // Runs function inside a transaction and handles comminting and rollback on
 // error
 func InTransaction(fn func(*sql.Tx) error) (err error) {
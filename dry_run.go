@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+
+	"github.com/bakape/hydron/common"
+	"github.com/bakape/hydron/files"
+	imp "github.com/bakape/hydron/import"
+	"github.com/bakape/hydron/sidecar"
+	"github.com/bakape/hydron/tags"
+	"github.com/bakape/hydron/util"
+)
+
+// Outcome of importing a path, determined without importing it
+type dryRunStatus uint8
+
+const (
+	dryRunNew dryRunStatus = iota
+	// Already imported into the database
+	dryRunImported
+	// Same file as a previous path of the import
+	dryRunDuplicate
+	dryRunUnsupported
+	dryRunUnreadable
+	// URLs are not downloaded in dry runs
+	dryRunURL
+)
+
+var dryRunStatusStr = [...]string{
+	"new", "imported", "duplicate", "unsupported", "unreadable", "url",
+}
+
+func (s dryRunStatus) String() string {
+	return dryRunStatusStr[int(s)]
+}
+
+func (s dryRunStatus) MarshalJSON() ([]byte, error) {
+	return strconv.AppendQuote(nil, s.String()), nil
+}
+
+// Outcome of importing a single path
+type dryRunResult struct {
+	Path   string       `json:"path"`
+	Status dryRunStatus `json:"status"`
+	// SHA1 of the file or the already imported file
+	SHA1 string `json:"sha1,omitempty"`
+	Size int    `json:"size,omitempty"`
+	// Tags added by the import, path rules, archive names and sidecar files
+	Tags  string `json:"tags,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Report of what importing a set of paths would do
+type dryRunReport struct {
+	Results []dryRunResult `json:"results"`
+	// Number of results per status
+	Counts map[string]int `json:"counts"`
+	// Total size of new files in bytes
+	NewBytes int64 `json:"new_bytes"`
+}
+
+/*
+Traverse, hash and detect the types of files to import without writing
+anything.
+archiveTags: report the tags of archive members
+tagStr: string of tags to add to all imported files
+*/
+func dryRunImport(paths []string, archiveTags bool, tagStr string) (
+	rep dryRunReport, err error,
+) {
+	paths, err = traverseImportPaths(paths)
+	if err != nil {
+		return
+	}
+
+	// Inspect files in parallel
+	type indexed struct {
+		i   int
+		res dryRunResult
+	}
+	index := make(map[string]int, len(paths))
+	for i, p := range paths {
+		index[p] = i
+	}
+	tasks := make(chan importTask)
+	go sendImportTasks(paths, tasks)
+	ch := make(chan indexed)
+	n := runtime.NumCPU() + 1
+	for i := 0; i < n; i++ {
+		go func() {
+			for t := range tasks {
+				ch <- indexed{index[t.path], inspectPath(t, archiveTags,
+					tagStr)}
+			}
+		}()
+	}
+
+	rep.Results = make([]dryRunResult, len(paths))
+	for range paths {
+		r := <-ch
+		rep.Results[r.i] = r.res
+	}
+
+	// Only count the first of identical files as new, in import order
+	rep.Counts = make(map[string]int, len(dryRunStatusStr))
+	seen := make(map[string]bool)
+	for i := range rep.Results {
+		r := &rep.Results[i]
+		if r.Status == dryRunNew {
+			if seen[r.SHA1] {
+				r.Status = dryRunDuplicate
+			} else {
+				seen[r.SHA1] = true
+				rep.NewBytes += int64(r.Size)
+			}
+		}
+		rep.Counts[r.Status.String()]++
+	}
+	return
+}
+
+// Determine the outcome of importing a single file, archive member or URL
+func inspectPath(task importTask, archiveTags bool, tagStr string) (
+	res dryRunResult,
+) {
+	res.Path = task.path
+	if util.IsFetchable(task.path) {
+		res.Status = dryRunURL
+		return
+	}
+
+	t := tags.FromString(tags.AddPathTags(task.path, tagStr), common.User)
+	var (
+		f   io.ReadSeeker
+		err = task.err
+	)
+	if task.isMember {
+		defer task.member.Close()
+		f = task.member
+		if archiveTags {
+			t = append(t, archiveTag(
+				tags.NormalizeString(files.ArchiveName(task.archive))))
+		}
+	} else if err == nil {
+		var file *os.File
+		file, err = os.Open(task.path)
+		if err == nil {
+			defer file.Close()
+			f = file
+
+			// Files with broken sidecar files are imported without their
+			// metadata
+			meta, _, metaErr := sidecar.Read(task.path)
+			if metaErr != nil {
+				stderr.Printf("sidecar: %s\n", metaErr)
+			} else {
+				t = append(t, meta.Tags...)
+			}
+		}
+	}
+	if err != nil {
+		res.Status = dryRunUnreadable
+		res.Error = err.Error()
+		return
+	}
+
+	res.SHA1, res.Size, _, err = imp.Inspect(f)
+	switch err {
+	case nil:
+		res.Status = dryRunNew
+		res.Tags = formatTags(dedupTagBases(t))
+	case imp.ErrImported:
+		res.Status = dryRunImported
+		res.Tags = formatTags(dedupTagBases(t))
+	case imp.ErrUnsupportedFile:
+		res.Status = dryRunUnsupported
+	default:
+		res.Status = dryRunUnreadable
+		res.Error = err.Error()
+	}
+	return
+}
+
+// Remove tags with the same type and name as a previous tag
+func dedupTagBases(ts []common.Tag) []common.Tag {
+	out := ts[:0]
+	seen := make(map[common.TagBase]bool, len(ts))
+	for _, t := range ts {
+		if !seen[t.TagBase] {
+			seen[t.TagBase] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// Print a dry run report of importing paths
+func printDryRun(paths []string, archiveTags bool, tagStr string) error {
+	rep, err := dryRunImport(paths, archiveTags, tagStr)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range rep.Results {
+		switch r.Status {
+		case dryRunNew:
+			fmt.Printf("%s\t%s\t%d\t%s\n", r.Status, r.Path, r.Size, r.Tags)
+		case dryRunImported, dryRunDuplicate:
+			fmt.Printf("%s\t%s\t%s\t%s\n", r.Status, r.Path, r.SHA1, r.Tags)
+		case dryRunUnreadable:
+			fmt.Printf("%s\t%s\t%s\n", r.Status, r.Path, r.Error)
+		default:
+			fmt.Printf("%s\t%s\n", r.Status, r.Path)
+		}
+	}
+	fmt.Printf("\n%d files, %d new bytes:", len(rep.Results), rep.NewBytes)
+	for _, s := range dryRunStatusStr {
+		fmt.Printf(" %d %s", rep.Counts[s], s)
+	}
+	fmt.Print("\n")
+	return nil
+}
@@ -1,15 +1,37 @@
 package main
 
 import (
+	"errors"
+	"time"
+
 	"github.com/bakape/boorufetch"
-	"github.com/bakape/hydron/common"
 	"github.com/bakape/hydron/db"
 	"github.com/bakape/hydron/fetch"
+	"github.com/bakape/hydron/tags"
 )
 
-// Fetch and update tags for all stored images
-func fetchAllTags() error {
-	all, err := db.GetDanbooruTaggable()
+/*
+Fetch and update tags for all stored images.
+fetcher: name of fetcher to use. Uses all configured fetchers, if empty.
+since: skip images tags were fetched for within this duration
+retryMissing: retry images no post was found for
+*/
+func fetchAllTags(fetcher string, since time.Duration, retryMissing bool,
+) error {
+	fs, err := fetch.Select(fetcher)
+	if err != nil {
+		return err
+	}
+
+	all, err := db.GetTaggable(
+		fs.FileTypes(),
+		time.Now().Add(-since),
+		retryMissing,
+	)
+	if err != nil {
+		return err
+	}
+	b, err := db.NewBatch(db.ActorFetch)
 	if err != nil {
 		return err
 	}
@@ -25,13 +47,7 @@ func fetchAllTags() error {
 	for i := 0; i < boorufetch.FetcherCount; i++ {
 		go func() {
 			for img := range passAll {
-				tags, err := fetch.FetchTags(img.MD5)
-				if err != nil {
-					goto end
-				}
-				err = db.UpdateTags(img.ID, tags, common.Danbooru)
-			end:
-				ch <- err
+				ch <- fetchImageTags(fs, b, img)
 			}
 		}()
 	}
@@ -54,3 +70,65 @@ func fetchAllTags() error {
 
 	return nil
 }
+
+// Fetch and update tags and sources for a stored image and record the fetch,
+// so reruns can skip it
+func fetchImageTags(fs fetch.Fetchers, b db.Batch, img db.IDAndMD5) (
+	err error,
+) {
+	post, err := fs.FetchPost(img.MD5, img.Type)
+	if err != nil {
+		return
+	}
+	if post != nil {
+		err = db.UpdateTags(b, img.ID, post.Tags, post.Source)
+		if err != nil {
+			return
+		}
+		err = db.AddSources(img.ID, post.Sources()...)
+		if err != nil {
+			return
+		}
+	}
+	return db.SetFetchState(img.ID, post != nil)
+}
+
+// Apply fetched tag rules to all stored tags fetched from boorus
+func applyTagRules() error {
+	if !tags.HasRules() {
+		return errors.New("no tag rules configured in tag_rules.json")
+	}
+	b, err := db.NewBatch(db.ActorRules)
+	if err != nil {
+		return err
+	}
+
+	for _, src := range fetch.Sources() {
+		ids, err := db.GetImagesWithTagsFrom(src)
+		if err != nil {
+			return err
+		}
+
+		p := progressLogger{
+			header: "applying tag rules to " + src.String(),
+			total:  len(ids),
+		}
+		for _, id := range ids {
+			err = func() (err error) {
+				old, err := db.GetImageTagsFrom(id, src)
+				if err != nil {
+					return
+				}
+				return db.UpdateTags(b, id, tags.ApplyRules(old), src)
+			}()
+			if err != nil {
+				p.Err(err)
+			} else {
+				p.Done()
+			}
+		}
+		p.Close()
+	}
+
+	return nil
+}
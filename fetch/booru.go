@@ -0,0 +1,158 @@
+package fetch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bakape/hydron/common"
+)
+
+// Retry limits of throttled or failed requests
+const (
+	maxRetries     = 5
+	initialBackoff = time.Second
+	maxBackoff     = time.Minute
+)
+
+// File types boorus host by default
+var booruFileTypes = []common.FileType{
+	common.JPEG, common.PNG, common.GIF, common.WEBM,
+}
+
+// Common implementation of booru-based fetchers
+type booru struct {
+	conf      Config
+	fileTypes map[common.FileType]bool
+	client    http.Client
+	limiter   *tokenBucket
+}
+
+// Initialize booru from c. Zero values in c are replaced with defaults.
+func (b *booru) init(c Config, defaultURL string, defaultRate float64) (
+	err error,
+) {
+	if c.URL == "" {
+		c.URL = defaultURL
+	}
+	c.URL = strings.TrimSuffix(c.URL, "/")
+	if _, err = url.Parse(c.URL); err != nil {
+		return
+	}
+	if c.Rate == 0 {
+		c.Rate = defaultRate
+	}
+	if c.Rate < 0 {
+		return errors.New("rate must be positive")
+	}
+	if c.Burst == 0 {
+		c.Burst = 1
+	}
+	if c.Burst < 0 {
+		return errors.New("burst must be positive")
+	}
+	b.conf = c
+	b.limiter = newTokenBucket(c.Rate, c.Burst)
+	b.client.Timeout = 30 * time.Second
+
+	types := booruFileTypes
+	if c.FileTypes != nil {
+		types, err = parseFileTypes(c.FileTypes)
+		if err != nil {
+			return
+		}
+	}
+	b.fileTypes = make(map[common.FileType]bool, len(types))
+	for _, t := range types {
+		b.fileTypes[t] = true
+	}
+	return
+}
+
+func (b *booru) Name() string {
+	return b.conf.Name
+}
+
+func (b *booru) CanFetch(t common.FileType) bool {
+	return b.fileTypes[t]
+}
+
+/*
+Make a rate-limited GET request to the booru API and decode the JSON response
+into dst. Throttled and failed requests are retried with exponential backoff.
+Returns found=false, if the API responded with 404 or an empty body.
+path: path relative to the base URL
+q: query parameters
+*/
+func (b *booru) get(path string, q url.Values, dst interface{}) (
+	found bool, err error,
+) {
+	u := b.conf.URL + path + "?" + q.Encode()
+	backoff := initialBackoff
+	var res *http.Response
+	for i := 0; ; i++ {
+		b.limiter.take()
+		res, err = b.client.Get(u)
+		var retryAfter time.Duration
+		if err == nil {
+			switch res.StatusCode {
+			case 200, 404:
+			case 429, 500, 502, 503, 504:
+				// Don't leak credentials in the query into logs
+				err = fmt.Errorf("GET %s%s returned status code %d",
+					b.conf.URL, path, res.StatusCode)
+				retryAfter = parseRetryAfter(res.Header.Get("Retry-After"))
+				res.Body.Close()
+			default:
+				res.Body.Close()
+				err = fmt.Errorf("GET %s%s returned status code %d",
+					b.conf.URL, path, res.StatusCode)
+				return
+			}
+		}
+		if err == nil {
+			break
+		}
+		if i == maxRetries {
+			return
+		}
+
+		if retryAfter > backoff {
+			backoff = retryAfter
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 404 {
+		return
+	}
+
+	err = json.NewDecoder(res.Body).Decode(dst)
+	switch err {
+	case nil:
+		found = true
+	case io.EOF:
+		err = nil
+	}
+	return
+}
+
+// Parse the delay in seconds of a Retry-After header.
+// Returns 0, if not set or not in seconds.
+func parseRetryAfter(s string) time.Duration {
+	sec, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(sec) * time.Second
+}
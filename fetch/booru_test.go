@@ -0,0 +1,102 @@
+package fetch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// Create a booru querying a test server running handler
+func newTestBooru(t *testing.T, h http.Handler) *booru {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	b := new(booru)
+	err := b.init(Config{URL: srv.URL, Rate: 1000}, "", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestBooruGetRetry(t *testing.T) {
+	requests := 0
+	b := newTestBooru(t, http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if requests == 1 {
+				w.WriteHeader(503)
+				return
+			}
+			w.Write([]byte(`{"id":1}`))
+		},
+	))
+
+	var res struct {
+		ID int
+	}
+	found, err := b.get("/posts.json", url.Values{}, &res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found || res.ID != 1 {
+		t.Fatalf("unexpected result: found=%t id=%d", found, res.ID)
+	}
+	if requests != 2 {
+		t.Fatalf("expected 2 requests, got %d", requests)
+	}
+}
+
+func TestBooruGetClientError(t *testing.T) {
+	requests := 0
+	b := newTestBooru(t, http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.WriteHeader(403)
+		},
+	))
+
+	var res struct{}
+	_, err := b.get("/posts.json", url.Values{"api_key": {"secret"}}, &res)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if requests != 1 {
+		t.Fatalf("client error retried %d times", requests-1)
+	}
+	if strings.Contains(err.Error(), "secret") {
+		t.Fatalf("credentials leaked into error: %s", err)
+	}
+}
+
+func TestBooruInit(t *testing.T) {
+	cases := [...]struct {
+		name string
+		conf Config
+		err  bool
+	}{
+		{"defaults", Config{}, false},
+		{"negative rate", Config{Rate: -1}, true},
+		{"negative burst", Config{Burst: -1}, true},
+		{"file types", Config{FileTypes: []string{"jpg", "png"}}, false},
+		{"unknown file type", Config{FileTypes: []string{"foo"}}, true},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			var b booru
+			err := b.init(c.conf, "https://example.com/", 2)
+			if (err != nil) != c.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err != nil {
+				return
+			}
+			if b.conf.URL != "https://example.com" {
+				t.Fatalf("unexpected URL: %s", b.conf.URL)
+			}
+		})
+	}
+}
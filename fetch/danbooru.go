@@ -0,0 +1,127 @@
+package fetch
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bakape/boorufetch"
+	"github.com/bakape/hydron/common"
+	"github.com/bakape/hydron/tags"
+)
+
+func init() {
+	RegisterBackend("danbooru", newDanbooru)
+}
+
+// Fetches tags from Danbooru and other boorus running its software
+type danbooru struct {
+	booru
+}
+
+type danbooruPost struct {
+	ID        uint64
+	MD5       string
+	FileURL   string `json:"file_url"`
+	Source    string
+	Rating    boorufetch.Rating
+	General   string `json:"tag_string_general"`
+	Artist    string `json:"tag_string_artist"`
+	Character string `json:"tag_string_character"`
+	Copyright string `json:"tag_string_copyright"`
+	Meta      string `json:"tag_string_meta"`
+}
+
+func newDanbooru(c Config) (TagFetcher, error) {
+	d := new(danbooru)
+	return d, d.init(c, "https://danbooru.donmai.us", 10)
+}
+
+func (d *danbooru) Source() common.TagSource {
+	return common.Danbooru
+}
+
+// Return query parameters with credentials set, if any
+func (d *danbooru) query(q url.Values) url.Values {
+	if d.conf.Login != "" {
+		q.Set("login", d.conf.Login)
+		q.Set("api_key", d.conf.APIKey)
+	}
+	return q
+}
+
+func (d *danbooru) FetchPost(md5 string) (p *Post, err error) {
+	var post danbooruPost
+	found, err := d.get("/posts.json", d.query(url.Values{"md5": {md5}}),
+		&post)
+	if err != nil || !found {
+		return
+	}
+	return d.convert(post), nil
+}
+
+func (d *danbooru) SearchPosts(query string, after uint64, limit int) (
+	posts []*Post, err error,
+) {
+	q := url.Values{
+		"tags":  {query},
+		"limit": {strconv.Itoa(limit)},
+	}
+	if after != 0 {
+		q.Set("page", "a"+strconv.FormatUint(after, 10))
+	}
+	var res []danbooruPost
+	_, err = d.get("/posts.json", d.query(q), &res)
+	if err != nil {
+		return
+	}
+
+	posts = make([]*Post, len(res))
+	for i, p := range res {
+		posts[i] = d.convert(p)
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		return posts[i].ID < posts[j].ID
+	})
+	return
+}
+
+// Convert a decoded post to the common format
+func (d *danbooru) convert(post danbooruPost) *Post {
+	out := make([]common.Tag, 0, 64)
+	for _, group := range [...]struct {
+		tags string
+		typ  common.TagType
+	}{
+		{post.General, common.Undefined},
+		{post.Artist, common.Author},
+		{post.Character, common.Character},
+		{post.Copyright, common.Series},
+		{post.Meta, common.Meta},
+	} {
+		for _, s := range strings.Fields(group.tags) {
+			t := tags.Normalize(s, common.Danbooru)
+			if t.Tag == "" {
+				continue
+			}
+			t.Type = group.typ
+			out = append(out, t)
+		}
+	}
+	out = append(out, common.Tag{
+		TagBase: common.TagBase{
+			Type: common.Rating,
+			Tag:  post.Rating.String(),
+		},
+		Source: common.Danbooru,
+	})
+	return &Post{
+		Tags:        out,
+		ID:          post.ID,
+		MD5:         post.MD5,
+		FileURL:     post.FileURL,
+		URL:         d.conf.URL + "/posts/" + strconv.FormatUint(post.ID, 10),
+		OriginalURL: post.Source,
+	}
+}
@@ -1,21 +1,39 @@
 package fetch
 
 import (
+	"fmt"
 	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 )
 
+// Maximum size of a file to download for importing
+var MaxDownloadSize int64 = 1 << 30
+
+// Client shared by all file downloads. Files can be large, so the entire
+// download has a generous timeout, while connecting and receiving the response
+// headers must be quick.
+var fileClient = http.Client{
+	Timeout: 10 * time.Minute,
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+		DisableCompression:    true,
+	},
+}
+
 // Fetch a file over network
 func FetchFile(url string) (rc io.ReadCloser, err error) {
-	cl := http.Client{
-		Transport: &http.Transport{
-			MaxIdleConns:       10,
-			IdleConnTimeout:    30 * time.Second,
-			DisableCompression: true,
-		},
-	}
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return
@@ -25,9 +43,55 @@ func FetchFile(url string) (rc io.ReadCloser, err error) {
 		req.Header.Set("Referer", "https://www.pixiv.net")
 	}
 
-	res, err := cl.Do(req)
+	res, err := fileClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
+	if res.StatusCode != 200 {
+		res.Body.Close()
+		return nil, fmt.Errorf("GET %s returned status code %d",
+			url, res.StatusCode)
+	}
 	return res.Body, nil
 }
+
+// Download a file over network into a temporary file. The caller is
+// responsible for closing and removing the file.
+// Returns an error, if the file exceeds MaxDownloadSize.
+func DownloadFile(url string) (f *os.File, err error) {
+	rc, err := FetchFile(url)
+	if err != nil {
+		return
+	}
+	defer rc.Close()
+	return BufferFile(rc)
+}
+
+// Buffer a stream into a temporary file. The caller is responsible for
+// closing and removing the file.
+// Returns an error, if the stream exceeds MaxDownloadSize.
+func BufferFile(r io.Reader) (f *os.File, err error) {
+	f, err = ioutil.TempFile("", "hydron-")
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			f = nil
+		}
+	}()
+
+	n, err := io.Copy(f, io.LimitReader(r, MaxDownloadSize+1))
+	if err != nil {
+		return
+	}
+	if n > MaxDownloadSize {
+		err = fmt.Errorf("file exceeds maximum size of %d bytes",
+			MaxDownloadSize)
+		return
+	}
+	_, err = f.Seek(0, 0)
+	return
+}
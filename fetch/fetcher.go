@@ -0,0 +1,341 @@
+package fetch
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/bakape/hydron/common"
+	"github.com/bakape/hydron/files"
+	"github.com/bakape/hydron/tags"
+)
+
+var (
+	// Registered fetcher backends by name
+	backends = make(map[string]Backend)
+
+	// Configured fetchers in order of preference
+	fetchers Fetchers
+
+	// Fetchers used, if none are configured
+	defaultConf = []Config{
+		{Backend: "danbooru"},
+	}
+
+	// Backends selected by name without being configured
+	unconfiguredMu sync.Mutex
+	unconfigured   = make(map[string]TagFetcher)
+)
+
+// Fetches tags for files from an external source like a booru
+type TagFetcher interface {
+	// Name of the fetcher as configured
+	Name() string
+
+	// Source to store the fetched tags under
+	Source() common.TagSource
+
+	// Return, if tags can possibly be fetched for files of type t
+	CanFetch(t common.FileType) bool
+
+	// Fetch the post of a file by its hex-encoded MD5 hash.
+	// Returns nil, if the source has no matching post.
+	FetchPost(md5 string) (*Post, error)
+}
+
+// TagFetcher, that can also search for posts by tag query
+type Searcher interface {
+	TagFetcher
+
+	// Search for up to limit posts matching the booru tag query with IDs
+	// greater than after in ascending ID order.
+	// Returns the newest matching posts, if after is 0.
+	SearchPosts(query string, after uint64, limit int) ([]*Post, error)
+}
+
+// Post matching a file fetched from a booru
+type Post struct {
+	Tags []common.Tag
+
+	// Source to store the tags under
+	Source common.TagSource
+
+	// ID of the post on the booru
+	ID uint64
+
+	// Hex-encoded MD5 hash and download URL of the file. The URL can be
+	// empty, if the file is restricted.
+	MD5, FileURL string
+
+	// URL of the post page
+	URL string
+
+	// Original source of the file listed on the post, if any
+	OriginalURL string
+}
+
+// Return source URLs of the file to store from the post
+func (p *Post) Sources() []common.Source {
+	s := make([]common.Source, 0, 2)
+	if p.URL != "" {
+		s = append(s, common.Source{
+			URL:  p.URL,
+			Kind: common.PostSource,
+		})
+	}
+	if p.OriginalURL != "" {
+		s = append(s, common.Source{
+			URL:  p.OriginalURL,
+			Kind: common.OriginalSource,
+		})
+	}
+	return s
+}
+
+// Fetcher configuration as read from the configuration file
+type Config struct {
+	// Name to select the fetcher by. Defaults to the backend name.
+	Name string
+
+	// Registered backend implementing the fetcher
+	Backend string
+
+	// Base URL of the API. Defaults to the backend's public instance.
+	URL string
+
+	// Optional credentials
+	Login  string
+	APIKey string `json:"api_key"`
+
+	// Maximum requests per second. Defaults to the backend's limit.
+	Rate float64
+
+	// Maximum number of requests in a burst. Defaults to 1.
+	Burst int
+
+	// File type extensions to fetch tags for. Defaults to the backend's
+	// supported types.
+	FileTypes []string `json:"file_types"`
+}
+
+// Constructs a TagFetcher from its configuration
+type Backend func(Config) (TagFetcher, error)
+
+// Register a fetcher backend under name to be selectable in the
+// configuration file
+func RegisterBackend(name string, b Backend) {
+	backends[name] = b
+}
+
+// Load tag fetchers from the configuration file or use the defaults, if none
+// configured
+func LoadFetchers() (err error) {
+	var conf []Config
+	err = files.ReadConfig("tag_fetchers.json", &conf)
+	if err != nil {
+		return
+	}
+	if len(conf) == 0 {
+		conf = defaultConf
+	}
+
+	fetchers = make(Fetchers, 0, len(conf))
+	names := make(map[string]bool, len(conf))
+	for i, c := range conf {
+		if c.Name == "" {
+			c.Name = c.Backend
+		}
+		if names[c.Name] {
+			return fmt.Errorf(
+				"tag_fetchers.json: fetcher %d: duplicate name: %s",
+				i, c.Name)
+		}
+		names[c.Name] = true
+
+		b, ok := backends[c.Backend]
+		if !ok {
+			return fmt.Errorf(
+				"tag_fetchers.json: fetcher %d: unknown backend: %s",
+				i, c.Backend)
+		}
+		var f TagFetcher
+		f, err = b(c)
+		if err != nil {
+			return fmt.Errorf("tag_fetchers.json: fetcher %d: %s", i, err)
+		}
+		fetchers = append(fetchers, f)
+	}
+	return
+}
+
+// Ordered list of tag fetchers. Each is tried in order, until one finds a
+// matching post.
+type Fetchers []TagFetcher
+
+// Select the fetcher with name or all configured fetchers, if name is empty.
+// Registered backends, that are not configured, can be selected by their
+// backend name.
+func Select(name string) (Fetchers, error) {
+	if name == "" {
+		return fetchers, nil
+	}
+	f, err := get(name)
+	if err != nil {
+		return nil, err
+	}
+	return Fetchers{f}, nil
+}
+
+// Return the configured fetcher with name. If none is configured, the
+// registered backend with name is created with its default configuration.
+func get(name string) (TagFetcher, error) {
+	for _, f := range fetchers {
+		if f.Name() == name {
+			return f, nil
+		}
+	}
+
+	unconfiguredMu.Lock()
+	defer unconfiguredMu.Unlock()
+	if f, ok := unconfigured[name]; ok {
+		return f, nil
+	}
+	b, ok := backends[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown tag fetcher: %s", name)
+	}
+	f, err := b(Config{
+		Name:    name,
+		Backend: name,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("tag fetcher %s: %s", name, err)
+	}
+	// Reuse the fetcher, so its rate limit applies to all requests
+	unconfigured[name] = f
+	return f, nil
+}
+
+// Return distinct sources of all configured fetchers
+func Sources() []common.TagSource {
+	seen := make(map[common.TagSource]bool, len(fetchers))
+	sources := make([]common.TagSource, 0, len(fetchers))
+	for _, f := range fetchers {
+		if s := f.Source(); !seen[s] {
+			seen[s] = true
+			sources = append(sources, s)
+		}
+	}
+	return sources
+}
+
+// Return, if any of the fetchers can possibly fetch tags for files of type t
+func (fs Fetchers) CanFetch(t common.FileType) bool {
+	for _, f := range fs {
+		if f.CanFetch(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Return all file types any of the fetchers can fetch tags for
+func (fs Fetchers) FileTypes() []common.FileType {
+	types := make([]common.FileType, 0, len(common.Extensions))
+	for t := range common.Extensions {
+		if fs.CanFetch(t) {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// Fetch the post of a file of type t by its hex-encoded MD5 hash from the
+// first fetcher, that has a matching post. Fetched tags have the configured
+// rules applied.
+// Returns nil, if no fetcher has a matching post.
+func (fs Fetchers) FetchPost(md5 string, t common.FileType) (
+	p *Post, err error,
+) {
+	for _, f := range fs {
+		if !f.CanFetch(t) {
+			continue
+		}
+		p, err = f.FetchPost(md5)
+		if err != nil {
+			err = fmt.Errorf("%s: %s", f.Name(), err)
+			return
+		}
+		if p != nil {
+			p.Source = f.Source()
+			p.Tags = tags.ApplyRules(p.Tags)
+			return
+		}
+	}
+	return
+}
+
+// Fetch the post of a file of type t by its hex-encoded MD5 hash from all
+// configured fetchers with fallback
+func FetchPost(md5 string, t common.FileType) (*Post, error) {
+	return fetchers.FetchPost(md5, t)
+}
+
+/*
+Search posts on a booru in ascending ID order. Fetched tags have the
+configured rules applied.
+fetcher: name of the configured fetcher to search with
+query: booru tag query
+after: only return posts with IDs greater than this or the newest posts, if 0
+limit: maximum number of posts to return
+*/
+func SearchPosts(fetcher, query string, after uint64, limit int) (
+	posts []*Post, err error,
+) {
+	f, err := GetSearcher(fetcher)
+	if err != nil {
+		return
+	}
+	posts, err = f.SearchPosts(query, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", fetcher, err)
+	}
+	for _, p := range posts {
+		p.Source = f.Source()
+		p.Tags = tags.ApplyRules(p.Tags)
+	}
+	return
+}
+
+// Return the fetcher with name, if it supports searching posts. Fetchers are
+// looked up the same way as by Select.
+func GetSearcher(name string) (s Searcher, err error) {
+	f, err := get(name)
+	if err != nil {
+		return
+	}
+	s, ok := f.(Searcher)
+	if !ok {
+		err = fmt.Errorf("tag fetcher does not support searching: %s", name)
+	}
+	return
+}
+
+// Return, if any configured fetcher can possibly fetch tags for files of
+// type t
+func CanFetchTags(t common.FileType) bool {
+	return fetchers.CanFetch(t)
+}
+
+// Parse file type extensions of a fetcher configuration
+func parseFileTypes(exts []string) (types []common.FileType, err error) {
+	types = make([]common.FileType, len(exts))
+	for i, e := range exts {
+		var ok bool
+		types[i], ok = common.RevExtensions[e]
+		if !ok {
+			return nil, errors.New("unknown file type: " + e)
+		}
+	}
+	return
+}
@@ -0,0 +1,260 @@
+package fetch
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bakape/hydron/common"
+	"github.com/bakape/hydron/files"
+)
+
+// Fetcher returning a fixed post
+type stubFetcher struct {
+	name   string
+	source common.TagSource
+	types  []common.FileType
+	post   *Post
+	err    error
+	calls  int
+}
+
+func (s *stubFetcher) Name() string {
+	return s.name
+}
+
+func (s *stubFetcher) Source() common.TagSource {
+	return s.source
+}
+
+func (s *stubFetcher) CanFetch(t common.FileType) bool {
+	for _, typ := range s.types {
+		if typ == t {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *stubFetcher) FetchPost(md5 string) (*Post, error) {
+	s.calls++
+	return s.post, s.err
+}
+
+func TestFetchersFetchPost(t *testing.T) {
+	png := &stubFetcher{
+		name:   "png",
+		source: common.Danbooru,
+		types:  []common.FileType{common.PNG},
+		post:   &Post{ID: 1},
+	}
+	missing := &stubFetcher{
+		name:   "missing",
+		source: common.Danbooru,
+		types:  []common.FileType{common.JPEG},
+	}
+	found := &stubFetcher{
+		name:   "found",
+		source: common.Gelbooru,
+		types:  []common.FileType{common.JPEG},
+		post:   &Post{ID: 2},
+	}
+	fs := Fetchers{png, missing, found}
+
+	p, err := fs.FetchPost(testMD5, common.JPEG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p == nil || p.ID != 2 {
+		t.Fatalf("unexpected post: %+v", p)
+	}
+	if p.Source != common.Gelbooru {
+		t.Fatalf("unexpected source: %s", p.Source)
+	}
+	if png.calls != 0 || missing.calls != 1 || found.calls != 1 {
+		t.Fatalf("unexpected calls: %d %d %d", png.calls, missing.calls,
+			found.calls)
+	}
+
+	p, err = fs.FetchPost(testMD5, common.GIF)
+	if err != nil || p != nil {
+		t.Fatalf("unexpected result for unsupported type: %+v %v", p, err)
+	}
+
+	// Errors stop the fallback
+	missing.err = errors.New("down")
+	_, err = fs.FetchPost(testMD5, common.JPEG)
+	if err == nil || err.Error() != "missing: down" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if found.calls != 1 {
+		t.Fatal("fell back after error")
+	}
+}
+
+func TestFetchersFileTypes(t *testing.T) {
+	fs := Fetchers{
+		&stubFetcher{types: []common.FileType{common.PNG}},
+		&stubFetcher{types: []common.FileType{common.JPEG, common.PNG}},
+	}
+	if !fs.CanFetch(common.JPEG) || fs.CanFetch(common.GIF) {
+		t.Fatal("unexpected CanFetch result")
+	}
+	types := fs.FileTypes()
+	if len(types) != 2 {
+		t.Fatalf("unexpected file types: %v", types)
+	}
+}
+
+// Write a fetcher configuration file to a temporary root directory and load
+// it
+func loadTestFetchers(t *testing.T, conf string) error {
+	t.Helper()
+	root := files.RootPath
+	old := fetchers
+	t.Cleanup(func() {
+		files.RootPath = root
+		fetchers = old
+	})
+	files.RootPath = t.TempDir()
+	if conf != "" {
+		err := ioutil.WriteFile(
+			filepath.Join(files.RootPath, "tag_fetchers.json"),
+			[]byte(conf),
+			0600,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return LoadFetchers()
+}
+
+func TestLoadFetchers(t *testing.T) {
+	err := loadTestFetchers(t, `[
+		{"backend": "gelbooru", "file_types": ["jpg"]},
+		{"name": "safebooru", "backend": "danbooru",
+			"url": "https://safebooru.donmai.us/"}
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, f := range fetchers {
+		names = append(names, f.Name())
+	}
+	if std := []string{"gelbooru", "safebooru"}; !reflect.DeepEqual(names,
+		std) {
+		t.Fatalf("expected fetchers %v, got %v", std, names)
+	}
+	if fetchers[0].CanFetch(common.PNG) || !fetchers[0].CanFetch(common.JPEG) {
+		t.Fatal("file types not applied")
+	}
+	std := []common.TagSource{common.Gelbooru, common.Danbooru}
+	if s := Sources(); !reflect.DeepEqual(s, std) {
+		t.Fatalf("expected sources %v, got %v", std, s)
+	}
+
+	fs, err := Select("safebooru")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fs) != 1 || fs[0] != fetchers[1] {
+		t.Fatalf("unexpected selection: %v", fs)
+	}
+	if _, err := Select("foo"); err == nil {
+		t.Fatal("selected unknown fetcher")
+	}
+	if _, err := GetSearcher("gelbooru"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadFetchersDefaults(t *testing.T) {
+	err := loadTestFetchers(t, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fetchers) != 1 || fetchers[0].Name() != "danbooru" {
+		t.Fatalf("unexpected default fetchers: %v", fetchers)
+	}
+}
+
+func TestSelectUnconfigured(t *testing.T) {
+	err := loadTestFetchers(t, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs, err := Select("gelbooru")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fs) != 1 || fs[0].Name() != "gelbooru" ||
+		fs[0].Source() != common.Gelbooru {
+		t.Fatalf("unexpected selection: %v", fs)
+	}
+	if !fs[0].CanFetch(common.JPEG) {
+		t.Fatal("default file types not applied")
+	}
+
+	// The same fetcher is reused, so it is rate limited across calls
+	again, err := Select("gelbooru")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again[0] != fs[0] {
+		t.Fatal("fetcher not reused")
+	}
+	if s, err := GetSearcher("gelbooru"); err != nil || s != fs[0] {
+		t.Fatalf("unexpected searcher: %v %v", s, err)
+	}
+
+	// Configured fetchers are not replaced
+	fs, err = Select("danbooru")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fs[0] != fetchers[0] {
+		t.Fatal("configured fetcher not selected")
+	}
+
+	if _, err := Select("foo"); err == nil {
+		t.Fatal("selected unknown backend")
+	}
+}
+
+func TestLoadFetchersInvalid(t *testing.T) {
+	cases := [...]struct {
+		name, conf, err string
+	}{
+		{
+			"duplicate name",
+			`[{"backend": "danbooru"}, {"backend": "danbooru"}]`,
+			"duplicate name",
+		},
+		{
+			"unknown backend",
+			`[{"backend": "foo"}]`,
+			"unknown backend",
+		},
+		{
+			"invalid rate",
+			`[{"backend": "gelbooru", "rate": -1}]`,
+			"rate must be positive",
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			err := loadTestFetchers(t, c.conf)
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Fatalf("expected error containing %q, got %v", c.err, err)
+			}
+		})
+	}
+}
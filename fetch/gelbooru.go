@@ -0,0 +1,200 @@
+package fetch
+
+import (
+	"html"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bakape/boorufetch"
+	"github.com/bakape/hydron/common"
+	"github.com/bakape/hydron/tags"
+)
+
+// Maximum number of tags to look up the types of per request
+const gelbooruTagChunk = 100
+
+// Gelbooru tag types
+const (
+	gelbooruGeneral = iota
+	gelbooruArtist
+	_
+	gelbooruCopyright
+	gelbooruCharacter
+	gelbooruMeta
+)
+
+func init() {
+	RegisterBackend("gelbooru", newGelbooru)
+}
+
+// Fetches tags from Gelbooru and other boorus running its software
+type gelbooru struct {
+	booru
+}
+
+type gelbooruPost struct {
+	ID      uint64
+	MD5     string
+	Hash    string // MD5 on older versions
+	FileURL string `json:"file_url"`
+	Source  string
+	Tags    string
+	Rating  boorufetch.Rating
+}
+
+type gelbooruTag struct {
+	Name string
+	Type int
+}
+
+func newGelbooru(c Config) (TagFetcher, error) {
+	g := new(gelbooru)
+	return g, g.init(c, "https://gelbooru.com", 2)
+}
+
+func (g *gelbooru) Source() common.TagSource {
+	return common.Gelbooru
+}
+
+func (g *gelbooru) FetchPost(md5 string) (p *Post, err error) {
+	posts, err := g.searchPosts(url.Values{
+		"tags":  {"md5:" + md5},
+		"limit": {"1"},
+	})
+	if err != nil || len(posts) == 0 {
+		return
+	}
+	return posts[0], nil
+}
+
+func (g *gelbooru) SearchPosts(query string, after uint64, limit int) (
+	posts []*Post, err error,
+) {
+	if after != 0 {
+		query += " id:>" + strconv.FormatUint(after, 10) + " sort:id:asc"
+	}
+	posts, err = g.searchPosts(url.Values{
+		"tags":  {query},
+		"limit": {strconv.Itoa(limit)},
+	})
+	sort.Slice(posts, func(i, j int) bool {
+		return posts[i].ID < posts[j].ID
+	})
+	return
+}
+
+// Search posts with query parameters q and convert them to the common format
+func (g *gelbooru) searchPosts(q url.Values) (posts []*Post, err error) {
+	var res struct {
+		Post []gelbooruPost
+	}
+	_, err = g.api("post", q, &res)
+	if err != nil || len(res.Post) == 0 {
+		return
+	}
+
+	// Look up the types of all tags of all posts at once
+	var names []string
+	types := make(map[string]int)
+	for _, p := range res.Post {
+		for _, n := range strings.Fields(html.UnescapeString(p.Tags)) {
+			if _, ok := types[n]; !ok {
+				types[n] = gelbooruGeneral
+				names = append(names, n)
+			}
+		}
+	}
+	for i := 0; i < len(names); i += gelbooruTagChunk {
+		j := i + gelbooruTagChunk
+		if j > len(names) {
+			j = len(names)
+		}
+		var res struct {
+			Tag []gelbooruTag
+		}
+		_, err = g.api("tag", url.Values{
+			"names": {strings.Join(names[i:j], " ")},
+			"limit": {strconv.Itoa(j - i)},
+		}, &res)
+		if err != nil {
+			return
+		}
+		for _, t := range res.Tag {
+			types[html.UnescapeString(t.Name)] = t.Type
+		}
+	}
+
+	posts = make([]*Post, len(res.Post))
+	for i, p := range res.Post {
+		posts[i] = g.convert(p, types)
+	}
+	return
+}
+
+// Convert a decoded post to the common format.
+// types: Gelbooru tag types by tag name
+func (g *gelbooru) convert(post gelbooruPost, types map[string]int) *Post {
+	names := strings.Fields(html.UnescapeString(post.Tags))
+	out := make([]common.Tag, 0, len(names)+1)
+	for _, name := range names {
+		t := tags.Normalize(name, common.Gelbooru)
+		if t.Tag == "" {
+			continue
+		}
+		switch types[name] {
+		case gelbooruArtist:
+			t.Type = common.Author
+		case gelbooruCopyright:
+			t.Type = common.Series
+		case gelbooruCharacter:
+			t.Type = common.Character
+		case gelbooruMeta:
+			t.Type = common.Meta
+		default:
+			t.Type = common.Undefined
+		}
+		out = append(out, t)
+	}
+	out = append(out, common.Tag{
+		TagBase: common.TagBase{
+			Type: common.Rating,
+			Tag:  post.Rating.String(),
+		},
+		Source: common.Gelbooru,
+	})
+
+	md5 := post.MD5
+	if md5 == "" {
+		md5 = post.Hash
+	}
+	return &Post{
+		Tags:    out,
+		ID:      post.ID,
+		MD5:     md5,
+		FileURL: post.FileURL,
+		URL: g.conf.URL + "/index.php?" + url.Values{
+			"page": {"post"},
+			"s":    {"view"},
+			"id":   {strconv.FormatUint(post.ID, 10)},
+		}.Encode(),
+		OriginalURL: post.Source,
+	}
+}
+
+// Query the Gelbooru JSON API for resources of type s and decode the
+// response into dst
+func (g *gelbooru) api(s string, q url.Values, dst interface{}) (
+	bool, error,
+) {
+	q.Set("page", "dapi")
+	q.Set("s", s)
+	q.Set("q", "index")
+	q.Set("json", "1")
+	if g.conf.Login != "" {
+		q.Set("user_id", g.conf.Login)
+		q.Set("api_key", g.conf.APIKey)
+	}
+	return g.get("/index.php", q, dst)
+}
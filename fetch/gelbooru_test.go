@@ -0,0 +1,204 @@
+package fetch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bakape/hydron/common"
+)
+
+const testMD5 = "0123456789abcdef0123456789abcdef"
+
+// Serve recorded Gelbooru API responses from testdata
+func gelbooruHandler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/index.php" || q.Get("page") != "dapi" ||
+			q.Get("q") != "index" || q.Get("json") != "1" {
+			t.Errorf("unexpected request: %s", r.URL)
+			http.NotFound(w, r)
+			return
+		}
+
+		var file string
+		switch q.Get("s") {
+		case "post":
+			switch tags := q.Get("tags"); {
+			case tags == "md5:"+testMD5:
+				file = "gelbooru_post.json"
+			case strings.HasPrefix(tags, "md5:"):
+				file = "gelbooru_empty.json"
+			default:
+				file = "gelbooru_search.json"
+			}
+		case "tag":
+			file = "gelbooru_tags.json"
+		default:
+			t.Errorf("unexpected resource: %s", q.Get("s"))
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		http.ServeFile(w, r, filepath.Join("testdata", file))
+	}
+}
+
+// Create a Gelbooru fetcher querying a test server running handler
+func newTestGelbooru(t *testing.T, h http.Handler) *gelbooru {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	f, err := newGelbooru(Config{
+		Name:  "gelbooru",
+		URL:   srv.URL,
+		Rate:  1000,
+		Burst: 100,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f.(*gelbooru)
+}
+
+func fetchedTag(typ common.TagType, tag string) common.Tag {
+	return common.Tag{
+		TagBase: common.TagBase{
+			Type: typ,
+			Tag:  tag,
+		},
+		Source: common.Gelbooru,
+	}
+}
+
+func TestGelbooruFetchPost(t *testing.T) {
+	g := newTestGelbooru(t, gelbooruHandler(t))
+
+	p, err := g.FetchPost(testMD5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p == nil {
+		t.Fatal("post not found")
+	}
+
+	std := &Post{
+		Tags: []common.Tag{
+			fetchedTag(common.Undefined, "1girl"),
+			fetchedTag(common.Character, "hatsune_miku"),
+			fetchedTag(common.Author, "jane_doe_(artist)"),
+			fetchedTag(common.Undefined, "long_hair"),
+			fetchedTag(common.Meta, "tagme"),
+			fetchedTag(common.Series, "vocaloid"),
+			fetchedTag(common.Undefined, "yuri's_ribbon"),
+			fetchedTag(common.Rating, "questionable"),
+		},
+		ID:  7654321,
+		MD5: testMD5,
+		FileURL: "https://img3.gelbooru.com/images/01/23/" + testMD5 +
+			".jpg",
+		URL: g.conf.URL +
+			"/index.php?id=7654321&page=post&s=view",
+		OriginalURL: "https://www.pixiv.net/artworks/96000000",
+	}
+	if !reflect.DeepEqual(p, std) {
+		t.Fatalf("\nexpected: %+v\ngot:      %+v", std, p)
+	}
+}
+
+func TestGelbooruNotFound(t *testing.T) {
+	cases := [...]struct {
+		name    string
+		handler http.Handler
+	}{
+		{"empty result", gelbooruHandler(t)},
+		{"404", http.NotFoundHandler()},
+		{
+			"empty body",
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			g := newTestGelbooru(t, c.handler)
+			p, err := g.FetchPost("ffffffffffffffffffffffffffffffff")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p != nil {
+				t.Fatalf("unexpected post: %+v", p)
+			}
+		})
+	}
+}
+
+func TestGelbooruSearchPosts(t *testing.T) {
+	var query string
+	h := gelbooruHandler(t)
+	g := newTestGelbooru(t, http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("s") == "post" {
+				query = r.URL.Query().Get("tags")
+			}
+			h(w, r)
+		},
+	))
+
+	posts, err := g.SearchPosts("long_hair", 7654320, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if std := "long_hair id:>7654320 sort:id:asc"; query != std {
+		t.Fatalf("expected query %q, got %q", std, query)
+	}
+
+	// Posts are sorted by ascending ID
+	var ids []uint64
+	for _, p := range posts {
+		ids = append(ids, p.ID)
+	}
+	if std := []uint64{7654321, 7654330}; !reflect.DeepEqual(ids, std) {
+		t.Fatalf("expected IDs %v, got %v", std, ids)
+	}
+	ratings := [...]string{"general", "explicit"}
+	for i, p := range posts {
+		last := p.Tags[len(p.Tags)-1]
+		if std := fetchedTag(common.Rating, ratings[i]); last != std {
+			t.Fatalf("post %d: expected rating %+v, got %+v", p.ID, std,
+				last)
+		}
+	}
+	if posts[1].FileURL != "" {
+		t.Fatal("restricted post has file URL")
+	}
+}
+
+func TestGelbooruCredentials(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			q := r.URL.Query()
+			if q.Get("user_id") != "123" || q.Get("api_key") != "secret" {
+				t.Errorf("missing credentials: %s", r.URL)
+			}
+			w.Write([]byte(`{"@attributes":{"count":0}}`))
+		},
+	))
+	defer srv.Close()
+	f, err := newGelbooru(Config{
+		URL:    srv.URL,
+		Login:  "123",
+		APIKey: "secret",
+		Rate:   1000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.FetchPost(testMD5)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
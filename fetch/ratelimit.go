@@ -0,0 +1,46 @@
+package fetch
+
+import (
+	"sync"
+	"time"
+)
+
+// Token bucket rate limiter
+type tokenBucket struct {
+	mu sync.Mutex
+	// Tokens added per second
+	rate float64
+	// Maximum number of tokens
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Take a token and block until it is available
+func (b *tokenBucket) take() {
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens--
+	var delay time.Duration
+	if b.tokens < 0 {
+		// Token is reserved and becomes available after delay
+		delay = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.mu.Unlock()
+
+	time.Sleep(delay)
+}
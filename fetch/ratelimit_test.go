@@ -0,0 +1,72 @@
+package fetch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	const rate = 50
+	b := newTokenBucket(rate, 2)
+
+	// The burst is available immediately
+	start := time.Now()
+	b.take()
+	b.take()
+	if d := time.Since(start); d > 10*time.Millisecond {
+		t.Fatalf("burst delayed by %s", d)
+	}
+
+	// Further tokens become available at the rate
+	start = time.Now()
+	for i := 0; i < 5; i++ {
+		b.take()
+	}
+	d := time.Since(start)
+	min := 5 * time.Second / rate
+	if d < min-5*time.Millisecond || d > 10*min {
+		t.Fatalf("expected 5 tokens to take about %s, took %s", min, d)
+	}
+}
+
+func TestTokenBucketConcurrent(t *testing.T) {
+	const rate = 100
+	b := newTokenBucket(rate, 1)
+	b.take()
+
+	start := time.Now()
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		go func() {
+			b.take()
+			done <- struct{}{}
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		<-done
+	}
+
+	// Concurrent callers reserve consecutive tokens
+	d := time.Since(start)
+	min := 4 * time.Second / rate
+	if d < min-5*time.Millisecond {
+		t.Fatalf("expected 4 tokens to take at least %s, took %s", min, d)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	cases := [...]struct {
+		in  string
+		out time.Duration
+	}{
+		{"", 0},
+		{"3", 3 * time.Second},
+		{"Wed, 21 Oct 2015 07:28:00 GMT", 0},
+		{"-1", 0},
+	}
+	for _, c := range cases {
+		if d := parseRetryAfter(c.in); d != c.out {
+			t.Errorf("%q: expected %s, got %s", c.in, c.out, d)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package files
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Separates the path of an archive from the name of a member inside it like
+// "/comics/vol1.cbz!/001.jpg"
+const ArchiveSep = "!/"
+
+// Maximum size of an archive member to read into memory for importing.
+// Larger members are decompressed into temporary files.
+var MaxArchiveMemberMemory int64 = 32 << 20
+
+// Supported archive extensions
+var archiveExts = [...]string{".zip", ".cbz", ".tar", ".tar.gz", ".tgz"}
+
+// Returns the extension of an archive path or "", if not an archive
+func archiveExt(p string) string {
+	lower := strings.ToLower(p)
+	for _, ext := range archiveExts {
+		if strings.HasSuffix(lower, ext) {
+			return ext
+		}
+	}
+	return ""
+}
+
+// Returns, if path points to a supported archive
+func IsArchive(path string) bool {
+	return archiveExt(path) != ""
+}
+
+// Return the path to a member of an archive
+func ArchiveMemberPath(archive, member string) string {
+	return archive + ArchiveSep + member
+}
+
+// Split a path to an archive member into the path to the archive and the name
+// of the member. ok is false, if p is not an archive member path.
+func SplitArchivePath(p string) (archive, member string, ok bool) {
+	for i := 0; ; {
+		j := strings.Index(p[i:], ArchiveSep)
+		if j == -1 {
+			return
+		}
+		i += j
+		if IsArchive(p[:i]) {
+			return p[:i], p[i+len(ArchiveSep):], true
+		}
+		i += len(ArchiveSep)
+	}
+}
+
+// Returns the name of an archive without directories and extension
+func ArchiveName(archive string) string {
+	name := path.Base(strings.Replace(archive, "\\", "/", -1))
+	return name[:len(name)-len(archiveExt(name))]
+}
+
+// List the names of all regular files in an archive in archive order
+func ListArchive(archive string) (members []string, err error) {
+	err = walkArchive(archive, func(name string, _ int64, _ io.Reader) error {
+		members = append(members, name)
+		return nil
+	})
+	return
+}
+
+// Member of an archive read for importing. Must be closed after use.
+type ArchiveMember struct {
+	io.ReadSeeker
+	// Uncompressed size of the member
+	Size int64
+	// Position among the regular files of the archive sorted by name, which
+	// is the order comic book readers display pages in
+	Position int
+	close    func() error
+}
+
+// Release the resources held by the member
+func (m ArchiveMember) Close() error {
+	if m.close == nil {
+		return nil
+	}
+	return m.close()
+}
+
+// Read the passed members of an archive in a single streaming pass in archive
+// order without extracting the archive. Only the names of the members are
+// read beforehand to determine their positions.
+// fn: called exactly once for each of the passed members with the read member
+// or the error, that prevented reading it. Must close the member.
+func ReadArchive(
+	archive string,
+	members []string,
+	fn func(member string, m ArchiveMember, err error),
+) {
+	names, err := ListArchive(archive)
+	if err != nil {
+		for _, member := range members {
+			fn(member, ArchiveMember{}, err)
+		}
+		return
+	}
+	sort.Strings(names)
+
+	pending := make(map[string]bool, len(members))
+	for _, member := range members {
+		pending[member] = true
+	}
+	err = walkArchive(archive, func(name string, size int64, r io.Reader) (
+		err error,
+	) {
+		if !pending[name] {
+			return
+		}
+		delete(pending, name)
+		m, err := readArchiveMember(r, size)
+		m.Position = sort.SearchStrings(names, name)
+		fn(name, m, err)
+		return nil
+	})
+
+	// Members missing from the archive or not reached because of an error
+	if err == nil {
+		err = os.ErrNotExist
+	}
+	for _, member := range members {
+		if pending[member] {
+			fn(member, ArchiveMember{}, err)
+		}
+	}
+}
+
+// Read an archive member of size bytes from r into memory or spill it to a
+// temporary file, if it is larger than MaxArchiveMemberMemory
+func readArchiveMember(r io.Reader, size int64) (m ArchiveMember, err error) {
+	m.Size = size
+	if size <= MaxArchiveMemberMemory {
+		var buf []byte
+		buf, err = ioutil.ReadAll(r)
+		if err != nil {
+			return
+		}
+		m.ReadSeeker = bytes.NewReader(buf)
+		m.Size = int64(len(buf))
+		return
+	}
+
+	tmp, err := ioutil.TempFile("", "hydron-archive-member-")
+	if err != nil {
+		return
+	}
+	m.close = func() error {
+		tmp.Close()
+		return os.Remove(tmp.Name())
+	}
+	m.Size, err = io.Copy(tmp, r)
+	if err == nil {
+		_, err = tmp.Seek(0, 0)
+	}
+	if err != nil {
+		m.Close()
+		m.close = nil
+		return
+	}
+	m.ReadSeeker = tmp
+	return
+}
+
+// Call fn for each regular file in an archive in archive order, until it
+// returns an error.
+// fn: receives the name, uncompressed size and contents of the member
+func walkArchive(
+	archive string,
+	fn func(name string, size int64, r io.Reader) error,
+) (err error) {
+	switch archiveExt(archive) {
+	case ".zip", ".cbz":
+		var zr *zip.ReadCloser
+		zr, err = zip.OpenReader(archive)
+		if err != nil {
+			return
+		}
+		defer zr.Close()
+
+		for _, f := range zr.File {
+			if !f.Mode().IsRegular() {
+				continue
+			}
+			var rc io.ReadCloser
+			rc, err = f.Open()
+			if err != nil {
+				return
+			}
+			err = fn(f.Name, int64(f.UncompressedSize64), rc)
+			rc.Close()
+			if err != nil {
+				return
+			}
+		}
+		return
+	default:
+		var f *os.File
+		f, err = os.Open(archive)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+
+		var r io.Reader = f
+		if archiveExt(archive) != ".tar" {
+			var gr *gzip.Reader
+			gr, err = gzip.NewReader(f)
+			if err != nil {
+				return
+			}
+			defer gr.Close()
+			r = gr
+		}
+
+		tr := tar.NewReader(r)
+		for {
+			var h *tar.Header
+			h, err = tr.Next()
+			switch err {
+			case nil:
+			case io.EOF:
+				return nil
+			default:
+				return
+			}
+			if h.Typeflag != tar.TypeReg && h.Typeflag != tar.TypeRegA {
+				continue
+			}
+			err = fn(h.Name, h.Size, tr)
+			if err != nil {
+				return
+			}
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package files
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/user"
 	"path/filepath"
@@ -13,7 +15,7 @@ import (
 )
 
 // Root directory paths
-var RootPath, ImageRoot, ThumbRoot string
+var RootPath, ImageRoot, ThumbRoot, PreviewRoot string
 
 // Determine root dirs
 func init() {
@@ -24,6 +26,7 @@ func init() {
 	}
 	ImageRoot = filepath.Join(RootPath, "images")
 	ThumbRoot = filepath.Join(RootPath, "thumbs")
+	PreviewRoot = filepath.Join(RootPath, "previews")
 }
 
 func Init() error {
@@ -33,9 +36,9 @@ func Init() error {
 
 	const hexStr = "0123456789abcdef"
 
-	// Create source file and thumbnail directories
+	// Create source file, thumbnail and preview directories
 	const dirMode = os.ModeDir | 0700
-	for _, dir := range [...]string{ImageRoot, ThumbRoot} {
+	for _, dir := range [...]string{ImageRoot, ThumbRoot, PreviewRoot} {
 		err := os.MkdirAll(dir, dirMode)
 		if err != nil {
 			return err
@@ -54,7 +57,34 @@ func Init() error {
 	return nil
 }
 
-// Recursively traverses an array of file and/or directory paths
+// Read and decode a JSON configuration file from RootPath into dst.
+// A missing file is not an error and leaves dst unchanged.
+func ReadConfig(name string, dst interface{}) error {
+	buf, err := ioutil.ReadFile(filepath.Join(RootPath, name))
+	switch {
+	case os.IsNotExist(err):
+		return nil
+	case err != nil:
+		return err
+	}
+	err = json.Unmarshal(buf, dst)
+	if err != nil {
+		return fmt.Errorf("%s: %s", name, err)
+	}
+	return nil
+}
+
+// Encode and write a JSON configuration file to RootPath
+func WriteConfig(name string, src interface{}) error {
+	buf, err := json.MarshalIndent(src, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(RootPath, name), buf, 0600)
+}
+
+// Recursively traverses an array of file and/or directory paths.
+// Archives are expanded into the paths of their members.
 func Traverse(paths []string) (files []string, err error) {
 	files = make([]string, 0, 64)
 
@@ -62,7 +92,18 @@ func Traverse(paths []string) (files []string, err error) {
 		switch {
 		case err != nil:
 			return err
-		case !info.IsDir():
+		case info.IsDir():
+		case IsArchive(path):
+			members, err := ListArchive(path)
+			if err != nil {
+				// Report the error, when the archive is imported
+				files = append(files, path)
+				return nil
+			}
+			for _, m := range members {
+				files = append(files, ArchiveMemberPath(path, m))
+			}
+		default:
 			files = append(files, path)
 		}
 		return nil
@@ -107,16 +148,6 @@ func SourcePath(id string, typ common.FileType) string {
 		fmt.Sprintf("%s.%s", id, common.Extensions[typ]))
 }
 
-// Returns the path to the thumbnail
-func ThumbPath(id string) string {
-	return filepath.Join(ThumbRoot, id[:2], id+".webp")
-}
-
-// Net URL to thumbnail path
-func NetThumbPath(id string) string {
-	return fmt.Sprintf("/thumbs/%s.webp", id)
-}
-
 // Net URL to source file path
 func NetSourcePath(id string, typ common.FileType) string {
 	return fmt.Sprintf("/files/%s.%s", id, common.Extensions[typ])
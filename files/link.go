@@ -0,0 +1,27 @@
+package files
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Place the file at src at dst without copying its data. Tries a reflink
+// first, which shares data blocks until either file is modified, and a hardlink
+// second. Both only work, if src and dst are on the same file system. Returns
+// an error, if the file must be copied instead.
+func LinkFile(src, dst string) (err error) {
+	err = os.MkdirAll(filepath.Dir(dst), 0760)
+	if err != nil {
+		return
+	}
+	// Remove leftovers of interrupted imports
+	err = os.Remove(dst)
+	if err != nil && !os.IsNotExist(err) {
+		return
+	}
+
+	if reflink(src, dst) == nil {
+		return nil
+	}
+	return os.Link(src, dst)
+}
@@ -0,0 +1,41 @@
+//go:build linux
+// +build linux
+
+package files
+
+import (
+	"os"
+	"syscall"
+)
+
+// FICLONE ioctl request supported by Btrfs, XFS and other copy-on-write file
+// systems
+const ficlone = 0x40049409
+
+// Create dst as a copy-on-write clone of src
+func reflink(src, dst string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return
+	}
+
+	_, _, errno := syscall.Syscall(
+		syscall.SYS_IOCTL,
+		out.Fd(),
+		ficlone,
+		in.Fd(),
+	)
+	err = out.Close()
+	if errno != 0 {
+		err = errno
+	}
+	if err != nil {
+		os.Remove(dst)
+	}
+	return
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package files
+
+import "errors"
+
+// Reflinks are only implemented on Linux
+func reflink(src, dst string) error {
+	return errors.New("reflinks not supported")
+}
@@ -0,0 +1,261 @@
+package files
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bakape/hydron/common"
+	"github.com/bakape/hydron/util"
+)
+
+const thumbConfigFile = "thumbnails.json"
+
+// Image formats thumbnails can be encoded in
+const (
+	WEBP = "webp"
+	JPEG = "jpeg"
+	AVIF = "avif"
+)
+
+// File extensions of thumbnail formats
+var thumbExtensions = map[string]string{
+	WEBP: "webp",
+	JPEG: "jpg",
+	AVIF: "avif",
+}
+
+// Size of thumbnails displayed on the search page, before zooming
+const DefaultThumbSize = 200
+
+// Thumbnail generation settings read from thumbnails.json
+type ThumbConfig struct {
+	// Maximum width and height of each thumbnail size in ascending order
+	Sizes []uint `json:"sizes"`
+	// One of "webp", "jpeg" or "avif"
+	Format string `json:"format"`
+	// Encoding quality from 1 to 100
+	Quality int `json:"quality"`
+}
+
+// Extension of thumbnail files
+func (c ThumbConfig) Extension() string {
+	return thumbExtensions[c.Format]
+}
+
+// Returns the largest thumbnail size. All other sizes are scaled down from
+// thumbnails of this size.
+func (c ThumbConfig) Largest() uint {
+	return c.Sizes[len(c.Sizes)-1]
+}
+
+// Returns the smallest thumbnail size at least as large as the default
+// displayed thumbnail size or the largest size, if none
+func (c ThumbConfig) Default() uint {
+	for _, s := range c.Sizes {
+		if s >= DefaultThumbSize {
+			return s
+		}
+	}
+	return c.Largest()
+}
+
+// Returns, if size is a configured thumbnail size
+func (c ThumbConfig) HasSize(size uint) bool {
+	for _, s := range c.Sizes {
+		if s == size {
+			return true
+		}
+	}
+	return false
+}
+
+// Thumbnail settings are only set on startup, so need no locking
+var thumbConfig = ThumbConfig{
+	Sizes:   []uint{150, 300, 600},
+	Format:  WEBP,
+	Quality: 90,
+}
+
+// Load thumbnail settings from the configuration file, if any
+func LoadThumbConfig() (err error) {
+	conf := thumbConfig
+	err = ReadConfig(thumbConfigFile, &conf)
+	if err != nil {
+		return
+	}
+	if len(conf.Sizes) == 0 {
+		return fmt.Errorf("%s: no thumbnail sizes set", thumbConfigFile)
+	}
+	sizes := make([]uint, 0, len(conf.Sizes))
+	for _, s := range conf.Sizes {
+		if s == 0 {
+			return fmt.Errorf("%s: thumbnail sizes must be positive",
+				thumbConfigFile)
+		}
+		sizes = append(sizes, s)
+	}
+	sort.Slice(sizes, func(i, j int) bool {
+		return sizes[i] < sizes[j]
+	})
+	conf.Sizes = sizes[:0]
+	for i, s := range sizes {
+		if i == 0 || s != sizes[i-1] {
+			conf.Sizes = append(conf.Sizes, s)
+		}
+	}
+	if _, ok := thumbExtensions[conf.Format]; !ok {
+		return fmt.Errorf("%s: unknown thumbnail format: %s", thumbConfigFile,
+			conf.Format)
+	}
+	if conf.Quality < 1 || conf.Quality > 100 {
+		return fmt.Errorf("%s: quality must be between 1 and 100",
+			thumbConfigFile)
+	}
+	thumbConfig = conf
+	return
+}
+
+// Return the current thumbnail settings
+func GetThumbConfig() ThumbConfig {
+	return thumbConfig
+}
+
+// Return the dimensions of a thumbnail of size scaled down from a thumbnail
+// of the largest size with dimensions largest
+func ScaleThumbDims(largest common.Dims, size uint) common.Dims {
+	max := largest.Width
+	if largest.Height > max {
+		max = largest.Height
+	}
+	if max <= uint64(size) {
+		return largest
+	}
+	d := common.Dims{
+		Width:  largest.Width * uint64(size) / max,
+		Height: largest.Height * uint64(size) / max,
+	}
+	if d.Width == 0 {
+		d.Width = 1
+	}
+	if d.Height == 0 {
+		d.Height = 1
+	}
+	return d
+}
+
+// Returns the path to the thumbnail of size in the configured format
+func ThumbPath(id string, size uint) string {
+	return thumbPath(id, size, thumbConfig.Extension())
+}
+
+func thumbPath(id string, size uint, ext string) string {
+	return filepath.Join(ThumbRoot, strconv.FormatUint(uint64(size), 10),
+		id[:2], id+"."+ext)
+}
+
+// Path to a thumbnail generated before multiple thumbnail sizes were supported
+func legacyThumbPath(id, ext string) string {
+	return filepath.Join(ThumbRoot, id[:2], id+"."+ext)
+}
+
+// Returns the path to an existing thumbnail of size. Thumbnails in other
+// formats and sizes and thumbnails generated before multiple sizes were
+// supported are returned, until thumbnails are regenerated.
+func FindThumbPath(id string, size uint) (string, error) {
+	if !util.IsSHA1(id) {
+		return "", os.ErrNotExist
+	}
+	path := ThumbPath(id, size)
+	_, err := os.Stat(path)
+	if !os.IsNotExist(err) {
+		return path, err
+	}
+	for _, ext := range thumbExtensions {
+		p := thumbPath(id, size, ext)
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+
+	// Prefer the smallest larger size, then the largest smaller size
+	matches, globErr := filepath.Glob(filepath.Join(ThumbRoot, "*", id[:2],
+		id+".*"))
+	if globErr != nil {
+		return path, globErr
+	}
+	var (
+		closest     string
+		closestSize uint64
+	)
+	for _, p := range matches {
+		// Skip thumbnails still being written
+		if strings.HasSuffix(p, ".tmp") {
+			continue
+		}
+		s, e := strconv.ParseUint(
+			filepath.Base(filepath.Dir(filepath.Dir(p))), 10, 64)
+		if e != nil {
+			continue
+		}
+		switch {
+		case closest == "",
+			s >= uint64(size) && (closestSize < uint64(size) || s < closestSize),
+			s < uint64(size) && closestSize < uint64(size) && s > closestSize:
+			closest = p
+			closestSize = s
+		}
+	}
+	if closest != "" {
+		return closest, nil
+	}
+
+	for _, ext := range thumbExtensions {
+		p := legacyThumbPath(id, ext)
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+	return path, err
+}
+
+// Returns the paths to all existing thumbnails of an image in any size and
+// format
+func AllThumbPaths(id string) (paths []string, err error) {
+	for _, pattern := range [...]string{
+		filepath.Join(ThumbRoot, "*", id[:2], id+".*"),
+		legacyThumbPath(id, "*"),
+	} {
+		var m []string
+		m, err = filepath.Glob(pattern)
+		if err != nil {
+			return
+		}
+		for _, p := range m {
+			// Skip thumbnails still being written
+			if !strings.HasSuffix(p, ".tmp") {
+				paths = append(paths, p)
+			}
+		}
+	}
+	return
+}
+
+// Net URL to the thumbnail of size
+func NetThumbPath(id string, size uint) string {
+	return fmt.Sprintf("/thumbs/%d/%s.%s?q=%d", size, id,
+		thumbConfig.Extension(), thumbConfig.Quality)
+}
+
+// Returns the path to the animated hover preview of a GIF or video
+func PreviewPath(id string) string {
+	return filepath.Join(PreviewRoot, id[:2], id+".webp")
+}
+
+// Net URL to the animated hover preview of a GIF or video
+func NetPreviewPath(id string) string {
+	return fmt.Sprintf("/previews/%s.webp?s=%d", id, thumbConfig.Default())
+}
@@ -1,6 +1,6 @@
 module github.com/bakape/hydron
 
-go 1.14
+go 1.23
 
 require (
 	github.com/Masterminds/squirrel v1.4.0
@@ -8,12 +8,29 @@ require (
 	github.com/bakape/thumbnailer/v2 v2.6.6
 	github.com/chai2010/webp v1.1.0
 	github.com/dimfeld/httptreemux v5.0.1+incompatible
+	github.com/fsnotify/fsnotify v1.4.9
+	github.com/gen2brain/avif v0.4.4
+	github.com/gen2brain/heic v0.4.5
 	github.com/gorilla/handlers v1.4.2
 	github.com/lib/pq v1.8.0
 	github.com/mailru/easyjson v0.7.2
 	github.com/mattn/go-sqlite3 v1.14.0
-	github.com/pyros2097/go-embed v0.0.0-20210109052941-d7cf67a0c154 // indirect
+	github.com/rwcarlsen/goexif v0.0.0-20190401172101-9e8deecbddbd
+	github.com/srwiley/oksvg v0.0.0-20221011165216-be6e8873101c
+	github.com/srwiley/rasterx v0.0.0-20220730225603-2ab79fcdd4ef
 	github.com/valyala/quicktemplate v1.6.3
-	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9 // indirect
-	golang.org/x/net v0.0.0-20211112202133-69e39bad7dc2 // indirect
+	golang.org/x/image v0.0.0-20211028202545-6944b10bf410
+	golang.org/x/text v0.3.6
+)
+
+require (
+	github.com/ebitengine/purego v0.8.3 // indirect
+	github.com/josharian/intern v1.0.0 // indirect
+	github.com/lann/builder v0.0.0-20180802200727-47ae307949d0 // indirect
+	github.com/lann/ps v0.0.0-20150810152359-62de8c46ede0 // indirect
+	github.com/nwaples/rardecode v1.1.0 // indirect
+	github.com/tetratelabs/wazero v1.9.0 // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
+	golang.org/x/net v0.0.0-20211118161319-6a13c67c3ce4 // indirect
+	golang.org/x/sys v0.0.0-20210423082822-04245dca01da // indirect
 )
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/bakape/hydron/common"
+	"github.com/bakape/hydron/db"
+	"github.com/bakape/hydron/hydrus"
+	imp "github.com/bakape/hydron/import"
+)
+
+// Import all files with their tags, inbox state and URLs from the Hydrus
+// client databases in dir. Files imported by previous runs are skipped, so
+// an interrupted import can be resumed by running it again.
+func importHydrus(dir string) (err error) {
+	c, err := hydrus.Open(dir)
+	if err != nil {
+		return
+	}
+	defer c.Close()
+
+	all, err := c.Files()
+	if err != nil {
+		return
+	}
+	var files []hydrus.File
+	for _, f := range all {
+		var done bool
+		done, err = db.IsHydrusImported(f.SHA256)
+		if err != nil {
+			return
+		}
+		if !done {
+			files = append(files, f)
+		}
+	}
+	if skipped := len(all) - len(files); skipped != 0 {
+		fmt.Printf("skipping %d previously imported files\n", skipped)
+	}
+	if len(files) == 0 {
+		return
+	}
+
+	b, err := db.NewBatch(db.ActorHydrus)
+	if err != nil {
+		return
+	}
+
+	// Buffer all files into a channel
+	passFiles := make(chan hydrus.File, len(files))
+	for _, f := range files {
+		passFiles <- f
+	}
+	close(passFiles)
+
+	// Process files in parallel
+	ch := make(chan error)
+	n := runtime.NumCPU() + 1
+	for i := 0; i < n; i++ {
+		go func() {
+			for f := range passFiles {
+				err := importHydrusFile(c, b, f)
+				if err != nil {
+					err = fmt.Errorf("%s: %s", f.SHA256, err)
+				}
+				ch <- err
+			}
+		}()
+	}
+
+	p := progressLogger{
+		header: "importing from Hydrus",
+		total:  len(files),
+	}
+	for range files {
+		err := <-ch
+		if err != nil {
+			p.Err(err)
+		} else {
+			p.Done()
+		}
+	}
+	p.Close()
+
+	return nil
+}
+
+// Import a single file from a Hydrus client with its tags, inbox state and
+// URLs
+func importHydrusFile(c *hydrus.Client, b db.Batch, f hydrus.File) (
+	err error,
+) {
+	path, err := c.Path(f)
+	if err != nil {
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return
+	}
+
+	img, err := imp.ImportFile(file, int(info.Size()), "", "", false, false)
+	switch err {
+	case nil:
+	case imp.ErrImported:
+		img.ID, err = db.GetImageID(img.SHA1)
+		if err != nil {
+			return
+		}
+	default:
+		return
+	}
+
+	tags, err := c.Tags(f)
+	if err != nil {
+		return
+	}
+	inbox, err := c.InInbox(f)
+	if err != nil {
+		return
+	}
+	if inbox {
+		tags = append(tags, common.Tag{
+			TagBase: common.TagBase{
+				Type: common.Meta,
+				Tag:  "inbox",
+			},
+			Source: common.Hydrus,
+		})
+	}
+	err = db.UpdateTags(b, img.ID, tags, common.Hydrus)
+	if err != nil {
+		return
+	}
+
+	urls, err := c.URLs(f)
+	if err != nil {
+		return
+	}
+	sources := make([]common.Source, len(urls))
+	for i, u := range urls {
+		sources[i] = common.Source{
+			URL:  u,
+			Kind: common.ImportSource,
+		}
+	}
+	err = db.AddSources(img.ID, sources...)
+	if err != nil {
+		return
+	}
+
+	return db.SetHydrusImported(f.SHA256, img.ID)
+}
@@ -0,0 +1,357 @@
+// Package hydrus reads files, tags and URLs directly from the SQLite databases
+// and file store of a Hydrus Network client
+package hydrus
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bakape/hydron/common"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Hydrus service types
+const (
+	tagRepository   = 0
+	localFileDomain = 2
+	localTag        = 5
+)
+
+// ErrUnsupportedVersion is returned for databases created by Hydrus versions
+// without per-service file and mapping tables
+var ErrUnsupportedVersion = errors.New("unsupported Hydrus database version")
+
+// File stored by the Hydrus client
+type File struct {
+	ID int64
+	// Hex-encoded SHA256 hash of the file
+	SHA256 string
+}
+
+// Client is a read-only connection to the databases of a Hydrus client
+type Client struct {
+	db *sql.DB
+	// Directory containing the databases
+	dir string
+	// Directories of the file store by 3-character prefix like "f0a"
+	locations map[string]string
+	// IDs of file and tag services to import
+	fileServices, tagServices []int64
+}
+
+// Open the Hydrus client databases in dir read-only
+func Open(dir string) (c *Client, err error) {
+	path := filepath.Join(dir, "client.db")
+	if _, err = os.Stat(path); err != nil {
+		return
+	}
+
+	db, err := sql.Open("sqlite3", dbURI(path))
+	if err != nil {
+		return
+	}
+	// Attached databases are only visible on the connection they were
+	// attached on
+	db.SetMaxOpenConns(1)
+	c = &Client{
+		db:  db,
+		dir: dir,
+	}
+	defer func() {
+		if err != nil {
+			db.Close()
+			c = nil
+		}
+	}()
+
+	for _, name := range [...]string{"master", "mappings"} {
+		_, err = db.Exec(
+			fmt.Sprintf(`attach database ? as external_%s`, name),
+			dbURI(filepath.Join(dir, fmt.Sprintf("client.%s.db", name))),
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	err = c.readServices()
+	if err != nil {
+		return
+	}
+	err = c.readLocations()
+	return
+}
+
+// Return an URI opening an SQLite database read-only
+func dbURI(path string) string {
+	return fmt.Sprintf("file:%s?mode=ro", path)
+}
+
+// Close the database connection
+func (c *Client) Close() error {
+	return c.db.Close()
+}
+
+// Read the IDs of the local file domains, local tag services and tag
+// repositories like the PTR
+func (c *Client) readServices() (err error) {
+	r, err := c.db.Query(
+		`select service_id, service_type
+		from services
+		where service_type in (?, ?, ?)`,
+		localFileDomain, localTag, tagRepository,
+	)
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	for r.Next() {
+		var id, typ int64
+		err = r.Scan(&id, &typ)
+		if err != nil {
+			return
+		}
+		if typ == localFileDomain {
+			c.fileServices = append(c.fileServices, id)
+		} else {
+			c.tagServices = append(c.tagServices, id)
+		}
+	}
+	err = r.Err()
+	if err != nil {
+		return
+	}
+	r.Close()
+
+	for _, id := range c.fileServices {
+		err = c.assertTable("main", currentFilesTable(id))
+		if err != nil {
+			return
+		}
+	}
+	for _, id := range c.tagServices {
+		err = c.assertTable("external_mappings", currentMappingsTable(id))
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Return ErrUnsupportedVersion, if table does not exist in schema
+func (c *Client) assertTable(schema, table string) error {
+	var n int
+	err := c.db.
+		QueryRow(
+			fmt.Sprintf(
+				`select count(*) from %s.sqlite_master
+				where type = 'table' and name = ?`,
+				schema,
+			),
+			table,
+		).
+		Scan(&n)
+	switch {
+	case err != nil:
+		return err
+	case n == 0:
+		return ErrUnsupportedVersion
+	default:
+		return nil
+	}
+}
+
+func currentFilesTable(serviceID int64) string {
+	return fmt.Sprintf("current_files_%d", serviceID)
+}
+
+func currentMappingsTable(serviceID int64) string {
+	return fmt.Sprintf("current_mappings_%d", serviceID)
+}
+
+// Read the locations of the file store directories. Locations are relative
+// to the database directory, unless absolute.
+func (c *Client) readLocations() (err error) {
+	c.locations = make(map[string]string, 512)
+	r, err := c.db.Query(`select prefix, location from client_files_locations`)
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	for r.Next() {
+		var prefix, loc string
+		err = r.Scan(&prefix, &loc)
+		if err != nil {
+			return
+		}
+		if !filepath.IsAbs(loc) {
+			loc = filepath.Join(c.dir, loc)
+		}
+		c.locations[prefix] = loc
+	}
+	return r.Err()
+}
+
+// Return all files in the local file domains ordered by ID
+func (c *Client) Files() (files []File, err error) {
+	if len(c.fileServices) == 0 {
+		return
+	}
+	q := make([]string, len(c.fileServices))
+	for i, id := range c.fileServices {
+		q[i] = "select hash_id from " + currentFilesTable(id)
+	}
+
+	r, err := c.db.Query(fmt.Sprintf(
+		`select h.hash_id, h.hash
+		from external_master.hashes as h
+		where h.hash_id in (%s)
+		order by h.hash_id`,
+		strings.Join(q, " union "),
+	))
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	for r.Next() {
+		var (
+			f    File
+			hash []byte
+		)
+		err = r.Scan(&f.ID, &hash)
+		if err != nil {
+			return
+		}
+		f.SHA256 = hex.EncodeToString(hash)
+		files = append(files, f)
+	}
+	err = r.Err()
+	return
+}
+
+// Return the path to the source file of f in the file store
+func (c *Client) Path(f File) (string, error) {
+	prefix := "f" + f.SHA256[:2]
+	dir, ok := c.locations[prefix]
+	if !ok {
+		dir = filepath.Join(c.dir, "client_files", prefix)
+	}
+
+	// The extension is not stored, so match any
+	m, err := filepath.Glob(filepath.Join(dir, f.SHA256+".*"))
+	switch {
+	case err != nil:
+		return "", err
+	case len(m) == 0:
+		return "", fmt.Errorf("file not found in file store: %s", f.SHA256)
+	default:
+		return m[0], nil
+	}
+}
+
+// Return the tags of f in all local tag services and tag repositories
+func (c *Client) Tags(f File) (tags []common.Tag, err error) {
+	if len(c.tagServices) == 0 {
+		return
+	}
+	q := make([]string, len(c.tagServices))
+	for i, id := range c.tagServices {
+		q[i] = fmt.Sprintf(
+			"select tag_id from external_mappings.%s where hash_id = ?",
+			currentMappingsTable(id),
+		)
+	}
+	args := make([]interface{}, len(q))
+	for i := range args {
+		args[i] = f.ID
+	}
+
+	r, err := c.db.Query(
+		fmt.Sprintf(
+			`select n.namespace, s.subtag
+			from external_master.tags as t
+			join external_master.namespaces as n
+				on n.namespace_id = t.namespace_id
+			join external_master.subtags as s on s.subtag_id = t.subtag_id
+			where t.tag_id in (%s)`,
+			strings.Join(q, " union "),
+		),
+		args...,
+	)
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	dedup := make(map[common.TagBase]struct{})
+	for r.Next() {
+		var namespace, subtag string
+		err = r.Scan(&namespace, &subtag)
+		if err != nil {
+			return
+		}
+		t := common.Tag{
+			TagBase: convertTag(namespace, subtag),
+			Source:  common.Hydrus,
+		}
+		if t.Tag == "" {
+			continue
+		}
+		if _, ok := dedup[t.TagBase]; ok {
+			continue
+		}
+		dedup[t.TagBase] = struct{}{}
+		tags = append(tags, t)
+	}
+	err = r.Err()
+	return
+}
+
+// Return, if f is in the inbox. Files not in the inbox are archived.
+func (c *Client) InInbox(f File) (inbox bool, err error) {
+	err = c.db.
+		QueryRow(`select 1 from file_inbox where hash_id = ?`, f.ID).
+		Scan(new(int))
+	switch err {
+	case nil:
+		inbox = true
+	case sql.ErrNoRows:
+		err = nil
+	}
+	return
+}
+
+// Return the URLs associated with f
+func (c *Client) URLs(f File) (urls []string, err error) {
+	r, err := c.db.Query(
+		`select u.url
+		from url_map as m
+		join external_master.urls as u on u.url_id = m.url_id
+		where m.hash_id = ?
+		order by u.url_id`,
+		f.ID,
+	)
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	for r.Next() {
+		var u string
+		err = r.Scan(&u)
+		if err != nil {
+			return
+		}
+		urls = append(urls, u)
+	}
+	err = r.Err()
+	return
+}
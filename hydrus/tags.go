@@ -0,0 +1,58 @@
+package hydrus
+
+import (
+	"strings"
+
+	"github.com/bakape/hydron/common"
+	"github.com/bakape/hydron/tags"
+)
+
+// Hydrus namespaces by internal tag type
+var namespaces = map[common.TagType]string{
+	common.Author:    "creator",
+	common.Character: "character",
+	common.Series:    "series",
+	common.Meta:      "meta",
+	common.Rating:    "rating",
+}
+
+// Convert a Hydrus tag like "creator:some artist" to the internal format
+func ParseTag(s string) common.TagBase {
+	var namespace string
+	if i := strings.IndexByte(s, ':'); i > 0 {
+		namespace = s[:i]
+		s = s[i+1:]
+	}
+	return convertTag(strings.TrimSpace(namespace), strings.TrimSpace(s))
+}
+
+// Convert a Hydrus namespaced tag to the internal format. Namespaces without
+// a matching tag type are kept as part of the tag.
+func convertTag(namespace, subtag string) (t common.TagBase) {
+	switch namespace {
+	case "":
+	case "creator", "artist":
+		t.Type = common.Author
+	case "character":
+		t.Type = common.Character
+	case "series", "copyright":
+		t.Type = common.Series
+	case "meta":
+		t.Type = common.Meta
+	case "rating":
+		t.Type = common.Rating
+	default:
+		subtag = namespace + ":" + subtag
+	}
+	t.Tag = tags.NormalizeString(subtag)
+	return
+}
+
+// Format a tag the way Hydrus displays it with spaces instead of underscores
+func FormatTag(t common.TagBase) string {
+	s := strings.Replace(t.Tag, "_", " ", -1)
+	if ns, ok := namespaces[t.Type]; ok {
+		s = ns + ":" + s
+	}
+	return s
+}
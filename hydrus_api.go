@@ -0,0 +1,696 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bakape/hydron/common"
+	"github.com/bakape/hydron/db"
+	"github.com/bakape/hydron/fetch"
+	"github.com/bakape/hydron/files"
+	"github.com/bakape/hydron/hydrus"
+	imp "github.com/bakape/hydron/import"
+	"github.com/bakape/hydron/util"
+	"github.com/dimfeld/httptreemux"
+)
+
+const (
+	// Implemented version of the Hydrus Client API
+	hydrusAPIVersion = 17
+
+	// Header or query parameter the access key is passed in
+	hydrusKeyHeader = "Hydrus-Client-API-Access-Key"
+
+	// Name of the single tag service exposed to clients
+	hydrusTagService = "my tags"
+)
+
+// Hydrus file import statuses
+const (
+	hydrusImported        = 1
+	hydrusAlreadyImported = 2
+	hydrusImportFailed    = 4
+)
+
+var (
+	// Access keys accepted by the Hydrus Client API. The API is disabled, if
+	// none.
+	hydrusAccessKeys []string
+
+	// Key of the tag service. Same as the default local tag service of a
+	// Hydrus client.
+	hydrusTagServiceKey = hex.EncodeToString([]byte("local tags"))
+)
+
+// Identifiers of a file in the Hydrus Client API. Hydrus file IDs are image
+// IDs.
+type hydrusIdentifiers struct {
+	FileID int64  `json:"file_id"`
+	Hash   string `json:"hash"`
+}
+
+// File metadata in the Hydrus Client API format
+type hydrusMetadata struct {
+	hydrusIdentifiers
+	Size      int      `json:"size"`
+	Mime      string   `json:"mime"`
+	Ext       string   `json:"ext"`
+	Width     uint64   `json:"width"`
+	Height    uint64   `json:"height"`
+	Duration  *uint64  `json:"duration"` // In milliseconds. Null for images.
+	NumFrames *int     `json:"num_frames"`
+	NumWords  *int     `json:"num_words"`
+	HasAudio  bool     `json:"has_audio"`
+	KnownURLs []string `json:"known_urls"`
+	IsInbox   bool     `json:"is_inbox"`
+	IsLocal   bool     `json:"is_local"`
+	IsTrashed bool     `json:"is_trashed"`
+
+	ServiceNamesToStatusesToTags map[string]map[string][]string `json:"service_names_to_statuses_to_tags"`
+	ServiceKeysToStatusesToTags  map[string]map[string][]string `json:"service_keys_to_statuses_to_tags"`
+}
+
+// Tags to add to or remove from files in Hydrus Client API requests. All
+// services map to the same tags.
+type hydrusTags struct {
+	ServiceNamesToTags           map[string][]string            `json:"service_names_to_tags"`
+	ServiceKeysToTags            map[string][]string            `json:"service_keys_to_tags"`
+	ServiceNamesToAdditionalTags map[string][]string            `json:"service_names_to_additional_tags"`
+	ServiceKeysToAdditionalTags  map[string][]string            `json:"service_keys_to_additional_tags"`
+	ServiceNamesToActionsToTags  map[string]map[string][]string `json:"service_names_to_actions_to_tags"`
+	ServiceKeysToActionsToTags   map[string]map[string][]string `json:"service_keys_to_actions_to_tags"`
+}
+
+// Convert requested tag changes to tags to add and remove
+func (t hydrusTags) changes() (add, remove []common.Tag) {
+	appendTags := func(dst []common.Tag, src []string) []common.Tag {
+		for _, s := range src {
+			tag := common.Tag{
+				TagBase: hydrus.ParseTag(s),
+				Source:  common.User,
+			}
+			if tag.Tag != "" {
+				dst = append(dst, tag)
+			}
+		}
+		return dst
+	}
+
+	for _, m := range [...]map[string][]string{
+		t.ServiceNamesToTags,
+		t.ServiceKeysToTags,
+		t.ServiceNamesToAdditionalTags,
+		t.ServiceKeysToAdditionalTags,
+	} {
+		for _, tags := range m {
+			add = appendTags(add, tags)
+		}
+	}
+	for _, m := range [...]map[string]map[string][]string{
+		t.ServiceNamesToActionsToTags,
+		t.ServiceKeysToActionsToTags,
+	} {
+		for _, actions := range m {
+			for action, tags := range actions {
+				switch action {
+				case "0", "2": // Add or pend
+					add = appendTags(add, tags)
+				case "1", "4": // Delete or petition
+					remove = appendTags(remove, tags)
+				}
+			}
+		}
+	}
+	return
+}
+
+// Read the Hydrus Client API configuration, if any
+func loadHydrusAPIConfig() (err error) {
+	var conf struct {
+		AccessKeys []string `json:"access_keys"`
+	}
+	err = files.ReadConfig("hydrus_api.json", &conf)
+	if err != nil {
+		return
+	}
+	hydrusAccessKeys = conf.AccessKeys
+	return
+}
+
+// Register the Hydrus Client API endpoints on the router
+func registerHydrusAPI(r *httptreemux.ContextMux) {
+	r.GET("/api_version", serveHydrusAPIVersion)
+	r.GET("/verify_access_key", hydrusAuth(verifyHydrusAccessKey))
+	r.POST("/add_files/add_file", hydrusAuth(hydrusAddFile))
+	r.POST("/add_tags/add_tags", hydrusAuth(hydrusAddTags))
+	r.POST("/add_urls/add_url", hydrusAuth(hydrusAddURL))
+	r.GET("/get_files/search_files", hydrusAuth(hydrusSearchFiles))
+	r.GET("/get_files/file_metadata", hydrusAuth(hydrusFileMetadata))
+	r.GET("/get_files/file", hydrusAuth(hydrusServeFile))
+	r.GET("/get_files/thumbnail", hydrusAuth(hydrusServeThumbnail))
+}
+
+// Only pass requests with a valid access key to h
+func hydrusAuth(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(hydrusKeyHeader)
+		if key == "" {
+			key = r.URL.Query().Get(hydrusKeyHeader)
+		}
+		if key == "" {
+			sendError(w, 401, errors.New("missing access key"))
+			return
+		}
+		for _, k := range hydrusAccessKeys {
+			if subtle.ConstantTimeCompare([]byte(key), []byte(k)) == 1 {
+				h(w, r)
+				return
+			}
+		}
+		sendError(w, 403, errors.New("invalid access key"))
+	}
+}
+
+func serveHydrusAPIVersion(w http.ResponseWriter, r *http.Request) {
+	serveJSON(w, r, map[string]int{
+		"version": hydrusAPIVersion,
+	})
+}
+
+func verifyHydrusAccessKey(w http.ResponseWriter, r *http.Request) {
+	serveJSON(w, r, map[string]interface{}{
+		// All permissions
+		"basic_permissions": []int{0, 1, 2, 3, 4, 5, 6},
+		"human_description": "hydron: all permissions",
+	})
+}
+
+// Decode a JSON request body into dst
+func decodeHydrusBody(r *http.Request, dst interface{}) error {
+	err := json.NewDecoder(r.Body).Decode(dst)
+	if err != nil {
+		return errStatus{400, err}
+	}
+	return nil
+}
+
+// Decode the JSON-encoded query parameters of a request into the matching
+// values of dst
+func decodeHydrusQuery(q url.Values, dst map[string]interface{}) error {
+	for key, val := range dst {
+		s := q.Get(key)
+		if s == "" {
+			continue
+		}
+		err := json.Unmarshal([]byte(s), val)
+		if err != nil {
+			return errStatus{400, fmt.Errorf("%s: %s", key, err)}
+		}
+	}
+	return nil
+}
+
+// Error with an HTTP status code
+type errStatus struct {
+	code int
+	error
+}
+
+func (e errStatus) Status() int {
+	return e.code
+}
+
+// Retrieve all images specified by the file ID and hash fields
+func hydrusImages(ids []int64, hashes []string) (
+	images []common.Image, err error,
+) {
+	images = make([]common.Image, 0, len(ids)+len(hashes))
+	for _, id := range ids {
+		var img common.Image
+		img, err = db.GetImageByID(id)
+		if err != nil {
+			return
+		}
+		images = append(images, img)
+	}
+	for _, h := range hashes {
+		var img common.Image
+		img, err = db.GetImageBySHA256(strings.ToLower(h))
+		if err != nil {
+			return
+		}
+		images = append(images, img)
+	}
+	return
+}
+
+// Import a file passed by path in a JSON body or as the raw request body
+func hydrusAddFile(w http.ResponseWriter, r *http.Request) {
+	var f *os.File
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var req struct {
+			Path string `json:"path"`
+		}
+		err := decodeHydrusBody(r, &req)
+		if err != nil {
+			httpError(w, r, err)
+			return
+		}
+		f, err = os.Open(req.Path)
+		if err != nil {
+			sendError(w, 400, err)
+			return
+		}
+	} else {
+		var err error
+		f, err = fetch.BufferFile(r.Body)
+		if err != nil {
+			sendError(w, 400, err)
+			return
+		}
+		defer os.Remove(f.Name())
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		send500(w, r, err)
+		return
+	}
+
+	var res struct {
+		Status int    `json:"status"`
+		Hash   string `json:"hash"`
+		Note   string `json:"note"`
+	}
+	img, err := imp.ImportFile(f, int(info.Size()), "", "", false, false)
+	switch err {
+	case nil:
+		res.Status = hydrusImported
+		res.Hash = img.SHA256
+	case imp.ErrImported:
+		res.Status = hydrusAlreadyImported
+		img, err = db.GetImage(img.SHA1)
+		if err != nil {
+			send500(w, r, err)
+			return
+		}
+		res.Hash, err = imageSHA256(img)
+		if err != nil {
+			send500(w, r, err)
+			return
+		}
+	default:
+		res.Status = hydrusImportFailed
+		res.Note = err.Error()
+	}
+	serveJSON(w, r, res)
+}
+
+// Add and remove tags of files
+func hydrusAddTags(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		hydrusTags
+		FileID  *int64   `json:"file_id"`
+		FileIDs []int64  `json:"file_ids"`
+		Hash    string   `json:"hash"`
+		Hashes  []string `json:"hashes"`
+	}
+	err := decodeHydrusBody(r, &req)
+	if err != nil {
+		httpError(w, r, err)
+		return
+	}
+	if req.FileID != nil {
+		req.FileIDs = append(req.FileIDs, *req.FileID)
+	}
+	if req.Hash != "" {
+		req.Hashes = append(req.Hashes, req.Hash)
+	}
+
+	images, err := hydrusImages(req.FileIDs, req.Hashes)
+	if err != nil {
+		httpError(w, r, err)
+		return
+	}
+	add, remove := req.changes()
+	b, err := db.NewBatch(db.ActorHydrusAPI)
+	if err != nil {
+		send500(w, r, err)
+		return
+	}
+	for _, img := range images {
+		err = db.AddTags(b, img.ID, add)
+		if err != nil {
+			send500(w, r, err)
+			return
+		}
+		err = db.RemoveTags(b, img.ID, remove)
+		if err != nil {
+			send500(w, r, err)
+			return
+		}
+	}
+}
+
+// Download and import a file from a URL in the background
+func hydrusAddURL(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		hydrusTags
+		URL string `json:"url"`
+	}
+	err := decodeHydrusBody(r, &req)
+	if err != nil {
+		httpError(w, r, err)
+		return
+	}
+	if !util.IsFetchable(req.URL) {
+		sendError(w, 400, errors.New("not a http(s) URL"))
+		return
+	}
+
+	add, _ := req.changes()
+	go func() {
+		err := func() (err error) {
+			img, err := importURL(req.URL, false, false, "")
+			if err == imp.ErrImported {
+				err = nil
+			}
+			if err != nil || len(add) == 0 {
+				return
+			}
+			b, err := db.NewBatch(db.ActorHydrusAPI)
+			if err != nil {
+				return
+			}
+			return db.AddTags(b, img.ID, add)
+		}()
+		if err != nil {
+			stderr.Printf("hydrus api: %s: %s\n", req.URL, err)
+		}
+	}()
+
+	serveJSON(w, r, map[string]string{
+		"human_result_text": fmt.Sprintf(`"%s" URL added successfully.`,
+			req.URL),
+		"normalised_url": req.URL,
+	})
+}
+
+// Search files by Hydrus tags
+func hydrusSearchFiles(w http.ResponseWriter, r *http.Request) {
+	var (
+		tags          []string
+		returnIDs     = true
+		returnHashes  bool
+		page          common.Page
+		ids           []int64
+		compactImages []common.CompactImage
+	)
+	err := decodeHydrusQuery(r.URL.Query(), map[string]interface{}{
+		"tags":            &tags,
+		"return_file_ids": &returnIDs,
+		"return_hashes":   &returnHashes,
+	})
+	if err != nil {
+		httpError(w, r, err)
+		return
+	}
+
+	for _, s := range tags {
+		s = strings.TrimSpace(s)
+		f := common.TagFilter{
+			Negative: strings.HasPrefix(s, "-"),
+		}
+		if f.Negative {
+			s = s[1:]
+		}
+		switch strings.ToLower(s) {
+		case "system:everything":
+			continue
+		case "system:inbox":
+			f.TagBase = common.TagBase{Type: common.Meta, Tag: "inbox"}
+		case "system:archive":
+			f.Negative = !f.Negative
+			f.TagBase = common.TagBase{Type: common.Meta, Tag: "inbox"}
+		default:
+			if strings.HasPrefix(s, "system:") {
+				sendError(w, 400,
+					fmt.Errorf("unsupported system predicate: %s", s))
+				return
+			}
+			f.TagBase = hydrus.ParseTag(s)
+			if f.Tag == "" {
+				continue
+			}
+		}
+		page.Filters.Tag = append(page.Filters.Tag, f)
+	}
+
+	err = db.SearchImages(&page, false, func(img common.CompactImage) error {
+		ids = append(ids, img.ID)
+		if returnHashes {
+			compactImages = append(compactImages, img)
+		}
+		return nil
+	})
+	if err != nil {
+		send500(w, r, err)
+		return
+	}
+
+	res := make(map[string]interface{}, 2)
+	if returnIDs {
+		if ids == nil {
+			ids = []int64{}
+		}
+		res["file_ids"] = ids
+	}
+	if returnHashes {
+		hashes := make([]string, len(compactImages))
+		for i, img := range compactImages {
+			hashes[i], err = compactImageSHA256(img)
+			if err != nil {
+				send500(w, r, err)
+				return
+			}
+		}
+		res["hashes"] = hashes
+	}
+	serveJSON(w, r, res)
+}
+
+// Serve metadata of files in the Hydrus Client API format
+func hydrusFileMetadata(w http.ResponseWriter, r *http.Request) {
+	var (
+		ids         []int64
+		hashes      []string
+		identifiers bool
+	)
+	err := decodeHydrusQuery(r.URL.Query(), map[string]interface{}{
+		"file_ids":                &ids,
+		"hashes":                  &hashes,
+		"only_return_identifiers": &identifiers,
+	})
+	if err != nil {
+		httpError(w, r, err)
+		return
+	}
+	images, err := hydrusImages(ids, hashes)
+	if err != nil {
+		httpError(w, r, err)
+		return
+	}
+
+	var res []interface{}
+	for _, img := range images {
+		var id hydrusIdentifiers
+		id.FileID = img.ID
+		id.Hash, err = imageSHA256(img)
+		if err != nil {
+			send500(w, r, err)
+			return
+		}
+		if identifiers {
+			res = append(res, id)
+		} else {
+			res = append(res, convertHydrusMetadata(id, img))
+		}
+	}
+	if res == nil {
+		res = []interface{}{}
+	}
+	serveJSON(w, r, map[string]interface{}{
+		"metadata": res,
+	})
+}
+
+// Convert an image to the Hydrus Client API metadata format
+func convertHydrusMetadata(id hydrusIdentifiers, img common.Image,
+) hydrusMetadata {
+	m := hydrusMetadata{
+		hydrusIdentifiers: id,
+		Size:              img.Size,
+		Mime:              fileMime(img.Type),
+		Ext:               "." + common.Extensions[img.Type],
+		Width:             img.Width,
+		Height:            img.Height,
+		KnownURLs:         make([]string, 0, len(img.Sources)),
+		IsLocal:           true,
+	}
+	if common.GetMediaType(img.Type) != common.MediaImage {
+		ms := img.Duration * 1000
+		m.Duration = &ms
+	}
+	for _, s := range img.Sources {
+		m.KnownURLs = append(m.KnownURLs, s.URL)
+	}
+
+	tags := make([]string, 0, len(img.Tags))
+	for _, t := range img.Tags {
+		if t.Type == common.Meta && t.Tag == "inbox" {
+			m.IsInbox = true
+		}
+		tags = append(tags, hydrus.FormatTag(t.TagBase))
+	}
+	// Status 0 is current tags
+	statuses := map[string][]string{
+		"0": tags,
+	}
+	m.ServiceNamesToStatusesToTags = map[string]map[string][]string{
+		hydrusTagService: statuses,
+	}
+	m.ServiceKeysToStatusesToTags = map[string]map[string][]string{
+		hydrusTagServiceKey: statuses,
+	}
+	return m
+}
+
+// Return the MIME type of a file type
+func fileMime(t common.FileType) string {
+	for mime, typ := range common.MimeTypes {
+		if typ == t {
+			return mime
+		}
+	}
+	return "application/octet-stream"
+}
+
+// Retrieve the image specified by the "file_id" or "hash" query parameter
+func hydrusRequestImage(r *http.Request) (img common.Image, err error) {
+	q := r.URL.Query()
+	if s := q.Get("file_id"); s != "" {
+		var id int64
+		id, err = strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return
+		}
+		return db.GetImageByID(id)
+	}
+	if s := q.Get("hash"); s != "" {
+		return db.GetImageBySHA256(strings.ToLower(s))
+	}
+	err = errStatus{400, errors.New("no file_id or hash specified")}
+	return
+}
+
+func hydrusServeFile(w http.ResponseWriter, r *http.Request) {
+	img, err := hydrusRequestImage(r)
+	if err != nil {
+		httpError(w, r, err)
+		return
+	}
+	serveHydrusFile(w, r, files.SourcePath(img.SHA1, img.Type),
+		img.SHA1+"."+common.Extensions[img.Type])
+}
+
+func hydrusServeThumbnail(w http.ResponseWriter, r *http.Request) {
+	img, err := hydrusRequestImage(r)
+	if err != nil {
+		httpError(w, r, err)
+		return
+	}
+	// Missing thumbnails are handled by serveHydrusFile
+	path, _ := files.FindThumbPath(img.SHA1, files.GetThumbConfig().Default())
+	serveHydrusFile(w, r, path, filepath.Base(path))
+}
+
+// Serve a file from path. The content type is detected from the extension of
+// name.
+func serveHydrusFile(w http.ResponseWriter, r *http.Request,
+	path, name string,
+) {
+	f, err := os.Open(path)
+	if err != nil {
+		send404(w)
+		return
+	}
+	defer f.Close()
+	setFileHeaders(w, name)
+	http.ServeContent(w, r, name, time.Time{}, f)
+}
+
+// Return the SHA256 hash of an image and hash the file, if not yet hashed
+func imageSHA256(img common.Image) (string, error) {
+	if img.SHA256 != "" {
+		return img.SHA256, nil
+	}
+	return hashImage(img.CompactImage)
+}
+
+// Return the SHA256 hash of an image and hash the file, if not yet hashed
+func compactImageSHA256(img common.CompactImage) (h string, err error) {
+	h, err = db.GetSHA256(img.ID)
+	if err != nil || h != "" {
+		return
+	}
+	return hashImage(img)
+}
+
+// Compute and store the SHA256 hash of an image's source file
+func hashImage(img common.CompactImage) (h string, err error) {
+	f, err := os.Open(files.SourcePath(img.SHA1, img.Type))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	s := sha256.New()
+	_, err = io.Copy(s, f)
+	if err != nil {
+		return
+	}
+	h = hex.EncodeToString(s.Sum(nil))
+	err = db.SetSHA256(img.ID, h)
+	return
+}
+
+// Hash all images imported before SHA256 hashes were stored
+func hashImages() {
+	images, err := db.GetUnhashedImages()
+	if err != nil {
+		stderr.Printf("hashing files: %s\n", err)
+		return
+	}
+	for _, img := range images {
+		_, err = hashImage(img)
+		switch {
+		case err == nil:
+		case os.IsNotExist(err):
+			// Removed concurrently
+		default:
+			stderr.Printf("hashing files: %s: %s\n", img.SHA1, err)
+		}
+	}
+}
@@ -1,86 +1,92 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
-	"runtime"
 	"strconv"
 	"strings"
 
 	"github.com/bakape/hydron/common"
 	"github.com/bakape/hydron/db"
+	"github.com/bakape/hydron/fetch"
 	"github.com/bakape/hydron/files"
 	imp "github.com/bakape/hydron/import"
+	"github.com/bakape/hydron/sidecar"
+	"github.com/bakape/hydron/tags"
+	"github.com/bakape/hydron/util"
 )
 
 /*
-Import file/directory paths
+Import file/directory paths as a persistent import job
 paths: list for file and/or directory paths
 del: delete files after import
 fetchTags: also fetch tags from danbooru
 storeName: store the original filename as a tag
+archiveTags: tag archive members with the archive name and store their order
+link: hardlink or reflink files into place instead of copying, if possible
 tagStr: add string of tags to all imported files
 */
 func importPaths(
-	paths []string, del, fetchTags, storeName bool, tagStr string,
+	paths []string, del, fetchTags, storeName, archiveTags, link bool,
+	tagStr string,
 ) error {
-	paths, err := files.Traverse(paths)
+	j, err := createImportJob(paths, del, fetchTags, storeName, archiveTags,
+		link, tagStr)
 	if err != nil {
 		return err
 	}
 
-	// Buffer all paths into a channel
-	passPaths := make(chan string, len(paths))
-	for _, p := range paths {
-		passPaths <- p
-	}
-
-	// Process files in parallel
-	ch := make(chan Result)
-	n := runtime.NumCPU() + 1
-	for i := 0; i < n; i++ {
-		go func() {
-			for p := range passPaths {
-				res := new(Result)
-				_, res.err = importPath(p, del, fetchTags, storeName, tagStr)
-				ch <- *res
-			}
-		}()
-	}
-
 	// Aggregate and log results
 	p := progressLogger{
-		header: "importing, thumbnailing",
-		total:  len(paths),
+		header: fmt.Sprintf("import job %d: importing, thumbnailing", j.ID),
+		total:  j.Counts.Pending,
 	}
 	if fetchTags {
 		p.header += ", fetching tags"
 	}
-	for i := 0; i < len(paths); i++ {
-		store := new(Result)
-		*store = <-ch
-		err = store.err
-		if err != nil {
-			p.Err(err)
-		} else {
+	err = runImportJob(j, func(res Result) {
+		switch res.err {
+		case nil, imp.ErrImported:
 			p.Done()
+		default:
+			p.Err(fmt.Errorf("%s: %s", res.path, res.err))
 		}
-	}
-	close(passPaths) // Stop worker goroutines
+	})
 	p.Close()
-
-	return nil
+	return err
 }
 
+// Import a file path, archive member path or URL.
+// Returns imp.ErrImported, if the file is already imported.
 func importPath(
-	p string, del, fetchTags, storeName bool, tagStr string,
+	p string, del, fetchTags, storeName, archiveTags, link bool, tagStr string,
 ) (
 	img common.Image, err error,
 ) {
+	if util.IsFetchable(p) {
+		return importURL(p, fetchTags, storeName, tagStr)
+	}
+	if archive, member, ok := files.SplitArchivePath(p); ok {
+		files.ReadArchive(archive, []string{member}, func(
+			_ string, m files.ArchiveMember, readErr error,
+		) {
+			if readErr != nil {
+				err = readErr
+				return
+			}
+			img, err = importArchiveMember(m, archive, member, fetchTags,
+				storeName, archiveTags, tagStr)
+		})
+		return
+	}
+	tagStr = tags.AddPathTags(p, tagStr)
+
 	f, err := os.Open(p)
 	if err != nil {
 		return
@@ -91,10 +97,21 @@ func importPath(
 		return
 	}
 
+	// Broken sidecar files must not prevent importing the media file itself.
+	// Keep the sidecar files, as their metadata was not imported.
+	meta, sidecars, err := sidecar.Read(p)
+	if err != nil {
+		stderr.Printf("sidecar: %s\n", err)
+		meta = sidecar.Metadata{}
+		sidecars = nil
+	}
+
 	var name string
 	if storeName {
 		name = info.Name()
 		name = strings.TrimSuffix(name, filepath.Ext(name))
+	} else {
+		name = meta.Name
 	}
 	img, err = imp.ImportFile(
 		f,
@@ -102,26 +119,172 @@ func importPath(
 		name,
 		tagStr,
 		fetchTags,
+		link,
 	)
 
-	switch err {
-	case nil:
-	case imp.ErrImported:
-		err = nil
-	default:
-		err = fmt.Errorf("%s: %s", p, err)
+	if err != nil && err != imp.ErrImported {
 		return
 	}
+	if !meta.IsEmpty() {
+		if metaErr := addSidecarMetadata(&img, meta); metaErr != nil {
+			return img, metaErr
+		}
+	}
 
 	if del {
 		// Close file before removing
 		f.Close()
 		f = nil
-		err = os.Remove(p)
+		for _, p := range append(sidecar.Deletable(p, sidecars), p) {
+			if rmErr := os.Remove(p); rmErr != nil {
+				err = rmErr
+			}
+		}
 	}
 	return
 }
 
+// Add the tags and sources read from sidecar files to an imported image
+func addSidecarMetadata(img *common.Image, meta sidecar.Metadata) (
+	err error,
+) {
+	if img.ID == 0 {
+		// Already imported
+		img.ID, err = db.GetImageID(img.SHA1)
+		if err != nil {
+			return
+		}
+	}
+	if len(meta.Tags) != 0 {
+		var b db.Batch
+		b, err = db.NewBatch(db.ActorImport)
+		if err != nil {
+			return
+		}
+		err = db.AddTags(b, img.ID, meta.Tags)
+		if err != nil {
+			return
+		}
+	}
+	return db.AddSources(img.ID, meta.Sources...)
+}
+
+// Import a read member of an archive and close it. Archive members are never
+// deleted.
+func importArchiveMember(
+	m files.ArchiveMember, archive, member string,
+	fetchTags, storeName, archiveTags bool, tagStr string,
+) (
+	img common.Image, err error,
+) {
+	defer m.Close()
+	tagStr = tags.AddPathTags(files.ArchiveMemberPath(archive, member), tagStr)
+
+	var name string
+	if storeName {
+		name = path.Base(member)
+		name = strings.TrimSuffix(name, path.Ext(name))
+	}
+	img, err = imp.ImportFile(m, int(m.Size), name, tagStr, fetchTags, false)
+	if !archiveTags {
+		return
+	}
+	switch err {
+	case nil:
+	case imp.ErrImported:
+		img.ID, err = db.GetImageID(img.SHA1)
+		if err != nil {
+			return
+		}
+		// Still record the archive of an already imported file
+		defer func() {
+			if err == nil {
+				err = imp.ErrImported
+			}
+		}()
+	default:
+		return
+	}
+
+	archiveName := tags.NormalizeString(files.ArchiveName(archive))
+	b, err := db.NewBatch(db.ActorImport)
+	if err != nil {
+		return
+	}
+	err = db.AddTags(b, img.ID, []common.Tag{archiveTag(archiveName)})
+	if err != nil {
+		return
+	}
+	return img, db.AddArchiveMember(img.ID, common.ArchiveMember{
+		Archive:  archive,
+		Name:     archiveName,
+		Member:   member,
+		Position: m.Position,
+	})
+}
+
+// Tag added to members of an archive with a normalized name
+func archiveTag(name string) common.Tag {
+	return common.Tag{
+		TagBase: common.TagBase{
+			Type: common.Undefined,
+			Tag:  "archive:" + name,
+		},
+		Source: common.User,
+	}
+}
+
+// Download and import a file from a URL and store the URL as the file's
+// source
+func importURL(u string, fetchTags, storeName bool, tagStr string) (
+	img common.Image, err error,
+) {
+	f, err := fetch.DownloadFile(u)
+	if err != nil {
+		return
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return
+	}
+
+	var name string
+	if storeName {
+		name = urlFileName(u)
+	}
+	img, err = imp.ImportFile(
+		f,
+		int(info.Size()),
+		name,
+		tagStr,
+		fetchTags,
+		false,
+	)
+	switch err {
+	case nil:
+	case imp.ErrImported:
+		img.ID, err = db.GetImageID(img.SHA1)
+		if err != nil {
+			return
+		}
+		// Still record the URL of an already imported file
+		defer func() {
+			if err == nil {
+				err = imp.ErrImported
+			}
+		}()
+	default:
+		return
+	}
+
+	return img, db.AddSources(img.ID, common.Source{
+		URL:  u,
+		Kind: common.ImportSource,
+	})
+}
+
 // Download and import a file from the client
 func importUpload(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseMultipartForm(0)
@@ -159,6 +322,7 @@ func importUpload(w http.ResponseWriter, r *http.Request) {
 		name,
 		r.Form.Get("tags"),
 		r.Form.Get("fetch_tags") == "true",
+		false,
 	)
 
 	switch err {
@@ -181,8 +345,7 @@ func importUpload(w http.ResponseWriter, r *http.Request) {
 	serveJSON(w, r, img)
 }
 
-// Copy of importPaths that sends progress to client
-// Temporary name, think of a better one
+// Import paths as a persistent import job and stream progress to the client
 func clientImportPaths(
 	w http.ResponseWriter,
 	r *http.Request,
@@ -190,67 +353,49 @@ func clientImportPaths(
 	del bool,
 	fetchTags bool,
 	storeName bool,
+	archiveTags bool,
+	link bool,
 	tagStr string,
 ) error {
-	paths, err := files.Traverse(paths)
+	j, err := createImportJob(paths, del, fetchTags, storeName, archiveTags,
+		link, tagStr)
 	if err != nil {
 		return err
 	}
 
-	// Buffer all paths into a channel
-	passPaths := make(chan string, len(paths))
-	for _, p := range paths {
-		passPaths <- p
-	}
-
-	// Process files in parallel
-	ch := make(chan Result)
-	n := runtime.NumCPU() + 1
-	for i := 0; i < n; i++ {
-		go func() {
-			for p := range passPaths {
-				res := new(Result)
-				res.img, res.err = importPath(
-					p, del, fetchTags, storeName, tagStr,
-				)
-				ch <- *res
-			}
-		}()
-	}
-
 	flusher, ok := w.(http.Flusher)
 	if !ok {
 		log.Fatal("Failed to make HTTP Flusher.")
 	}
-	total := len(paths)
-
-	for i := 0; i < total; i++ {
-		store := new(Result)
-		*store = <-ch
-		err = store.err
-
-		switch err {
-		case nil:
-		case imp.ErrImported:
-		case imp.ErrUnsupportedFile:
-			sendError(w, 415, err)
-			return err
+	var (
+		total   = j.Counts.Pending
+		current int
+	)
+	return runImportJob(j, func(res Result) {
+		current++
+		c := Chunk{
+			SHA1:    res.img.SHA1,
+			Current: current,
+			Total:   total,
+			Job:     j.ID,
+		}
+		switch res.err {
+		case nil, imp.ErrImported:
 		default:
-			send500(w, r, err)
-			return err
+			c.SHA1 = ""
+			c.Error = fmt.Sprintf("%s: %s", res.path, res.err)
 		}
 
-		jsonChunk, err := json.Marshal(Chunk{store.img.SHA1, i + 1, total})
+		jsonChunk, err := json.Marshal(c)
 		if err != nil {
-			send500(w, r, err)
-			return err
+			stderr.Printf("server: %s: %s", r.RemoteAddr, err)
+			return
 		}
-		// Need to separate chunks, hyphen never appears in chunk content
-		// so is safe to use
+		// Need to separate chunks with hyphens. Escape hyphens in strings
+		// like error messages, so they never appear in chunk content.
+		jsonChunk = bytes.Replace(jsonChunk, []byte("-"), []byte(`\u002d`),
+			-1)
 		fmt.Fprintf(w, "%s-", jsonChunk)
 		flusher.Flush()
-	}
-	close(passPaths) // Stop worker goroutines
-
-	return nil
+	})
 }
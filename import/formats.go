@@ -0,0 +1,222 @@
+package imp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"io"
+	"math"
+
+	"github.com/bakape/thumbnailer/v2"
+	"github.com/gen2brain/avif"
+	"github.com/gen2brain/heic"
+	"github.com/srwiley/oksvg"
+	"github.com/srwiley/rasterx"
+	"golang.org/x/image/draw"
+)
+
+// Size of SVG images with no dimensions or view box
+const defaultSVGSize = 512
+
+// Register detection and thumbnailing of file types not supported by the
+// thumbnailer
+func init() {
+	thumbnailer.RegisterMatcher(thumbnailer.MatcherFunc(matchHEIF))
+	thumbnailer.RegisterMatcher(thumbnailer.MatcherFunc(matchJXL))
+	thumbnailer.RegisterMatcher(thumbnailer.MatcherFunc(matchSVG))
+
+	thumbnailer.RegisterProcessor("image/avif",
+		imageProcessor(avif.DecodeConfig, avif.Decode))
+	thumbnailer.RegisterProcessor("image/heic",
+		imageProcessor(heic.DecodeConfig, heic.Decode))
+	thumbnailer.RegisterProcessor("image/jxl", processFFmpegImage)
+	thumbnailer.RegisterProcessor("image/svg+xml", processSVG)
+}
+
+// Match AVIF and HEIC images by the brands of their ISO base media file
+// format "ftyp" box. Generic HEIF images are decoded as HEIC.
+func matchHEIF(data []byte) (mime, ext string) {
+	if len(data) < 16 || string(data[4:8]) != "ftyp" {
+		return
+	}
+	size := int(binary.BigEndian.Uint32(data))
+	if size < 16 || size > len(data) {
+		return
+	}
+
+	// Major brand, followed by the minor version and compatible brands
+	var heif bool
+	for i := 8; i+4 <= size; i += 4 {
+		if i == 12 {
+			continue
+		}
+		switch string(data[i : i+4]) {
+		case "avif", "avis":
+			return "image/avif", "avif"
+		case "heic", "heix", "heim", "heis", "hevc", "hevx", "mif1", "msf1":
+			heif = true
+		}
+	}
+	if heif {
+		return "image/heic", "heic"
+	}
+	return
+}
+
+// Match JPEG XL images as a bare codestream or in their ISO base media file
+// format container
+func matchJXL(data []byte) (mime, ext string) {
+	if bytes.HasPrefix(data, []byte("\xFF\x0A")) ||
+		bytes.HasPrefix(data, []byte("\x00\x00\x00\x0CJXL \x0D\x0A\x87\x0A")) {
+		return "image/jxl", "jxl"
+	}
+	return
+}
+
+// Match SVG images by their root element, optionally preceded by an XML
+// declaration, comments or a doctype
+func matchSVG(data []byte) (mime, ext string) {
+	data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+	data = bytes.TrimLeft(data, " \t\r\n")
+	for _, prefix := range [...]string{"<svg", "<?xml", "<!--", "<!DOCTYPE"} {
+		if bytes.HasPrefix(data, []byte(prefix)) {
+			if bytes.Contains(data, []byte("<svg")) {
+				return "image/svg+xml", "svg"
+			}
+			return
+		}
+	}
+	return
+}
+
+// Returns a processor for image formats decoded by decode. The dimensions are
+// read with decodeConfig and checked before decoding the image.
+func imageProcessor(
+	decodeConfig func(io.Reader) (image.Config, error),
+	decode func(io.Reader) (image.Image, error),
+) thumbnailer.Processor {
+	return func(rs io.ReadSeeker, src *thumbnailer.Source,
+		opts thumbnailer.Options,
+	) (
+		thumb image.Image, err error,
+	) {
+		conf, err := decodeConfig(rs)
+		if err != nil {
+			return
+		}
+		src.Dims = thumbnailer.Dims{
+			Width:  uint(conf.Width),
+			Height: uint(conf.Height),
+		}
+		err = checkSourceDims(src.Dims, opts)
+		if err != nil {
+			return
+		}
+
+		_, err = rs.Seek(0, 0)
+		if err != nil {
+			return
+		}
+		img, err := decode(rs)
+		if err != nil {
+			return
+		}
+		b := img.Bounds()
+
+		dims := fitDims(src.Dims, opts.ThumbDims)
+		if dims == src.Dims {
+			return img, nil
+		}
+		scaled := image.NewRGBA(image.Rect(0, 0, int(dims.Width),
+			int(dims.Height)))
+		draw.CatmullRom.Scale(scaled, scaled.Bounds(), img, b, draw.Src, nil)
+		return scaled, nil
+	}
+}
+
+// Thumbnail images, that have no Go decoder, with the thumbnailer's FFmpeg
+// bindings. Requires FFmpeg to be built with support for the format.
+func processFFmpegImage(rs io.ReadSeeker, src *thumbnailer.Source,
+	opts thumbnailer.Options,
+) (
+	thumb image.Image, err error,
+) {
+	c, err := thumbnailer.NewFFContext(rs)
+	if err != nil {
+		return
+	}
+	defer c.Close()
+
+	src.Dims, err = c.Dims()
+	if err != nil {
+		return
+	}
+	err = checkSourceDims(src.Dims, opts)
+	if err != nil {
+		return
+	}
+	return c.Thumbnail(opts.ThumbDims)
+}
+
+// Rasterize an SVG image directly at thumbnail size
+func processSVG(rs io.ReadSeeker, src *thumbnailer.Source,
+	opts thumbnailer.Options,
+) (
+	thumb image.Image, err error,
+) {
+	icon, err := oksvg.ReadIconStream(rs, oksvg.IgnoreErrorMode)
+	if err != nil {
+		return
+	}
+	if icon.ViewBox.W <= 0 || icon.ViewBox.H <= 0 {
+		icon.ViewBox.W = defaultSVGSize
+		icon.ViewBox.H = defaultSVGSize
+	}
+	src.Dims = thumbnailer.Dims{
+		Width:  uint(math.Ceil(icon.ViewBox.W)),
+		Height: uint(math.Ceil(icon.ViewBox.H)),
+	}
+
+	dims := fitDims(src.Dims, opts.ThumbDims)
+	w, h := int(dims.Width), int(dims.Height)
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	icon.SetTarget(0, 0, float64(w), float64(h))
+	icon.Draw(
+		rasterx.NewDasher(w, h, rasterx.NewScannerGV(w, h, img, img.Bounds())),
+		1,
+	)
+	return img, nil
+}
+
+// Reject sources exceeding the maximum source dimensions
+func checkSourceDims(dims thumbnailer.Dims, opts thumbnailer.Options) error {
+	max := opts.MaxSourceDims
+	switch {
+	case max.Width != 0 && dims.Width > max.Width:
+		return thumbnailer.ErrTooWide
+	case max.Height != 0 && dims.Height > max.Height:
+		return thumbnailer.ErrTooTall
+	default:
+		return nil
+	}
+}
+
+// Scale dims down to fit into box, preserving the aspect ratio
+func fitDims(dims, box thumbnailer.Dims) thumbnailer.Dims {
+	if dims.Width <= box.Width && dims.Height <= box.Height {
+		return dims
+	}
+	scale := math.Min(float64(box.Width)/float64(dims.Width),
+		float64(box.Height)/float64(dims.Height))
+	out := thumbnailer.Dims{
+		Width:  uint(float64(dims.Width) * scale),
+		Height: uint(float64(dims.Height) * scale),
+	}
+	if out.Width == 0 {
+		out.Width = 1
+	}
+	if out.Height == 0 {
+		out.Height = 1
+	}
+	return out
+}
@@ -0,0 +1,196 @@
+package imp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/gif"
+	"io"
+	"time"
+)
+
+const (
+	// GIFs with more frames or larger dimensions get no preview
+	maxGIFFrames = 5000
+	maxGIFSize   = 4096
+
+	// GIF block introducers
+	gifExtension  = 0x21
+	gifImage      = 0x2C
+	gifTrailer    = 0x3B
+	gifControlExt = 0xF9
+)
+
+var errInvalidGIF = errors.New("invalid GIF")
+
+// Location of a single frame in a GIF file. Frames are decoded one at a time,
+// as decoding all frames at once can take huge amounts of memory.
+type gifFrame struct {
+	// Graphic control extension block of the frame, if any
+	control []byte
+	// Offset and length of the frame's image descriptor, color table and
+	// image data
+	offset, length int64
+	delay          time.Duration
+	disposal       byte
+}
+
+// Decode a single frame. The frame is located in a GIF file at the same
+// offset as in r.
+// head: header, logical screen descriptor and global color table of the file
+func (f gifFrame) decode(r io.ReaderAt, head []byte) (image.Image, error) {
+	buf := make([]byte, 0, len(head)+len(f.control)+int(f.length)+1)
+	buf = append(buf, head...)
+	buf = append(buf, f.control...)
+	buf = buf[:len(buf)+int(f.length)]
+	_, err := r.ReadAt(buf[len(buf)-int(f.length):], f.offset)
+	if err != nil {
+		return nil, err
+	}
+	return gif.Decode(bytes.NewReader(append(buf, gifTrailer)))
+}
+
+// Reads the block structure of a GIF file without decoding any image data
+type gifScanner struct {
+	r   *bufio.Reader
+	pos int64
+}
+
+// Read n bytes
+func (s *gifScanner) read(n int) (buf []byte, err error) {
+	buf = make([]byte, n)
+	_, err = io.ReadFull(s.r, buf)
+	s.pos += int64(n)
+	return
+}
+
+// Skip a sequence of data sub-blocks up to and including the terminator
+func (s *gifScanner) skipSubBlocks() (err error) {
+	for {
+		var n byte
+		n, err = s.r.ReadByte()
+		if err != nil {
+			return
+		}
+		s.pos++
+		if n == 0 {
+			return
+		}
+		_, err = s.r.Discard(int(n))
+		if err != nil {
+			return
+		}
+		s.pos += int64(n)
+	}
+}
+
+// Read the block structure of a GIF file.
+// Returns the header, logical screen descriptor and global color table of
+// the file, its dimensions and the locations of all its frames.
+func scanGIF(r io.Reader) (
+	head []byte, width, height int, frames []gifFrame, err error,
+) {
+	s := gifScanner{r: bufio.NewReader(r)}
+	head, err = s.read(13)
+	if err != nil {
+		return
+	}
+	if string(head[:6]) != "GIF87a" && string(head[:6]) != "GIF89a" {
+		err = errInvalidGIF
+		return
+	}
+	width = int(binary.LittleEndian.Uint16(head[6:]))
+	height = int(binary.LittleEndian.Uint16(head[8:]))
+	if head[10]&0x80 != 0 {
+		var table []byte
+		table, err = s.read(3 << (head[10]&7 + 1))
+		if err != nil {
+			return
+		}
+		head = append(head, table...)
+	}
+
+	var control []byte
+	for {
+		var b byte
+		b, err = s.r.ReadByte()
+		if err != nil {
+			return
+		}
+		s.pos++
+
+		switch b {
+		case gifTrailer:
+			return
+		case gifExtension:
+			var label byte
+			label, err = s.r.ReadByte()
+			if err != nil {
+				return
+			}
+			s.pos++
+			if label != gifControlExt {
+				err = s.skipSubBlocks()
+				if err != nil {
+					return
+				}
+				continue
+			}
+			// Block size, flags, delay, transparent color index and
+			// terminator
+			control, err = s.read(6)
+			if err != nil {
+				return
+			}
+			if control[0] != 4 || control[5] != 0 {
+				err = errInvalidGIF
+				return
+			}
+			control = append([]byte{gifExtension, gifControlExt}, control...)
+		case gifImage:
+			if len(frames) == maxGIFFrames {
+				err = errors.New("too many GIF frames")
+				return
+			}
+			f := gifFrame{
+				control: control,
+				offset:  s.pos - 1,
+			}
+			if control != nil {
+				f.disposal = control[3] >> 2 & 7
+				f.delay = time.Duration(binary.LittleEndian.Uint16(
+					control[4:])) * 10 * time.Millisecond
+			}
+			control = nil
+
+			var desc []byte
+			desc, err = s.read(9)
+			if err != nil {
+				return
+			}
+			if desc[8]&0x80 != 0 {
+				// Local color table
+				_, err = s.read(3 << (desc[8]&7 + 1))
+				if err != nil {
+					return
+				}
+			}
+			// LZW minimum code size
+			_, err = s.read(1)
+			if err != nil {
+				return
+			}
+			err = s.skipSubBlocks()
+			if err != nil {
+				return
+			}
+			f.length = s.pos - f.offset
+			frames = append(frames, f)
+		default:
+			err = errInvalidGIF
+			return
+		}
+	}
+}
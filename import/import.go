@@ -3,6 +3,7 @@ package imp
 import (
 	"crypto/md5"
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"hash"
@@ -17,9 +18,9 @@ import (
 	"github.com/bakape/hydron/db"
 	"github.com/bakape/hydron/fetch"
 	"github.com/bakape/hydron/files"
+	"github.com/bakape/hydron/metadata"
 	"github.com/bakape/hydron/tags"
 	"github.com/bakape/thumbnailer/v2"
-	"github.com/chai2010/webp"
 )
 
 // Common errors
@@ -34,23 +35,14 @@ var (
 			return make([]byte, 512)
 		},
 	}
-
-	thumbnailerOpts = thumbnailer.Options{
-		ThumbDims: thumbnailer.Dims{
-			Width:  200,
-			Height: 200,
-		},
-		AcceptedMimeTypes: common.AllowedMimes,
-	}
-	webpOpts = webp.Options{
-		Quality: 90,
-	}
 )
 
 type request struct {
 	f       io.ReadSeeker
 	size    int
 	addTags string
+	link    bool
+	batch   db.Batch
 	res     chan<- response
 }
 
@@ -67,7 +59,13 @@ func init() {
 			runtime.LockOSThread()
 			for {
 				req := <-importFile
-				img, err := doImport(req.f, req.size, req.addTags)
+				img, err := doImport(
+					req.f,
+					req.size,
+					req.addTags,
+					req.link,
+					req.batch,
+				)
 				req.res <- response{
 					Image: img,
 					err:   err,
@@ -108,8 +106,10 @@ func hashFile(rs io.ReadSeeker, h hash.Hash) (
 	}
 }
 
-// Worker function for file importing
-func doImport(f io.ReadSeeker, size int, addTags string,
+// Worker function for file importing.
+// link: link files on disk into place instead of copying them, if possible
+func doImport(f io.ReadSeeker, size int, addTags string, link bool,
+	b db.Batch,
 ) (
 	r common.Image, err error,
 ) {
@@ -132,7 +132,7 @@ func doImport(f io.ReadSeeker, size int, addTags string,
 		return
 	}
 
-	src, thumb, err := thumbnailer.Process(f, thumbnailerOpts)
+	src, thumb, err := thumbnailer.Process(f, thumbnailerOptions())
 	switch err {
 	case nil:
 	case thumbnailer.ErrCantThumbnail:
@@ -149,6 +149,10 @@ func doImport(f io.ReadSeeker, size int, addTags string,
 	if err != nil {
 		return
 	}
+	r.SHA256, _, err = hashFile(f, sha256.New())
+	if err != nil {
+		return
+	}
 	bounds := thumb.Bounds()
 	r.CompactImage = common.CompactImage{
 		Type: common.MimeTypes[src.Mime],
@@ -166,40 +170,84 @@ func doImport(f io.ReadSeeker, size int, addTags string,
 	r.Duration = uint64(src.Length / time.Second)
 	r.Tags = tags.FromString(addTags, common.User)
 
+	// Embedded metadata is optional, so files with unreadable metadata are
+	// still imported
+	if meta, err := metadata.Read(f, r.Type); err == nil {
+		r.Taken = meta.TakenUnix()
+		r.Metadata = meta.Fields()
+	}
+
 	// Encode thumbnail and dump source file concurrently
 	ch := make(chan error)
 	go func() {
-		ch <- func() (err error) {
-			f, err := createFile(files.ThumbPath(r.SHA1))
-			if err != nil {
-				return
-			}
-			defer f.Close()
-
-			return webp.Encode(f, thumb, &webpOpts)
-		}()
+		_, err := writeThumbnails(r.SHA1, thumb)
+		ch <- err
 	}()
 
-	_, err = f.Seek(0, 0)
+	dst := files.SourcePath(r.SHA1, r.Type)
+	linked := false
+	if file, ok := f.(*os.File); ok && link {
+		// Fall back to copying, if the file is on a different file system
+		linked = files.LinkFile(file.Name(), dst) == nil
+	}
+	if !linked {
+		err = copySource(f, dst)
+		if err != nil {
+			return
+		}
+	}
+
+	err = <-ch
 	if err != nil {
 		return
 	}
-	srcDest, err := createFile(files.SourcePath(r.SHA1, r.Type))
+	r.Preview = writePreview(r.SHA1, r.Type, dst, src.Length)
+
+	r.ID, err = db.WriteImage(b, r)
+	return
+}
+
+// Hash a file and detect its type without importing it. Returns
+// ErrUnsupportedFile, if the type is not supported, or ErrImported with sha1
+// set, if the file is already imported.
+func Inspect(f io.ReadSeeker) (
+	sha1Hash string, size int, typ common.FileType, err error,
+) {
+	sha1Hash, size, err = hashFile(f, sha1.New())
 	if err != nil {
 		return
 	}
-	defer srcDest.Close()
-	_, err = io.Copy(srcDest, f)
+	mime, _, err := thumbnailer.DetectMIME(f, common.AllowedMimes)
 	if err != nil {
+		if _, ok := err.(thumbnailer.ErrUnsupportedMIME); ok {
+			err = ErrUnsupportedFile
+		}
 		return
 	}
+	typ = common.MimeTypes[mime]
 
-	err = <-ch
+	isImported, err := db.IsImported(sha1Hash)
 	if err != nil {
 		return
 	}
+	if isImported {
+		err = ErrImported
+	}
+	return
+}
 
-	r.ID, err = db.WriteImage(r)
+// Copy an imported file into the image store
+func copySource(f io.ReadSeeker, dst string) (err error) {
+	_, err = f.Seek(0, 0)
+	if err != nil {
+		return
+	}
+	w, err := createFile(dst)
+	if err != nil {
+		return
+	}
+	defer w.Close()
+	_, err = io.Copy(w, f)
 	return
 }
 
@@ -223,11 +271,24 @@ f: stream to read
 size: estimated file size
 addTags: Add a list of tags to all images
 fetchTags: fetch tags from danbooru
+link: hardlink or reflink f into the image store instead of copying it, if f is
+a file on the same file system. Hardlinked files must not be modified after
+importing.
 */
-func ImportFile(f io.ReadSeeker, size int, name string, addTags string, fetchTags bool,
+func ImportFile(f io.ReadSeeker, size int, name string, addTags string,
+	fetchTags, link bool,
 ) (r common.Image, err error) {
+	// Only allocate a tag change batch, if any tags can be added
+	var b db.Batch
+	if addTags != "" || fetchTags {
+		b, err = db.NewBatch(db.ActorImport)
+		if err != nil {
+			return
+		}
+	}
+
 	ch := make(chan response)
-	importFile <- request{f, size, addTags, ch}
+	importFile <- request{f, size, addTags, link, b, ch}
 	res := <-ch
 	r = res.Image
 	err = res.err
@@ -248,16 +309,26 @@ func ImportFile(f io.ReadSeeker, size int, name string, addTags string, fetchTag
 	r.Name = name
 
 	if fetchTags && fetch.CanFetchTags(r.Type) {
-		var fetched []common.Tag
-		fetched, err = fetch.FetchTags(r.MD5)
+		var post *fetch.Post
+		post, err = fetch.FetchPost(r.MD5, r.Type)
 		if err != nil {
 			return
 		}
-		err = db.AddTags(r.ID, fetched)
+		if post != nil {
+			err = db.AddTags(b, r.ID, post.Tags)
+			if err != nil {
+				return
+			}
+			err = db.AddSources(r.ID, post.Sources()...)
+			if err != nil {
+				return
+			}
+			r.Tags = append(r.Tags, post.Tags...)
+		}
+		err = db.SetFetchState(r.ID, post != nil)
 		if err != nil {
 			return
 		}
-		r.Tags = append(r.Tags, fetched...)
 	}
 
 	return
@@ -0,0 +1,330 @@
+package imp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/png"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/bakape/hydron/common"
+	"github.com/bakape/hydron/files"
+	"github.com/chai2010/webp"
+	"golang.org/x/image/draw"
+)
+
+const (
+	// Maximum number of frames sampled from animated media for previews
+	previewFrames = 10
+	// Display time of each sampled video frame
+	previewFrameDelay = 400 * time.Millisecond
+	// GIF frames with no delay are displayed this long by most browsers
+	defaultGIFDelay = 100 * time.Millisecond
+	// Maximum time to spend extracting video frames
+	videoPreviewTimeout = time.Minute
+)
+
+// Single frame of an animated preview
+type previewFrame struct {
+	img   image.Image
+	delay time.Duration
+}
+
+/*
+Generate an animated WEBP hover preview of a GIF or video and write it to
+files.PreviewPath. Returns, if a preview was written. Previews are optional, so
+media that can not be previewed is not an error.
+path: path to the source file
+length: duration of a video
+*/
+func writePreview(id string, typ common.FileType, path string,
+	length time.Duration,
+) bool {
+	var (
+		frames []previewFrame
+		err    error
+	)
+	switch {
+	case typ == common.GIF:
+		frames, err = gifFrames(path)
+	case common.GetMediaType(typ) == common.MediaVideo:
+		frames, err = videoFrames(path, length)
+	}
+	if err != nil || len(frames) < 2 {
+		return false
+	}
+
+	dst := files.PreviewPath(id)
+	tmp := dst + ".tmp"
+	f, err := createFile(tmp)
+	if err != nil {
+		return false
+	}
+	err = encodeAnimatedWEBP(f, frames)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, dst)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return false
+	}
+	return true
+}
+
+// Remove an image's preview, if any
+func removePreview(id string) error {
+	err := os.Remove(files.PreviewPath(id))
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return err
+}
+
+// Dimensions of preview frames scaled down from a source of dims
+func previewDims(dims common.Dims) common.Dims {
+	return files.ScaleThumbDims(dims, files.GetThumbConfig().Default())
+}
+
+// Decode and sample frames of an animated GIF
+func gifFrames(path string) (frames []previewFrame, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	head, width, height, gifFrames, err := scanGIF(f)
+	if err != nil || len(gifFrames) < 2 {
+		return
+	}
+	if width > maxGIFSize || height > maxGIFSize {
+		return
+	}
+
+	// GIF frames only contain the changed area, so draw them on a canvas
+	canvasRect := image.Rect(0, 0, width, height)
+	canvas := image.NewRGBA(canvasRect)
+	dims := previewDims(common.Dims{
+		Width:  uint64(width),
+		Height: uint64(height),
+	})
+
+	// Sample frames evenly and display each for the duration of the frames
+	// it replaces
+	step := (len(gifFrames) + previewFrames - 1) / previewFrames
+	var prev *image.RGBA
+	for i, gf := range gifFrames {
+		var frame image.Image
+		frame, err = gf.decode(f, head)
+		if err != nil {
+			return nil, err
+		}
+		if gf.disposal == gif.DisposalPrevious {
+			prev = image.NewRGBA(canvasRect)
+			draw.Draw(prev, canvasRect, canvas, image.Point{}, draw.Src)
+		}
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min,
+			draw.Over)
+
+		delay := gf.delay
+		if delay == 0 {
+			delay = defaultGIFDelay
+		}
+		if i%step == 0 {
+			frames = append(frames, previewFrame{
+				img: scalePreviewFrame(canvas, dims),
+			})
+		}
+		frames[len(frames)-1].delay += delay
+
+		switch gf.disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent,
+				image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = prev
+		}
+	}
+	return
+}
+
+// Copy and scale a frame to the preview dimensions
+func scalePreviewFrame(src image.Image, dims common.Dims) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, int(dims.Width), int(dims.Height)))
+	draw.ApproxBiLinear.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Src,
+		nil)
+	return dst
+}
+
+// Sample frames evenly across a video. Requires the ffmpeg executable, as the
+// thumbnailer only decodes a single frame.
+func videoFrames(path string, length time.Duration) (
+	frames []previewFrame, err error,
+) {
+	if length <= 0 {
+		return
+	}
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(),
+		videoPreviewTimeout)
+	defer cancel()
+	size := files.GetThumbConfig().Default()
+	cmd := exec.CommandContext(ctx, ffmpeg,
+		"-v", "error",
+		"-i", path,
+		"-an",
+		"-vf", fmt.Sprintf(
+			"fps=%f,scale=%d:%d:force_original_aspect_ratio=decrease",
+			previewFrames/length.Seconds(), size, size,
+		),
+		"-frames:v", strconv.Itoa(previewFrames),
+		"-f", "image2pipe",
+		"-c:v", "png",
+		"pipe:1",
+	)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return
+	}
+	err = cmd.Start()
+	if err != nil {
+		return
+	}
+
+	// PNG images are written back to back
+	r := bufio.NewReader(out)
+	for {
+		var img image.Image
+		img, err = png.Decode(r)
+		if err != nil {
+			break
+		}
+		frames = append(frames, previewFrame{img, previewFrameDelay})
+	}
+	io.Copy(io.Discard, r)
+	if waitErr := cmd.Wait(); waitErr != nil {
+		return nil, waitErr
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	return
+}
+
+// Encode frames as an animated WEBP image. The frames are encoded separately
+// and muxed into an animated container, as the WEBP encoder only produces
+// still images.
+func encodeAnimatedWEBP(w io.Writer, frames []previewFrame) (err error) {
+	var (
+		body          bytes.Buffer
+		width, height int
+		frame         bytes.Buffer
+		anmf          []byte
+	)
+	quality := float32(files.GetThumbConfig().Quality)
+	for _, f := range frames {
+		b := f.img.Bounds()
+		if b.Dx() > width {
+			width = b.Dx()
+		}
+		if b.Dy() > height {
+			height = b.Dy()
+		}
+
+		frame.Reset()
+		err = webp.Encode(&frame, f.img, &webp.Options{
+			Quality: quality,
+		})
+		if err != nil {
+			return
+		}
+
+		// Frame header followed by the image data chunks of the still image
+		anmf = anmf[:0]
+		anmf = append(anmf, 0, 0, 0, 0, 0, 0) // X and Y offsets
+		anmf = appendUint24(anmf, uint32(b.Dx()-1))
+		anmf = appendUint24(anmf, uint32(b.Dy()-1))
+		anmf = appendUint24(anmf, uint32(f.delay/time.Millisecond))
+		anmf = append(anmf, 0x02) // Overwrite the previous frame
+		anmf, err = appendImageChunks(anmf, frame.Bytes())
+		if err != nil {
+			return
+		}
+		writeChunk(&body, "ANMF", anmf)
+	}
+
+	// Animation flag and alpha channel flag, as GIFs may be transparent
+	vp8x := []byte{0x12, 0, 0, 0}
+	vp8x = appendUint24(vp8x, uint32(width-1))
+	vp8x = appendUint24(vp8x, uint32(height-1))
+
+	var head bytes.Buffer
+	writeChunk(&head, "VP8X", vp8x)
+	// Transparent background and infinite looping
+	writeChunk(&head, "ANIM", []byte{0, 0, 0, 0, 0, 0})
+
+	riff := make([]byte, 12)
+	copy(riff, "RIFF")
+	binary.LittleEndian.PutUint32(riff[4:],
+		uint32(4+head.Len()+body.Len()))
+	copy(riff[8:], "WEBP")
+	for _, b := range [...][]byte{riff, head.Bytes(), body.Bytes()} {
+		_, err = w.Write(b)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Append the ALPH, VP8 and VP8L chunks of a still WEBP image to buf
+func appendImageChunks(buf, img []byte) ([]byte, error) {
+	if len(img) < 12 || string(img[:4]) != "RIFF" ||
+		string(img[8:12]) != "WEBP" {
+		return nil, fmt.Errorf("invalid WEBP image")
+	}
+	for i := 12; i+8 <= len(img); {
+		size := int(binary.LittleEndian.Uint32(img[i+4:]))
+		end := i + 8 + size + size&1
+		if end > len(img) {
+			return nil, fmt.Errorf("invalid WEBP chunk size")
+		}
+		switch string(img[i : i+4]) {
+		case "ALPH", "VP8 ", "VP8L":
+			buf = append(buf, img[i:end]...)
+		}
+		i = end
+	}
+	return buf, nil
+}
+
+// Write a RIFF chunk padded to an even size
+func writeChunk(w *bytes.Buffer, fourCC string, data []byte) {
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(data)))
+	w.WriteString(fourCC)
+	w.Write(size[:])
+	w.Write(data)
+	if len(data)&1 != 0 {
+		w.WriteByte(0)
+	}
+}
+
+// Append a 24 bit little endian integer
+func appendUint24(buf []byte, i uint32) []byte {
+	return append(buf, byte(i), byte(i>>8), byte(i>>16))
+}
@@ -0,0 +1,159 @@
+package imp
+
+import (
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"os"
+	"runtime"
+
+	"github.com/bakape/hydron/common"
+	"github.com/bakape/hydron/db"
+	"github.com/bakape/hydron/files"
+	"github.com/bakape/thumbnailer/v2"
+	"github.com/chai2010/webp"
+	"github.com/gen2brain/avif"
+	"golang.org/x/image/draw"
+)
+
+// JPEG has no transparency, so transparent areas are drawn on the background
+// color of the search page
+var jpegBackground = color.RGBA{0x1d, 0x1f, 0x21, 0xff}
+
+// Thumbnailer options for generating thumbnails of the largest configured
+// size
+func thumbnailerOptions() thumbnailer.Options {
+	size := files.GetThumbConfig().Largest()
+	return thumbnailer.Options{
+		ThumbDims: thumbnailer.Dims{
+			Width:  size,
+			Height: size,
+		},
+		AcceptedMimeTypes: common.AllowedMimes,
+	}
+}
+
+// Encode a thumbnail in the configured format and quality
+func encodeThumbnail(w io.Writer, img image.Image) error {
+	conf := files.GetThumbConfig()
+	switch conf.Format {
+	case files.JPEG:
+		bg := image.NewRGBA(img.Bounds())
+		draw.Draw(bg, bg.Bounds(), image.NewUniform(jpegBackground),
+			image.Point{}, draw.Src)
+		draw.Draw(bg, bg.Bounds(), img, img.Bounds().Min, draw.Over)
+		return jpeg.Encode(w, bg, &jpeg.Options{
+			Quality: conf.Quality,
+		})
+	case files.AVIF:
+		return avif.Encode(w, img, avif.Options{
+			Quality:           conf.Quality,
+			QualityAlpha:      conf.Quality,
+			Speed:             avif.DefaultSpeed,
+			ChromaSubsampling: image.YCbCrSubsampleRatio420,
+		})
+	default:
+		return webp.Encode(w, img, &webp.Options{
+			Quality: float32(conf.Quality),
+		})
+	}
+}
+
+// Encode and write a thumbnail to path. The thumbnail is written to a
+// temporary file first to keep any previous thumbnail on failure.
+func writeThumbnail(path string, img image.Image) (err error) {
+	tmp := path + ".tmp"
+	f, err := createFile(tmp)
+	if err != nil {
+		return
+	}
+	err = encodeThumbnail(f, img)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return
+}
+
+// Write thumbnails of all configured sizes scaled down from a thumbnail of the
+// largest size and return the dimensions of the largest thumbnail
+func writeThumbnails(id string, thumb image.Image) (
+	dims common.Dims, err error,
+) {
+	b := thumb.Bounds()
+	dims = common.Dims{
+		Width:  uint64(b.Dx()),
+		Height: uint64(b.Dy()),
+	}
+	for _, size := range files.GetThumbConfig().Sizes {
+		img := thumb
+		d := files.ScaleThumbDims(dims, size)
+		if d != dims {
+			scaled := image.NewRGBA(image.Rect(0, 0, int(d.Width),
+				int(d.Height)))
+			draw.CatmullRom.Scale(scaled, scaled.Bounds(), thumb, b, draw.Src,
+				nil)
+			img = scaled
+		}
+		err = writeThumbnail(files.ThumbPath(id, size), img)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Generate the thumbnail and preview of an imported file again with the
+// current thumbnail settings and store its new dimensions
+func RegenerateThumbnail(img common.CompactImage) (err error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	path := files.SourcePath(img.SHA1, img.Type)
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	src, thumb, err := thumbnailer.Process(f, thumbnailerOptions())
+	if err != nil {
+		return
+	}
+
+	// Remove thumbnails in previously configured sizes and formats
+	old, err := files.AllThumbPaths(img.SHA1)
+	if err != nil {
+		return
+	}
+	dims, err := writeThumbnails(img.SHA1, thumb)
+	if err != nil {
+		return
+	}
+	current := make(map[string]bool)
+	for _, size := range files.GetThumbConfig().Sizes {
+		current[files.ThumbPath(img.SHA1, size)] = true
+	}
+	for _, p := range old {
+		if !current[p] {
+			err = os.Remove(p)
+			if err != nil && !os.IsNotExist(err) {
+				return
+			}
+		}
+	}
+
+	preview := writePreview(img.SHA1, img.Type, path, src.Length)
+	if !preview {
+		err = removePreview(img.SHA1)
+		if err != nil {
+			return
+		}
+	}
+	return db.SetThumbnail(img.ID, dims, preview)
+}
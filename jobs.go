@@ -0,0 +1,283 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/bakape/hydron/common"
+	"github.com/bakape/hydron/db"
+	"github.com/bakape/hydron/files"
+	imp "github.com/bakape/hydron/import"
+	"github.com/bakape/hydron/sidecar"
+)
+
+const (
+	// Interval of updating the heartbeat of a running import job
+	importJobHeartbeat = 10 * time.Second
+	// Unfinished import jobs without a heartbeat for this long have been
+	// abandoned by a crashed or killed process and are resumed by the server
+	importJobTimeout = time.Minute
+)
+
+// Identifies this process as the owner of the import jobs it runs
+var jobOwner = newJobOwner()
+
+func newJobOwner() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s:%d:%d", host, os.Getpid(), time.Now().UnixNano())
+}
+
+// Traverse paths and return all files, archive members and URLs to import
+func traverseImportPaths(paths []string) ([]string, error) {
+	paths, err := files.Traverse(paths)
+	if err != nil {
+		return nil, err
+	}
+	// Sidecar files are imported together with their media files
+	filtered := paths[:0]
+	for _, p := range paths {
+		if !sidecar.IsSidecar(p) {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered, nil
+}
+
+// Traverse paths and store them as a new import job
+func createImportJob(
+	paths []string, del, fetchTags, storeName, archiveTags, link bool,
+	tagStr string,
+) (
+	j common.ImportJob, err error,
+) {
+	paths, err = traverseImportPaths(paths)
+	if err != nil {
+		return
+	}
+	j = common.ImportJob{
+		Delete:      del,
+		FetchTags:   fetchTags,
+		StoreName:   storeName,
+		ArchiveTags: archiveTags,
+		Link:        link,
+		Tags:        tagStr,
+	}
+	id, err := db.CreateImportJob(j, paths, jobOwner)
+	if err != nil {
+		return
+	}
+	return db.GetImportJob(id)
+}
+
+// Import all pending paths of an import job, record their results and mark
+// the job as finished. The job must be owned by this process.
+// onResult: called with the result of each imported path
+func runImportJob(j common.ImportJob, onResult func(Result)) (err error) {
+	stop := make(chan struct{})
+	defer close(stop)
+	go keepImportJobAlive(j.ID, stop)
+
+	paths, err := db.GetPendingImportPaths(j.ID)
+	if err != nil {
+		return
+	}
+
+	// Process files in parallel
+	tasks := make(chan importTask)
+	go sendImportTasks(paths, tasks)
+	ch := make(chan Result)
+	n := runtime.NumCPU() + 1
+	for i := 0; i < n; i++ {
+		go func() {
+			for t := range tasks {
+				res := Result{path: t.path}
+				switch {
+				case t.err != nil:
+					res.err = t.err
+				case t.isMember:
+					res.img, res.err = importArchiveMember(t.member, t.archive,
+						t.memberName, j.FetchTags, j.StoreName, j.ArchiveTags,
+						j.Tags)
+				default:
+					res.img, res.err = importPath(t.path, j.Delete, j.FetchTags,
+						j.StoreName, j.ArchiveTags, j.Link, j.Tags)
+				}
+				ch <- res
+			}
+		}()
+	}
+
+	for range paths {
+		res := <-ch
+		p := common.ImportJobPath{
+			Path:   res.path,
+			Status: importStatus(res.err),
+		}
+		if p.Status >= common.ImportUnsupported {
+			p.Error = res.err.Error()
+		}
+		// Keep importing on database errors, so the results of the remaining
+		// paths are at least reported
+		if dbErr := db.SetImportStatus(j.ID, p); dbErr != nil && err == nil {
+			err = dbErr
+		}
+		onResult(res)
+	}
+	if err != nil {
+		return
+	}
+	return db.FinishImportJob(j.ID)
+}
+
+// Path to import or inspect
+type importTask struct {
+	path string
+	// Read member, if path is an archive member. Must be closed after use.
+	isMember            bool
+	member              files.ArchiveMember
+	archive, memberName string
+	// Error reading the archive member
+	err error
+}
+
+// Send paths to ch and close it. Archive members are sent after all other
+// paths, grouped by archive and read in a single pass over each archive.
+func sendImportTasks(paths []string, ch chan<- importTask) {
+	defer close(ch)
+
+	var archives []string
+	members := make(map[string][]string)
+	for _, p := range paths {
+		archive, member, ok := files.SplitArchivePath(p)
+		if !ok {
+			ch <- importTask{path: p}
+			continue
+		}
+		if _, ok := members[archive]; !ok {
+			archives = append(archives, archive)
+		}
+		members[archive] = append(members[archive], member)
+	}
+
+	for _, archive := range archives {
+		files.ReadArchive(archive, members[archive], func(
+			member string, m files.ArchiveMember, err error,
+		) {
+			ch <- importTask{
+				path:       files.ArchiveMemberPath(archive, member),
+				isMember:   err == nil,
+				member:     m,
+				archive:    archive,
+				memberName: member,
+				err:        err,
+			}
+		})
+	}
+}
+
+// Map the error returned by importPath to an import status
+func importStatus(err error) common.ImportStatus {
+	switch err {
+	case nil:
+		return common.ImportImported
+	case imp.ErrImported:
+		return common.ImportDuplicate
+	case imp.ErrUnsupportedFile:
+		return common.ImportUnsupported
+	default:
+		return common.ImportError
+	}
+}
+
+// Update the heartbeat of a running import job, until stop is closed
+func keepImportJobAlive(id int64, stop <-chan struct{}) {
+	t := time.NewTicker(importJobHeartbeat)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			owned, err := db.UpdateImportJobHeartbeat(id, jobOwner)
+			switch {
+			case err != nil:
+				stderr.Printf("import job %d: %s\n", id, err)
+			case !owned:
+				stderr.Printf("import job %d: claimed by another process\n",
+					id)
+				return
+			}
+		}
+	}
+}
+
+// Periodically resume import jobs abandoned by crashed or killed processes.
+// Jobs still run by other processes are left alone.
+func resumeImportJobs() {
+	for {
+		jobs, err := db.GetStaleImportJobs(time.Now().Add(-importJobTimeout))
+		if err != nil {
+			stderr.Printf("import jobs: %s\n", err)
+		}
+		for _, j := range jobs {
+			claimed, err := db.ClaimImportJob(j.ID, jobOwner,
+				time.Now().Add(-importJobTimeout))
+			if err != nil {
+				stderr.Printf("import job %d: %s\n", j.ID, err)
+				continue
+			}
+			if !claimed {
+				continue
+			}
+			stderr.Printf("resuming import job %d\n", j.ID)
+			err = runImportJob(j, func(Result) {})
+			if err != nil {
+				stderr.Printf("import job %d: %s\n", j.ID, err)
+			}
+		}
+		time.Sleep(importJobTimeout)
+	}
+}
+
+// Print all import jobs
+func listImportJobs() error {
+	jobs, err := db.GetImportJobs()
+	if err != nil {
+		return err
+	}
+	for _, j := range jobs {
+		state := "unfinished"
+		if j.Finished != 0 {
+			state = "finished " +
+				time.Unix(j.Finished, 0).Format("2006-01-02 15:04:05")
+		}
+		c := j.Counts
+		fmt.Printf(
+			"%d\t%s\t%s\t%d files: %d imported, %d duplicate, "+
+				"%d unsupported, %d errors, %d pending\n",
+			j.ID, time.Unix(j.Created, 0).Format("2006-01-02 15:04:05"),
+			state, c.Total(), c.Imported, c.Duplicate, c.Unsupported, c.Error,
+			c.Pending,
+		)
+	}
+	return nil
+}
+
+// Serve an import job with its counts and failed paths as JSON
+func serveImportJob(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(extractParam(r, "id"), 10, 64)
+	if err != nil {
+		httpError(w, r, err)
+		return
+	}
+	j, err := db.GetImportJob(id)
+	if err != nil {
+		httpError(w, r, err)
+		return
+	}
+	serveJSON(w, r, j)
+}
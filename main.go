@@ -5,9 +5,13 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/bakape/hydron/common"
 	"github.com/bakape/hydron/db"
+	"github.com/bakape/hydron/fetch"
 	"github.com/bakape/hydron/files"
+	"github.com/bakape/hydron/tags"
 	"github.com/bakape/hydron/util"
 )
 
@@ -15,9 +19,14 @@ const defaultAddress = ":8010"
 
 var (
 	modeFlags = map[string]*flag.FlagSet{
-		"serve":  flag.NewFlagSet("serve", flag.PanicOnError),
-		"import": flag.NewFlagSet("import", flag.PanicOnError),
-		"search": flag.NewFlagSet("search", flag.PanicOnError),
+		"serve":      flag.NewFlagSet("serve", flag.PanicOnError),
+		"import":     flag.NewFlagSet("import", flag.PanicOnError),
+		"search":     flag.NewFlagSet("search", flag.PanicOnError),
+		"undo":       flag.NewFlagSet("undo", flag.PanicOnError),
+		"fetch_tags": flag.NewFlagSet("fetch_tags", flag.PanicOnError),
+		"subscribe":  flag.NewFlagSet("subscribe", flag.PanicOnError),
+		"watch":      flag.NewFlagSet("watch", flag.PanicOnError),
+		"export":     flag.NewFlagSet("export", flag.PanicOnError),
 	}
 	modeTooltips = [][3]string{
 		{
@@ -29,7 +38,35 @@ var (
 		{
 			"import",
 			"PATHS...",
-			"Recursively import all file and directory PATHS.",
+			`Recursively import all file and directory PATHS. PATHS can also be
+  http(s):// URLs to download files from. Files inside zip, cbz, tar and
+  tar.gz archives are imported without extracting the archives.`,
+		},
+		{
+			"jobs",
+			"",
+			`List all import jobs with the number of imported, duplicate,
+  unsupported, failed and pending files.`,
+		},
+		{
+			"path_tags",
+			"PATHS...",
+			`Print the tags the rules in path_rules.json assign to all files in
+  PATHS.`,
+		},
+		{
+			"import_hydrus",
+			"PATH_TO_DB_DIR",
+			`Import all files of a Hydrus Network client with their local and
+  tag repository tags, inbox state and URLs. Files in the inbox are tagged
+  meta:inbox. Can be rerun to resume an interrupted import.`,
+		},
+		{
+			"watch",
+			"[DIRS...]",
+			`Watch DIRS recursively and import new files, once they are no
+  longer written to. Watches the folders configured in watch.json, if no DIRS
+  set.`,
 		},
 		{
 			"remove",
@@ -44,19 +81,34 @@ var (
   TAGS can be prefixed to match a specific tag category like artist, series and
   character.
   TAGS can include an order:$x parameter where $x is one of:
-	  size, width, height, duration, tag_count, random.
+	  size, width, height, duration, tag_count, random, taken.
   Prefixing - before $x will reverse the order.
   TAGS can include prefixed system tags for searching by file metadata:
     size, width, height, duration, tag_count,
   followed by one of these comparison operators:
     >, <, =, >=, <=
   and a positive integer.
-  There is also the type system tag to search by file type.
+  There is also the type system tag to search by file type and the taken
+  system tag to compare the date a photo was taken to a YYYY-MM-DD date.
+  TAGS can include exif:$field or exif:$field=$value to match embedded file
+  metadata, where $field is one of make, model, orientation or keyword and
+  $value can contain * wildcards and _ in place of spaces.
+  TAGS can include source_url:$x to match files by source URL, where $x can
+  contain * wildcards.
   Examples:
     hydron search system:width>1920 system:height>1080 artist:null
     hydron search system:tag_count=0 order:random
     hydron search 'red_scarf -bed system:size<10485760'
-    hydron search system:type=gif`,
+    hydron search system:type=gif
+    hydron search 'source_url:*pixiv.net*'
+    hydron search 'system:taken<2020-01-01 exif:model=*eos* order:taken'`,
+		},
+		{
+			"export",
+			"DIR TAGS...",
+			`Copy files that match the set of TAGS into DIR and write sidecar
+  files with their name, tags and sources next to them. TAGS have the same
+  syntax as in search.`,
 		},
 		{
 			"complete_tag",
@@ -76,7 +128,55 @@ var (
 		{
 			"fetch_tags",
 			"",
-			"Fetch tags for imported images and webm from danbooru.com.",
+			`Fetch tags for imported images and webm from configured boorus.
+  Files checked recently and files no matching post was found for are skipped.`,
+		},
+		{
+			"undo",
+			"[N]",
+			`Revert the last N tag changes. Reverts the last batch of changes,
+  if N is not set.`,
+		},
+		{
+			"apply_tag_rules",
+			"",
+			`Apply the rules from tag_rules.json to all stored tags fetched from
+  boorus.`,
+		},
+		{
+			"regenerate_thumbs",
+			"[TAGS...]",
+			`Generate thumbnails and animated previews of all files or files
+  matching the set of TAGS again with the settings from thumbnails.json. TAGS
+  have the same syntax as in search.`,
+		},
+		{
+			"read_metadata",
+			"",
+			`Read the embedded EXIF, IPTC and XMP metadata of all stored JPEG,
+  PNG, WEBP and TIFF files again.`,
+		},
+		{
+			"subscribe",
+			"FETCHER QUERY...",
+			`Subscribe to a booru tag QUERY searched with the tag fetcher named
+  FETCHER. New posts are imported periodically while the server is running.`,
+		},
+		{
+			"unsubscribe",
+			"ID",
+			"Remove the subscription with ID.",
+		},
+		{
+			"subscriptions",
+			"",
+			"List all subscriptions and their state.",
+		},
+		{
+			"sync_subscriptions",
+			"[IDs...]",
+			`Import new posts of the subscriptions with IDs or all subscriptions,
+  if none set.`,
 		},
 		{
 			"set_name",
@@ -97,7 +197,7 @@ var (
 	fetchTagsForImports = modeFlags["import"].Bool(
 		"f",
 		false,
-		"Fetch tags from danbooru.com for imported files.\n"+
+		"Fetch tags from the configured boorus for imported files.\n"+
 			"NB: This will notably slow down importing large amounts of files.\n"+
 			"Consider using import, followed by fetch_tags!",
 	)
@@ -106,6 +206,80 @@ var (
 		false,
 		"store the filename of an imported file as a tag",
 	)
+	deleteWatched = modeFlags["watch"].Bool(
+		"d",
+		false,
+		"delete imported files",
+	)
+	addTagsToWatched = modeFlags["watch"].String(
+		"t",
+		"",
+		"add tags to all imported files",
+	)
+	fetchTagsForWatched = modeFlags["watch"].Bool(
+		"f",
+		false,
+		"fetch tags from the configured boorus for imported files",
+	)
+	storeNameForWatched = modeFlags["watch"].Bool(
+		"n",
+		false,
+		"store the filename of an imported file as a tag",
+	)
+	dryRunImports = modeFlags["import"].Bool(
+		"dry-run",
+		false,
+		"only report, which files would be imported and their tags, "+
+			"without importing them",
+	)
+	linkImported = modeFlags["import"].Bool(
+		"l",
+		false,
+		"hardlink or reflink files into place instead of copying them, if "+
+			"on the same file system",
+	)
+	linkWatched = modeFlags["watch"].Bool(
+		"l",
+		false,
+		"hardlink or reflink files into place instead of copying them, if "+
+			"on the same file system",
+	)
+	archiveTagsForImports = modeFlags["import"].Bool(
+		"a",
+		false,
+		"tag files imported from archives with the archive name and store "+
+			"their order in the archive",
+	)
+	exportSidecarFormats = modeFlags["export"].String(
+		"f",
+		"txt,json,xmp",
+		"comma-separated list of sidecar formats to write",
+	)
+	undoBatch = modeFlags["undo"].Int64(
+		"b",
+		0,
+		"revert all tag changes of the batch with this ID instead",
+	)
+	fetchTagsSource = modeFlags["fetch_tags"].String(
+		"source",
+		"",
+		"name of the tag fetcher to use instead of all configured fetchers",
+	)
+	fetchTagsSince = modeFlags["fetch_tags"].Duration(
+		"since",
+		24*time.Hour,
+		"skip files tags were fetched for within this duration",
+	)
+	fetchTagsRetryMissing = modeFlags["fetch_tags"].Bool(
+		"retry-missing",
+		false,
+		"retry files no matching post was found for",
+	)
+	subscriptionInterval = modeFlags["subscribe"].Duration(
+		"i",
+		24*time.Hour,
+		"interval between checks for new posts",
+	)
 	address = modeFlags["serve"].String(
 		"a",
 		defaultAddress,
@@ -135,7 +309,14 @@ func main() {
 		}
 	}
 
-	if err := util.Waterfall(files.Init, db.Open); err != nil {
+	if err := util.Waterfall(
+		files.Init,
+		db.Open,
+		tags.LoadRules,
+		tags.LoadPathRules,
+		files.LoadThumbConfig,
+		fetch.LoadFetchers,
+	); err != nil {
 		panic(err)
 	}
 	defer db.Close()
@@ -146,31 +327,108 @@ func main() {
 		err = startServer(*address)
 	case "import":
 		assertArgCount(3)
+		if *dryRunImports {
+			err = printDryRun(
+				fl.Args(),
+				*archiveTagsForImports,
+				*addTagsToImported,
+			)
+			break
+		}
 		err = importPaths(
 			fl.Args(),
 			*deleteImported,
 			*fetchTagsForImports,
 			*storeNameForImports,
+			*archiveTagsForImports,
+			*linkImported,
 			*addTagsToImported,
 		)
+	case "watch":
+		err = watchFolders(fl.Args(), watchFolder{
+			Tags:      *addTagsToWatched,
+			Delete:    *deleteWatched,
+			StoreName: *storeNameForWatched,
+			FetchTags: *fetchTagsForWatched,
+			Link:      *linkWatched,
+		})
+	case "jobs":
+		err = listImportJobs()
+	case "path_tags":
+		assertArgCount(3)
+		err = printPathTags(os.Args[2:])
+	case "import_hydrus":
+		assertArgCount(3)
+		err = importHydrus(os.Args[2])
 	case "remove":
 		assertArgCount(3)
 		err = removeFiles(os.Args[2:])
 	case "fetch_tags":
-		err = fetchAllTags()
+		err = fetchAllTags(
+			*fetchTagsSource,
+			*fetchTagsSince,
+			*fetchTagsRetryMissing,
+		)
+	case "apply_tag_rules":
+		err = applyTagRules()
+	case "regenerate_thumbs":
+		err = regenerateThumbnails(strings.Join(os.Args[2:], " "))
+	case "read_metadata":
+		err = readAllMetadata()
 	case "search":
 		err = searchImages(strings.Join(fl.Args(), " "))
+	case "export":
+		if fl.NArg() == 0 {
+			printHelp()
+		}
+		err = exportImages(
+			fl.Arg(0),
+			*exportSidecarFormats,
+			strings.Join(fl.Args()[1:], " "),
+		)
 	case "complete_tag":
 		assertArgCount(3)
-		var suggests []string
+		var suggests []common.TagSuggestion
 		suggests, err = db.CompleteTag(os.Args[2])
-		fmt.Println(strings.Join(suggests, " "))
+		for i, s := range suggests {
+			if i != 0 {
+				fmt.Print(" ")
+			}
+			fmt.Print(s.Tag)
+		}
+		fmt.Print("\n")
 	case "add_tags":
 		assertArgCount(4)
-		err = addTags(os.Args[2], strings.Join(os.Args[3:], " "))
+		err = inCLIBatch(func(b db.Batch) error {
+			return addTags(b, os.Args[2], strings.Join(os.Args[3:], " "))
+		})
 	case "remove_tags":
 		assertArgCount(4)
-		err = removeTags(os.Args[2], strings.Join(os.Args[3:], " "))
+		err = inCLIBatch(func(b db.Batch) error {
+			return removeTags(b, os.Args[2], strings.Join(os.Args[3:], " "))
+		})
+	case "undo":
+		err = undoTagChanges(fl.Args(), *undoBatch)
+	case "subscribe":
+		if fl.NArg() < 2 {
+			printHelp()
+		}
+		var s common.Subscription
+		s, err = addSubscription(
+			fl.Arg(0),
+			strings.Join(fl.Args()[1:], " "),
+			*subscriptionInterval,
+		)
+		if err == nil {
+			fmt.Println(s.ID)
+		}
+	case "unsubscribe":
+		assertArgCount(3)
+		err = removeSubscription(os.Args[2])
+	case "subscriptions":
+		err = listSubscriptions()
+	case "sync_subscriptions":
+		err = syncSubscriptionsCLI(os.Args[2:])
 	case "set_name":
 		assertArgCount(4)
 		err = setImageName(os.Args[2], os.Args[3])
@@ -0,0 +1,286 @@
+package metadata
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+var (
+	errBlockTooLarge = errors.New("metadata block too large")
+
+	exifHeader      = []byte("Exif\x00\x00")
+	xmpHeader       = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	photoshopHeader = []byte("Photoshop 3.0\x00")
+)
+
+// TIFF tags containing XMP and IPTC metadata
+const (
+	tiffXMPTag  = 700
+	tiffIPTCTag = 33723
+)
+
+// Read a block of n bytes into memory
+func readBlock(r io.Reader, n int64) ([]byte, error) {
+	if n > maxBlockSize {
+		return nil, errBlockTooLarge
+	}
+	buf := make([]byte, n)
+	_, err := io.ReadFull(r, buf)
+	return buf, err
+}
+
+// Extract metadata from the APP1 and APP13 segments of a JPEG file
+func readJPEG(r io.ReadSeeker) (b blocks, err error) {
+	var head [4]byte
+	_, err = io.ReadFull(r, head[:2])
+	if err != nil {
+		return
+	}
+	if head[0] != 0xFF || head[1] != 0xD8 {
+		return b, errors.New("not a JPEG file")
+	}
+
+	for {
+		_, err = io.ReadFull(r, head[:2])
+		if err != nil {
+			return
+		}
+		if head[0] != 0xFF {
+			return b, errors.New("invalid JPEG marker")
+		}
+		marker := head[1]
+		switch {
+		case marker == 0xFF:
+			// Fill byte
+			_, err = r.Seek(-1, io.SeekCurrent)
+			if err != nil {
+				return
+			}
+			continue
+		case marker == 0xD8 || (marker >= 0xD0 && marker <= 0xD7) ||
+			marker == 0x01:
+			// Markers without a payload
+			continue
+		case marker == 0xDA || marker == 0xD9:
+			// Metadata is always stored before the image data
+			return b, nil
+		}
+
+		_, err = io.ReadFull(r, head[2:4])
+		if err != nil {
+			return
+		}
+		n := int64(binary.BigEndian.Uint16(head[2:4])) - 2
+		if n < 0 {
+			return b, errors.New("invalid JPEG segment length")
+		}
+		if marker != 0xE1 && marker != 0xED {
+			_, err = r.Seek(n, io.SeekCurrent)
+			if err != nil {
+				return
+			}
+			continue
+		}
+
+		var buf []byte
+		buf, err = readBlock(r, n)
+		if err != nil {
+			return
+		}
+		switch {
+		case marker == 0xE1 && bytes.HasPrefix(buf, exifHeader):
+			if b.exif == nil {
+				b.exif = buf
+			}
+		case marker == 0xE1 && bytes.HasPrefix(buf, xmpHeader):
+			if b.xmp == nil {
+				b.xmp = buf[len(xmpHeader):]
+			}
+		case marker == 0xED && bytes.HasPrefix(buf, photoshopHeader):
+			if b.iptc == nil {
+				b.iptc = photoshopIPTC(buf[len(photoshopHeader):])
+			}
+		}
+	}
+}
+
+// Extract IPTC data from Photoshop image resource blocks
+func photoshopIPTC(buf []byte) []byte {
+	for len(buf) >= 12 && bytes.HasPrefix(buf, []byte("8BIM")) {
+		id := binary.BigEndian.Uint16(buf[4:6])
+		// Pascal string name padded to an even length
+		nameLen := int(buf[6]) + 1
+		if nameLen%2 != 0 {
+			nameLen++
+		}
+		i := 6 + nameLen
+		if len(buf) < i+4 {
+			return nil
+		}
+		size := int(binary.BigEndian.Uint32(buf[i : i+4]))
+		i += 4
+		if size < 0 || len(buf) < i+size {
+			return nil
+		}
+		if id == 0x0404 {
+			return buf[i : i+size]
+		}
+		if size%2 != 0 {
+			size++
+		}
+		if len(buf) < i+size {
+			return nil
+		}
+		buf = buf[i+size:]
+	}
+	return nil
+}
+
+// Extract metadata from the eXIf and iTXt chunks of a PNG file
+func readPNG(r io.ReadSeeker) (b blocks, err error) {
+	var head [8]byte
+	_, err = io.ReadFull(r, head[:])
+	if err != nil {
+		return
+	}
+	if string(head[:]) != "\x89PNG\r\n\x1a\n" {
+		return b, errors.New("not a PNG file")
+	}
+
+	for {
+		_, err = io.ReadFull(r, head[:])
+		switch err {
+		case nil:
+		case io.EOF, io.ErrUnexpectedEOF:
+			return b, nil
+		default:
+			return
+		}
+		n := int64(binary.BigEndian.Uint32(head[:4]))
+		switch string(head[4:]) {
+		case "IEND":
+			return b, nil
+		case "eXIf", "iTXt":
+			var buf []byte
+			buf, err = readBlock(r, n)
+			if err != nil {
+				return
+			}
+			if string(head[4:]) == "eXIf" {
+				b.exif = buf
+			} else if x := pngXMP(buf); x != nil {
+				b.xmp = x
+			}
+			n = 0
+		}
+		// Skip the rest of the chunk and its CRC
+		_, err = r.Seek(n+4, io.SeekCurrent)
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Return the XMP stored in a PNG iTXt chunk or nil, if the chunk does not
+// contain XMP
+func pngXMP(buf []byte) []byte {
+	const keyword = "XML:com.adobe.xmp\x00"
+	if !bytes.HasPrefix(buf, []byte(keyword)) {
+		return nil
+	}
+	buf = buf[len(keyword):]
+	if len(buf) < 2 {
+		return nil
+	}
+	compressed := buf[0] == 1
+	buf = buf[2:]
+	// Skip the language tag and translated keyword
+	for i := 0; i < 2; i++ {
+		j := bytes.IndexByte(buf, 0)
+		if j == -1 {
+			return nil
+		}
+		buf = buf[j+1:]
+	}
+	if !compressed {
+		return buf
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(buf))
+	if err != nil {
+		return nil
+	}
+	defer zr.Close()
+	buf, err = ioutil.ReadAll(io.LimitReader(zr, maxBlockSize))
+	if err != nil {
+		return nil
+	}
+	return buf
+}
+
+// Extract metadata from the EXIF and XMP chunks of a WEBP file
+func readWEBP(r io.ReadSeeker) (b blocks, err error) {
+	var head [12]byte
+	_, err = io.ReadFull(r, head[:])
+	if err != nil {
+		return
+	}
+	if string(head[:4]) != "RIFF" || string(head[8:]) != "WEBP" {
+		return b, errors.New("not a WEBP file")
+	}
+
+	for {
+		_, err = io.ReadFull(r, head[:8])
+		switch err {
+		case nil:
+		case io.EOF, io.ErrUnexpectedEOF:
+			return b, nil
+		default:
+			return
+		}
+		n := int64(binary.LittleEndian.Uint32(head[4:8]))
+		// Chunks are padded to an even size
+		padded := n + n%2
+		switch string(head[:4]) {
+		case "EXIF", "XMP ":
+			var buf []byte
+			buf, err = readBlock(r, n)
+			if err != nil {
+				return
+			}
+			if string(head[:4]) == "EXIF" {
+				b.exif = buf
+			} else {
+				b.xmp = buf
+			}
+			padded -= n
+		}
+		_, err = r.Seek(padded, io.SeekCurrent)
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Extract metadata from a TIFF file. The TIFF file itself is the EXIF data,
+// so it is decoded directly and XMP and IPTC are read from its first IFD.
+func readTIFF(r io.ReadSeeker) (b blocks, err error) {
+	b.decoded, err = decodeEXIF(r)
+	if err != nil {
+		return
+	}
+	if t := b.decoded.Tiff; t != nil && len(t.Dirs) != 0 {
+		for _, tag := range t.Dirs[0].Tags {
+			switch tag.Id {
+			case tiffXMPTag:
+				b.xmp = tag.Val
+			case tiffIPTCTag:
+				b.iptc = tag.Val
+			}
+		}
+	}
+	return
+}
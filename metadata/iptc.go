@@ -0,0 +1,48 @@
+package metadata
+
+import (
+	"encoding/binary"
+)
+
+// IPTC IIM datasets of the application record
+const (
+	iptcKeywords    = 25
+	iptcDateCreated = 55
+	iptcTimeCreated = 60
+)
+
+// Parse the keywords and creation date from IPTC IIM data
+func parseIPTC(buf []byte) (m Metadata) {
+	var date, tm string
+	for len(buf) >= 5 && buf[0] == 0x1C {
+		record, dataset := buf[1], buf[2]
+		n := int(binary.BigEndian.Uint16(buf[3:5]))
+		buf = buf[5:]
+		// Extended length datasets are never used for these fields
+		if n&0x8000 != 0 || len(buf) < n {
+			break
+		}
+		val := string(buf[:n])
+		buf = buf[n:]
+
+		if record != 2 {
+			continue
+		}
+		switch dataset {
+		case iptcKeywords:
+			m.Keywords = append(m.Keywords, val)
+		case iptcDateCreated:
+			date = val
+		case iptcTimeCreated:
+			tm = val
+		}
+	}
+
+	if date != "" {
+		m.Taken = parseDate(date + tm)
+		if m.Taken.IsZero() {
+			m.Taken = parseDate(date)
+		}
+	}
+	return
+}
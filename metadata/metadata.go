@@ -0,0 +1,223 @@
+// Package metadata reads EXIF, IPTC and XMP metadata embedded in image files
+package metadata
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bakape/hydron/common"
+	"github.com/rwcarlsen/goexif/exif"
+	"github.com/rwcarlsen/goexif/tiff"
+)
+
+// Keys of metadata fields stored in the database
+const (
+	Make        = "make"
+	Model       = "model"
+	Orientation = "orientation"
+	Keyword     = "keyword"
+)
+
+// File types metadata can be read from
+var FileTypes = []common.FileType{
+	common.JPEG, common.PNG, common.WEBP, common.TIFF,
+}
+
+// Maximum size of a single metadata block to read into memory
+const maxBlockSize = 16 << 20
+
+// Metadata embedded in a file by cameras and photo editors
+type Metadata struct {
+	Make, Model string
+	// Zero, if unknown. Dates without time zones are read as UTC.
+	Taken time.Time
+	// EXIF orientation from 1 to 8 or 0, if unknown
+	Orientation int
+	Keywords    []string
+}
+
+// Merge fields not yet set from other metadata and all keywords
+func (m *Metadata) merge(other Metadata) {
+	if m.Make == "" {
+		m.Make = other.Make
+	}
+	if m.Model == "" {
+		m.Model = other.Model
+	}
+	if m.Taken.IsZero() {
+		m.Taken = other.Taken
+	}
+	if m.Orientation == 0 {
+		m.Orientation = other.Orientation
+	}
+	m.Keywords = append(m.Keywords, other.Keywords...)
+}
+
+// Returns the date the file was taken as a Unix timestamp or 0, if unknown
+func (m Metadata) TakenUnix() int64 {
+	if m.Taken.IsZero() {
+		return 0
+	}
+	return m.Taken.Unix()
+}
+
+// Return the metadata as key/value pairs to store in the database
+func (m Metadata) Fields() (fields []common.MetadataField) {
+	add := func(key, val string) {
+		val = strings.TrimSpace(val)
+		if val != "" {
+			fields = append(fields, common.MetadataField{
+				Key:   key,
+				Value: val,
+			})
+		}
+	}
+
+	add(Make, m.Make)
+	add(Model, m.Model)
+	if m.Orientation != 0 {
+		add(Orientation, strconv.Itoa(m.Orientation))
+	}
+	seen := make(map[string]bool, len(m.Keywords))
+	for _, k := range m.Keywords {
+		k = strings.TrimSpace(k)
+		if !seen[k] {
+			seen[k] = true
+			add(Keyword, k)
+		}
+	}
+	return
+}
+
+// Raw metadata blocks extracted from a file
+type blocks struct {
+	exif, xmp, iptc []byte
+	// EXIF data already decoded from the file
+	decoded *exif.Exif
+}
+
+// Read metadata embedded in a JPEG, PNG, WEBP or TIFF file. Other file types
+// return empty metadata. EXIF fields take precedence over XMP fields and XMP
+// fields over IPTC fields. Invalid EXIF or XMP blocks are skipped.
+func Read(r io.ReadSeeker, typ common.FileType) (m Metadata, err error) {
+	_, err = r.Seek(0, 0)
+	if err != nil {
+		return
+	}
+
+	var b blocks
+	switch typ {
+	case common.JPEG:
+		b, err = readJPEG(r)
+	case common.PNG:
+		b, err = readPNG(r)
+	case common.WEBP:
+		b, err = readWEBP(r)
+	case common.TIFF:
+		b, err = readTIFF(r)
+	default:
+		return
+	}
+	if err != nil {
+		return
+	}
+
+	if b.decoded == nil && b.exif != nil {
+		b.decoded, _ = decodeEXIF(bytes.NewReader(b.exif))
+	}
+	if b.decoded != nil {
+		m = parseEXIF(b.decoded)
+	}
+	if b.xmp != nil {
+		if x, err := parseXMP(b.xmp); err == nil {
+			m.merge(x)
+		}
+	}
+	if b.iptc != nil {
+		m.merge(parseIPTC(b.iptc))
+	}
+	return
+}
+
+// Decode EXIF stored as TIFF data with an optional "Exif\0\0" header
+func decodeEXIF(r io.Reader) (x *exif.Exif, err error) {
+	x, err = exif.Decode(r)
+	if err != nil {
+		// Errors in sub-directories like GPS still return the main fields
+		if x == nil || exif.IsCriticalError(err) {
+			return nil, err
+		}
+		err = nil
+	}
+	return
+}
+
+// Read the metadata fields of decoded EXIF
+func parseEXIF(x *exif.Exif) (m Metadata) {
+	m.Make = exifString(x, exif.Make)
+	m.Model = exifString(x, exif.Model)
+	if t, err := x.Get(exif.Orientation); err == nil {
+		if o, err := t.Int(0); err == nil && o >= 1 && o <= 8 {
+			m.Orientation = o
+		}
+	}
+	for _, name := range [...]exif.FieldName{
+		exif.DateTimeOriginal, exif.DateTimeDigitized, exif.DateTime,
+	} {
+		m.Taken = parseDate(exifString(x, name))
+		if !m.Taken.IsZero() {
+			break
+		}
+	}
+	return
+}
+
+// Return the value of a string EXIF field or "", if not set
+func exifString(x *exif.Exif, name exif.FieldName) string {
+	t, err := x.Get(name)
+	if err != nil || t.Format() != tiff.StringVal {
+		return ""
+	}
+	s, err := t.StringVal()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(s, "\x00"))
+}
+
+// Date formats used by EXIF, XMP and IPTC
+var dateFormats = [...]string{
+	"2006:01:02 15:04:05",
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	"20060102150405-0700",
+	"20060102150405",
+	"20060102",
+}
+
+// Parse a date in any of the formats used by EXIF, XMP and IPTC.
+// Returns the zero time, if the date is not valid.
+func parseDate(s string) time.Time {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}
+	}
+	for _, f := range dateFormats {
+		if t, err := time.ParseInLocation(f, s, time.UTC); err == nil {
+			// Cameras without a set clock write zeroed dates
+			if t.Year() < 1800 {
+				return time.Time{}
+			}
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,353 @@
+package metadata
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bakape/hydron/common"
+)
+
+const testXMP = `<?xpacket begin="" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+	<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+		<rdf:Description
+			xmlns:tiff="http://ns.adobe.com/tiff/1.0/"
+			xmlns:dc="http://purl.org/dc/elements/1.1/"
+			xmlns:photoshop="http://ns.adobe.com/photoshop/1.0/"
+			tiff:Make="XMP Make"
+			tiff:Model="XMP Model"
+			photoshop:DateCreated="2019-05-06T07:08:09+02:00">
+			<dc:subject>
+				<rdf:Bag>
+					<rdf:li>beach</rdf:li>
+					<rdf:li>sunset</rdf:li>
+				</rdf:Bag>
+			</dc:subject>
+		</rdf:Description>
+	</rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`
+
+// TIFF field of a test file
+type tiffField struct {
+	tag, typ uint16
+	val      []byte
+}
+
+// Encode a little endian TIFF file with a single IFD and no image data
+func encodeTIFF(fields ...tiffField) []byte {
+	var (
+		head = []byte{'I', 'I', 42, 0, 8, 0, 0, 0}
+		ifd  = make([]byte, 2, 2+len(fields)*12+4)
+		data []byte
+	)
+	binary.LittleEndian.PutUint16(ifd, uint16(len(fields)))
+	dataOff := len(head) + cap(ifd)
+	for _, f := range fields {
+		var entry [12]byte
+		binary.LittleEndian.PutUint16(entry[0:], f.tag)
+		binary.LittleEndian.PutUint16(entry[2:], f.typ)
+		n := len(f.val)
+		if f.typ == 3 {
+			n /= 2
+		}
+		binary.LittleEndian.PutUint32(entry[4:], uint32(n))
+		if len(f.val) <= 4 {
+			copy(entry[8:], f.val)
+		} else {
+			binary.LittleEndian.PutUint32(entry[8:],
+				uint32(dataOff+len(data)))
+			data = append(data, f.val...)
+			if len(data)%2 != 0 {
+				data = append(data, 0)
+			}
+		}
+		ifd = append(ifd, entry[:]...)
+	}
+	ifd = append(ifd, 0, 0, 0, 0)
+	return append(append(head, ifd...), data...)
+}
+
+// ASCII TIFF field
+func tiffString(tag uint16, s string) tiffField {
+	return tiffField{tag, 2, append([]byte(s), 0)}
+}
+
+// SHORT TIFF field
+func tiffShort(tag, val uint16) tiffField {
+	var buf [2]byte
+	binary.LittleEndian.PutUint16(buf[:], val)
+	return tiffField{tag, 3, buf[:]}
+}
+
+// EXIF data with the make, model, orientation and date of a camera
+func testEXIF(extra ...tiffField) []byte {
+	return encodeTIFF(append([]tiffField{
+		tiffString(0x010F, "Camera Make"),
+		tiffString(0x0110, "Camera Model"),
+		tiffShort(0x0112, 6),
+		tiffString(0x0132, "2020:01:02 03:04:05"),
+	}, extra...)...)
+}
+
+// Encode IPTC IIM datasets of the application record
+func encodeIPTC(datasets map[byte][]string) []byte {
+	var buf []byte
+	for _, ds := range [...]byte{iptcKeywords, iptcDateCreated,
+		iptcTimeCreated} {
+		for _, v := range datasets[ds] {
+			buf = append(buf, 0x1C, 2, ds, 0, 0)
+			binary.BigEndian.PutUint16(buf[len(buf)-2:], uint16(len(v)))
+			buf = append(buf, v...)
+		}
+	}
+	return buf
+}
+
+var testIPTC = encodeIPTC(map[byte][]string{
+	iptcKeywords:    {"sunset", "holiday"},
+	iptcDateCreated: {"20180304"},
+	iptcTimeCreated: {"050607+0000"},
+})
+
+// Encode a JPEG segment
+func jpegSegment(marker byte, data []byte) []byte {
+	buf := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(buf[2:], uint16(len(data)+2))
+	return append(buf, data...)
+}
+
+// Encode a JPEG file with the passed segments and no image data
+func encodeJPEG(segments ...[]byte) []byte {
+	buf := []byte{0xFF, 0xD8}
+	for _, s := range segments {
+		buf = append(buf, s...)
+	}
+	return append(buf, 0xFF, 0xD9)
+}
+
+// Encode a Photoshop image resource block containing IPTC data
+func photoshopBlock(iptc []byte) []byte {
+	buf := append([]byte(nil), photoshopHeader...)
+	// Unrelated resource preceding the IPTC
+	buf = append(buf, "8BIM\x03\xED\x00\x00\x00\x00\x00\x03abc\x00"...)
+	buf = append(buf, "8BIM\x04\x04\x00\x00\x00\x00\x00\x00"...)
+	binary.BigEndian.PutUint32(buf[len(buf)-4:], uint32(len(iptc)))
+	return append(buf, iptc...)
+}
+
+// Encode a PNG chunk
+func pngChunk(typ string, data []byte) []byte {
+	buf := make([]byte, 4, 12+len(data))
+	binary.BigEndian.PutUint32(buf, uint32(len(data)))
+	buf = append(buf, typ...)
+	buf = append(buf, data...)
+	var crc [4]byte
+	binary.BigEndian.PutUint32(crc[:], crc32.ChecksumIEEE(buf[4:]))
+	return append(buf, crc[:]...)
+}
+
+// Encode a PNG iTXt chunk containing XMP
+func pngXMPChunk(xmp string, compress bool) []byte {
+	buf := []byte("XML:com.adobe.xmp\x00")
+	if compress {
+		var w bytes.Buffer
+		zw := zlib.NewWriter(&w)
+		zw.Write([]byte(xmp))
+		zw.Close()
+		buf = append(buf, 1, 0, 0, 0)
+		buf = append(buf, w.Bytes()...)
+	} else {
+		buf = append(buf, 0, 0, 0, 0)
+		buf = append(buf, xmp...)
+	}
+	return pngChunk("iTXt", buf)
+}
+
+// Encode a PNG file with the passed chunks and no image data
+func encodePNG(chunks ...[]byte) []byte {
+	buf := []byte("\x89PNG\r\n\x1a\n")
+	buf = append(buf, pngChunk("IHDR", make([]byte, 13))...)
+	for _, c := range chunks {
+		buf = append(buf, c...)
+	}
+	return append(buf, pngChunk("IEND", nil)...)
+}
+
+func TestRead(t *testing.T) {
+	exifMeta := Metadata{
+		Make:        "Camera Make",
+		Model:       "Camera Model",
+		Taken:       time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Orientation: 6,
+	}
+	xmpMeta := Metadata{
+		Make:     "XMP Make",
+		Model:    "XMP Model",
+		Taken:    time.Date(2019, 5, 6, 5, 8, 9, 0, time.UTC),
+		Keywords: []string{"beach", "sunset"},
+	}
+
+	cases := [...]struct {
+		name string
+		typ  common.FileType
+		file []byte
+		std  Metadata
+	}{
+		{
+			name: "JPEG with EXIF, XMP and IPTC",
+			typ:  common.JPEG,
+			file: encodeJPEG(
+				jpegSegment(0xE0, []byte("JFIF\x00")),
+				jpegSegment(0xE1, append(exifHeader, testEXIF()...)),
+				jpegSegment(0xE1, append(xmpHeader, testXMP...)),
+				jpegSegment(0xED, photoshopBlock(testIPTC)),
+			),
+			std: Metadata{
+				Make:        exifMeta.Make,
+				Model:       exifMeta.Model,
+				Taken:       exifMeta.Taken,
+				Orientation: 6,
+				Keywords:    []string{"beach", "sunset", "sunset", "holiday"},
+			},
+		},
+		{
+			name: "JPEG with IPTC only",
+			typ:  common.JPEG,
+			file: encodeJPEG(jpegSegment(0xED, photoshopBlock(testIPTC))),
+			std: Metadata{
+				Taken:    time.Date(2018, 3, 4, 5, 6, 7, 0, time.UTC),
+				Keywords: []string{"sunset", "holiday"},
+			},
+		},
+		{
+			name: "JPEG with invalid EXIF",
+			typ:  common.JPEG,
+			file: encodeJPEG(
+				jpegSegment(0xE1, append(exifHeader, "garbage"...)),
+				jpegSegment(0xE1, append(xmpHeader, testXMP...)),
+			),
+			std: xmpMeta,
+		},
+		{
+			name: "PNG with eXIf",
+			typ:  common.PNG,
+			file: encodePNG(pngChunk("eXIf", testEXIF())),
+			std:  exifMeta,
+		},
+		{
+			name: "PNG with XMP",
+			typ:  common.PNG,
+			file: encodePNG(pngXMPChunk(testXMP, false)),
+			std:  xmpMeta,
+		},
+		{
+			name: "PNG with compressed XMP",
+			typ:  common.PNG,
+			file: encodePNG(pngXMPChunk(testXMP, true)),
+			std:  xmpMeta,
+		},
+		{
+			name: "TIFF with XMP and IPTC",
+			typ:  common.TIFF,
+			file: testEXIF(
+				tiffField{700, 1, []byte(testXMP)},
+				tiffField{33723, 7, testIPTC},
+			),
+			std: Metadata{
+				Make:        exifMeta.Make,
+				Model:       exifMeta.Model,
+				Taken:       exifMeta.Taken,
+				Orientation: 6,
+				Keywords:    []string{"beach", "sunset", "sunset", "holiday"},
+			},
+		},
+		{
+			name: "unsupported type",
+			typ:  common.GIF,
+			file: []byte("GIF89a"),
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			m, err := Read(bytes.NewReader(c.file), c.typ)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !m.Taken.Equal(c.std.Taken) {
+				t.Fatalf("expected date %s, got %s", c.std.Taken, m.Taken)
+			}
+			m.Taken = c.std.Taken
+			if !reflect.DeepEqual(m, c.std) {
+				t.Fatalf("\nexpected: %+v\ngot:      %+v", c.std, m)
+			}
+		})
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	cases := [...]struct {
+		name string
+		typ  common.FileType
+		file []byte
+	}{
+		{"JPEG", common.JPEG, []byte("not a JPEG")},
+		{"PNG", common.PNG, []byte("not a PNG file")},
+		{"TIFF", common.TIFF, []byte("not a TIFF")},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			_, err := Read(bytes.NewReader(c.file), c.typ)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	cases := [...]struct {
+		in  string
+		std time.Time
+	}{
+		{"2020:01:02 03:04:05", time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{
+			"2020-01-02T03:04:05+02:00",
+			time.Date(2020, 1, 2, 1, 4, 5, 0, time.UTC),
+		},
+		{"2020-01-02", time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"20200102", time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"0000:00:00 00:00:00", time.Time{}},
+		{"    ", time.Time{}},
+		{"yesterday", time.Time{}},
+	}
+	for _, c := range cases {
+		if d := parseDate(c.in); !d.Equal(c.std) {
+			t.Errorf("%q: expected %s, got %s", c.in, c.std, d)
+		}
+	}
+}
+
+func TestFields(t *testing.T) {
+	m := Metadata{
+		Make:        " Camera Make ",
+		Orientation: 3,
+		Keywords:    []string{"sunset", " sunset", "", "beach"},
+	}
+	std := []common.MetadataField{
+		{Key: Make, Value: "Camera Make"},
+		{Key: Orientation, Value: "3"},
+		{Key: Keyword, Value: "sunset"},
+		{Key: Keyword, Value: "beach"},
+	}
+	if f := m.Fields(); !reflect.DeepEqual(f, std) {
+		t.Fatalf("\nexpected: %+v\ngot:      %+v", std, f)
+	}
+}
@@ -0,0 +1,122 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// XML namespaces of XMP properties
+const (
+	nsRDF       = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	nsDC        = "http://purl.org/dc/elements/1.1/"
+	nsTIFF      = "http://ns.adobe.com/tiff/1.0/"
+	nsEXIF      = "http://ns.adobe.com/exif/1.0/"
+	nsXMP       = "http://ns.adobe.com/xap/1.0/"
+	nsPhotoshop = "http://ns.adobe.com/photoshop/1.0/"
+)
+
+// Properties to read in order of precedence for fields with multiple sources
+var (
+	xmpTaken = [...]xml.Name{
+		{Space: nsEXIF, Local: "DateTimeOriginal"},
+		{Space: nsPhotoshop, Local: "DateCreated"},
+		{Space: nsXMP, Local: "CreateDate"},
+	}
+	xmpMake        = xml.Name{Space: nsTIFF, Local: "Make"}
+	xmpModel       = xml.Name{Space: nsTIFF, Local: "Model"}
+	xmpOrientation = xml.Name{Space: nsTIFF, Local: "Orientation"}
+	xmpSubject     = xml.Name{Space: nsDC, Local: "subject"}
+)
+
+// Parse XMP metadata. Properties can be stored as attributes of
+// rdf:Description, as elements or as lists of rdf:li elements.
+func parseXMP(buf []byte) (m Metadata, err error) {
+	props, err := readXMPProps(bytes.NewReader(buf))
+	if err != nil {
+		return
+	}
+
+	first := func(name xml.Name) string {
+		if v := props[name]; len(v) != 0 {
+			return v[0]
+		}
+		return ""
+	}
+
+	m.Make = first(xmpMake)
+	m.Model = first(xmpModel)
+	if o, err := strconv.Atoi(first(xmpOrientation)); err == nil &&
+		o >= 1 && o <= 8 {
+		m.Orientation = o
+	}
+	for _, name := range xmpTaken {
+		m.Taken = parseDate(first(name))
+		if !m.Taken.IsZero() {
+			break
+		}
+	}
+	m.Keywords = props[xmpSubject]
+	return
+}
+
+// Read the values of all XMP properties by name
+func readXMPProps(r io.Reader) (props map[xml.Name][]string, err error) {
+	var (
+		d = xml.NewDecoder(r)
+		// Property currently inside of
+		prop xml.Name
+		text strings.Builder
+	)
+	props = make(map[xml.Name][]string)
+	add := func(name xml.Name, val string) {
+		val = strings.TrimSpace(val)
+		if val != "" {
+			props[name] = append(props[name], val)
+		}
+	}
+
+	for {
+		var tok xml.Token
+		tok, err = d.Token()
+		switch err {
+		case nil:
+		case io.EOF:
+			return props, nil
+		default:
+			return
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space == nsRDF {
+				if t.Name.Local == "Description" {
+					for _, a := range t.Attr {
+						if a.Name.Space != nsRDF && a.Name.Space != "xmlns" &&
+							a.Name.Space != "" {
+							add(a.Name, a.Value)
+						}
+					}
+				}
+			} else {
+				prop = t.Name
+			}
+			text.Reset()
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			switch {
+			case t.Name == prop:
+				// Property without rdf:Bag, rdf:Seq or rdf:Alt
+				add(prop, text.String())
+				prop = xml.Name{}
+			case t.Name.Space == nsRDF && t.Name.Local == "li" &&
+				prop.Local != "":
+				add(prop, text.String())
+			}
+			text.Reset()
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/bakape/hydron/common"
+	"github.com/bakape/hydron/files"
+	"github.com/bakape/hydron/tags"
+	"github.com/bakape/hydron/util"
+)
+
+// Maximum number of paths returned by a path rule preview
+const maxPathRulePreview = 1000
+
+// Tags path rules assign to a file path
+type pathTags struct {
+	Path string `json:"path"`
+	// Space-separated tags with type prefixes
+	Tags string `json:"tags"`
+}
+
+// Traverse paths and return the tags rs assigns to each file. URLs are skipped.
+func previewPathRules(rs tags.PathRules, paths []string) (
+	res []pathTags, err error,
+) {
+	local := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if !util.IsFetchable(p) {
+			local = append(local, p)
+		}
+	}
+	local, err = files.Traverse(local)
+	if err != nil {
+		return
+	}
+
+	res = make([]pathTags, len(local))
+	for i, p := range local {
+		res[i] = pathTags{
+			Path: p,
+			Tags: formatTags(rs.Tags(p)),
+		}
+	}
+	return
+}
+
+// Format tags as a space-separated string with type prefixes
+func formatTags(ts []common.Tag) string {
+	var w bytes.Buffer
+	for i, t := range ts {
+		if i != 0 {
+			w.WriteByte(' ')
+		}
+		t.WriteTo(&w)
+	}
+	return w.String()
+}
+
+// Print the tags the configured path rules assign to each file in paths
+func printPathTags(paths []string) error {
+	res, err := previewPathRules(tags.GetPathRules(), paths)
+	if err != nil {
+		return err
+	}
+	for _, r := range res {
+		fmt.Printf("%s\t%s\n", r.Path, r.Tags)
+	}
+	return nil
+}
+
+// Serve the configured path rules as JSON
+func servePathRules(w http.ResponseWriter, r *http.Request) {
+	conf := tags.GetPathRules().Conf
+	if conf == nil {
+		conf = []tags.PathRule{}
+	}
+	serveJSON(w, r, conf)
+}
+
+// Parse the JSON-encoded "rules" form value
+func parsePathRulesForm(r *http.Request) (conf []tags.PathRule, err error) {
+	err = r.ParseForm()
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal([]byte(r.Form.Get("rules")), &conf)
+	return
+}
+
+// Replace the configured path rules with the "rules" form value
+func setPathRulesHTTP(w http.ResponseWriter, r *http.Request) {
+	conf, err := parsePathRulesForm(r)
+	if err != nil {
+		sendError(w, 400, err)
+		return
+	}
+	rs, err := tags.CompilePathRules(conf)
+	if err != nil {
+		sendError(w, 400, err)
+		return
+	}
+	err = tags.SetPathRules(rs)
+	if err != nil {
+		send500(w, r, err)
+	}
+}
+
+// Serve the tags the path rules in the "rules" form value assign to each file
+// in the "path" form value without saving the rules
+func previewPathRulesHTTP(w http.ResponseWriter, r *http.Request) {
+	conf, err := parsePathRulesForm(r)
+	if err != nil {
+		sendError(w, 400, err)
+		return
+	}
+	rs, err := tags.CompilePathRules(conf)
+	if err != nil {
+		sendError(w, 400, err)
+		return
+	}
+	res, err := previewPathRules(rs, parsePaths(r.Form.Get("path")))
+	if err != nil {
+		sendError(w, 400, err)
+		return
+	}
+	if len(res) > maxPathRulePreview {
+		res = res[:maxPathRulePreview]
+	}
+	serveJSON(w, r, res)
+}
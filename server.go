@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -22,6 +23,7 @@ import (
 	"github.com/bakape/hydron/files"
 	"github.com/bakape/hydron/tags"
 	"github.com/bakape/hydron/templates"
+	"github.com/bakape/hydron/util"
 	"github.com/dimfeld/httptreemux"
 	"github.com/gorilla/handlers"
 )
@@ -38,6 +40,10 @@ var fileHeaders = map[string]string{
 }
 
 func startServer(addr string) error {
+	err := loadHydrusAPIConfig()
+	if err != nil {
+		return err
+	}
 	stderr.Println("listening on " + addr)
 
 	r := httptreemux.NewContextMux()
@@ -61,8 +67,9 @@ func startServer(addr string) error {
 	r.GET("/files/:file", func(w http.ResponseWriter, r *http.Request) {
 		serveFiles(w, r, files.ImageRoot)
 	})
-	r.GET("/thumbs/:file", func(w http.ResponseWriter, r *http.Request) {
-		serveFiles(w, r, files.ThumbRoot)
+	r.GET("/thumbs/:size/:file", serveThumbnailFile)
+	r.GET("/previews/:file", func(w http.ResponseWriter, r *http.Request) {
+		serveFiles(w, r, files.PreviewRoot)
 	})
 	r.GET("/assets/*path", serveAssets)
 
@@ -77,6 +84,7 @@ func startServer(addr string) error {
 	api.GET("/complete_tag/:prefix", completeTagHTTP)
 	api.POST("/images/:id/name", setImageNameHTTP)
 	api.POST("/import", importPathsHTTP)
+	api.POST("/batches", newBatchHTTP)
 
 	images := api.NewGroup("/images")
 	images.GET("/", serveSearch) // Dumps everything
@@ -85,20 +93,40 @@ func startServer(addr string) error {
 
 	images.GET("/:id", serveByID)
 	images.DELETE("/:id", removeFileHTTP)
+	images.GET("/:id/history", serveTagHistory)
+	images.POST("/:id/sources", addSourceHTTP)
 
 	tags := images.NewGroup("/:id/tags")
 	tags.PATCH("/", addTagsHTTP)
 	tags.POST("/", removeTagsHTTP)
 	tags.PATCH("/fetch", fetchTagsHTTP)
 
+	api.GET("/jobs/:id", serveImportJob)
+	api.GET("/archives/:name", serveArchiveMembers)
+	api.GET("/path_rules", servePathRules)
+	api.PUT("/path_rules", setPathRulesHTTP)
+	api.POST("/path_rules/preview", previewPathRulesHTTP)
+
+	subs := api.NewGroup("/subscriptions")
+	subs.GET("/", serveSubscriptions)
+	subs.POST("/", addSubscriptionHTTP)
+	subs.DELETE("/:id", removeSubscriptionHTTP)
+	subs.POST("/:id/sync", syncSubscriptionHTTP)
+
 	ajax := r.NewGroup("/ajax")
 	ajax.GET("/thumbnail/:id", serveThumbnail)
 
+	registerHydrusAPI(r)
+
 	s := http.Server{
 		Addr:    addr,
 		Handler: selectiveCompression(r),
 	}
 
+	go pollSubscriptions()
+	go hashImages()
+	go resumeImportJobs()
+
 	// Stop server on SIGTERM and propagate errors
 	errCh := make(chan error)
 	go func() {
@@ -171,9 +199,64 @@ func serveFiles(w http.ResponseWriter, r *http.Request, root string) {
 	}
 	defer file.Close()
 
+	setFileHeaders(w, name)
+
+	// Detect the content type from the extension, as not all formats can be
+	// sniffed
+	http.ServeContent(w, r, name, time.Time{}, file)
+}
+
+// Set the headers for serving an immutable file with the passed name
+func setFileHeaders(w http.ResponseWriter, name string) {
 	setHeaders(w, fileHeaders)
+	if strings.HasSuffix(name, ".svg") {
+		// Prevent scripts in SVG files from running, when opened directly
+		w.Header().Set("Content-Security-Policy",
+			"default-src 'none'; style-src 'unsafe-inline'; img-src data:; "+
+				"sandbox")
+	}
+}
 
-	http.ServeContent(w, r, extractParam(r, "path"), time.Time{}, file)
+// Serve a thumbnail of a configured size. Thumbnails in previously configured
+// formats and sizes are served, until they are regenerated.
+func serveThumbnailFile(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("If-None-Match") == "0" {
+		w.WriteHeader(304)
+		return
+	}
+
+	size, err := strconv.ParseUint(extractParam(r, "size"), 10, 64)
+	if err != nil || !files.GetThumbConfig().HasSize(uint(size)) {
+		send404(w)
+		return
+	}
+	name := extractParam(r, "file")
+	if len(name) < 40 || !util.IsSHA1(name[:40]) {
+		send404(w)
+		return
+	}
+	path, err := files.FindThumbPath(name[:40], uint(size))
+	if err != nil {
+		send404(w)
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		send404(w)
+		return
+	}
+	defer file.Close()
+
+	setHeaders(w, fileHeaders)
+	if path != files.ThumbPath(name[:40], uint(size)) ||
+		filepath.Base(path) != name {
+		// Not the final thumbnail, so must not be cached
+		w.Header().Del("ETag")
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	// Detect the content type from the extension, as not all formats can be
+	// sniffed
+	http.ServeContent(w, r, filepath.Base(path), time.Time{}, file)
 }
 
 // Return data descibing the page the client is requesting
@@ -190,7 +273,7 @@ func getRequestPage(r *http.Request) (page common.Page, err error) {
 	page.Page = extractUint("page")
 	page.Limit = extractUint("limit")
 	page.Order.Type = common.OrderType(extractUint("order"))
-	if page.Order.Type > common.Random {
+	if page.Order.Type > common.ByTaken {
 		page.Order.Type = common.None
 	}
 	page.Order.Reverse = q.Get("reverse") == "on"
@@ -250,8 +333,30 @@ func serveSearchHTML(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Offer a corrected query, if a tag did not match anything
+	var correction *common.Page
+	if len(images) == 0 {
+		var corrected []common.TagFilter
+		corrected, err = db.SuggestFilterCorrections(page.Filters.Tag)
+		if err != nil {
+			httpError(w, r, err)
+			return
+		}
+		if corrected != nil {
+			correction = &common.Page{
+				Limit: page.Limit,
+				Order: page.Order,
+				Filters: common.FilterSet{
+					Tag:       corrected,
+					System:    page.Filters.System,
+					SystemStr: page.Filters.SystemStr,
+				},
+			}
+		}
+	}
+
 	setHeaders(w, htmlHeaders)
-	templates.WriteBrowser(w, page, images)
+	templates.WriteBrowser(w, page, images, correction)
 }
 
 // Serve single image data by ID
@@ -280,13 +385,19 @@ func completeTagHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	b := make([]byte, 1, 256)
+	b := make([]byte, 1, 512)
 	b[0] = '['
 	for i, t := range tags {
 		if i != 0 {
 			b = append(b, ',')
 		}
-		b = strconv.AppendQuote(b, t)
+		b = append(b, `{"tag":`...)
+		b = strconv.AppendQuote(b, t.Tag)
+		if t.Count != 0 {
+			b = append(b, `,"count":`...)
+			b = strconv.AppendUint(b, t.Count, 10)
+		}
+		b = append(b, '}')
 	}
 	b = append(b, ']')
 
@@ -299,15 +410,21 @@ func addTagsHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func modTagsHTTP(w http.ResponseWriter, r *http.Request,
-	fn func(sha1 string, tagStr string) error,
+	fn func(b db.Batch, sha1 string, tagStr string) error,
 ) {
 	err := r.ParseForm()
 	if err != nil {
 		sendError(w, 400, err)
 		return
 	}
+	b, done, err := requestBatch(r)
+	if err != nil {
+		httpError(w, r, err)
+		return
+	}
+	defer done()
 
-	err = fn(extractParam(r, "id"), r.Form.Get("tags"))
+	err = fn(b, extractParam(r, "id"), r.Form.Get("tags"))
 	switch err {
 	case nil:
 	case sql.ErrNoRows:
@@ -346,22 +463,67 @@ func setImageNameHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// Fetch tags for a single file
-func fetchTagsHTTP(w http.ResponseWriter, r *http.Request) {
+// Add a manually entered source URL to a file.
+// Redirects back to the image page, if submitted from it.
+func addSourceHTTP(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		sendError(w, 400, err)
+		return
+	}
+	u := strings.TrimSpace(r.Form.Get("url"))
+	if !util.IsFetchable(u) {
+		sendError(w, 400, errors.New("invalid source URL"))
+		return
+	}
+
 	sha1 := extractParam(r, "id")
-	pair, err := db.GetImageIDAndMD5(sha1)
+	id, err := db.GetImageID(sha1)
+	if err != nil {
+		httpError(w, r, err)
+		return
+	}
+	err = db.AddSources(id, common.Source{
+		URL:  u,
+		Kind: common.UserSource,
+	})
 	if err != nil {
 		send500(w, r, err)
 		return
 	}
 
-	tags, err := fetch.FetchTags(pair.MD5)
+	if r.Form.Get("redirect") == "true" {
+		http.Redirect(w, r, "/image/"+sha1, 303)
+	}
+}
+
+// Fetch tags for a single file
+func fetchTagsHTTP(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		sendError(w, 400, err)
+		return
+	}
+	fs, err := fetch.Select(r.Form.Get("source"))
+	if err != nil {
+		sendError(w, 400, err)
+		return
+	}
+	b, done, err := requestBatch(r)
+	if err != nil {
+		httpError(w, r, err)
+		return
+	}
+	defer done()
+
+	sha1 := extractParam(r, "id")
+	pair, err := db.GetImageIDAndMD5(sha1)
 	if err != nil {
 		send500(w, r, err)
 		return
 	}
 
-	err = db.UpdateTags(pair.ID, tags, common.Danbooru)
+	err = fetchImageTags(fs, b, pair)
 	switch err {
 	case nil:
 	case sql.ErrNoRows:
@@ -385,8 +547,9 @@ func serveThumbnail(w http.ResponseWriter, r *http.Request) {
 // Serve expanded view of an image
 func serveImagePage(w http.ResponseWriter, r *http.Request) {
 	var (
-		page common.Page
-		img  common.Image
+		page    common.Page
+		img     common.Image
+		history []common.TagChange
 	)
 	err := func() (err error) {
 		page, err = getRequestPage(r)
@@ -394,6 +557,10 @@ func serveImagePage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		img, err = db.GetImage(extractParam(r, "id"))
+		if err != nil {
+			return
+		}
+		history, err = db.GetTagHistory(img.ID)
 		return
 	}()
 	if err != nil {
@@ -402,7 +569,32 @@ func serveImagePage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	setHeaders(w, htmlHeaders)
-	templates.WriteImagePage(w, img, page)
+	templates.WriteImagePage(w, img, history, page)
+}
+
+// Serve the tag edit history of an image as JSON
+func serveTagHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := db.GetImageID(extractParam(r, "id"))
+	if err != nil {
+		httpError(w, r, err)
+		return
+	}
+	history, err := db.GetTagHistory(id)
+	if err != nil {
+		httpError(w, r, err)
+		return
+	}
+	serveJSON(w, r, history)
+}
+
+// Create a new tag change batch, that can be shared by multiple requests
+func newBatchHTTP(w http.ResponseWriter, r *http.Request) {
+	b, err := db.NewBatch(db.ActorHTTP)
+	if err != nil {
+		send500(w, r, err)
+		return
+	}
+	serveJSON(w, r, b.ID)
 }
 
 func serveImportPage(w http.ResponseWriter, r *http.Request) {
@@ -437,6 +629,26 @@ func importPathsHTTP(w http.ResponseWriter, r *http.Request) {
 		send500(w, r, err)
 		return
 	}
+	archiveTags, err := strconv.ParseBool(r.Form.Get("archiveTags"))
+	if err != nil {
+		send500(w, r, err)
+		return
+	}
+	link, err := strconv.ParseBool(r.Form.Get("link"))
+	if err != nil {
+		send500(w, r, err)
+		return
+	}
+
+	if r.Form.Get("dryRun") == "true" {
+		rep, err := dryRunImport(paths, archiveTags, r.Form.Get("tagStr"))
+		if err != nil {
+			send500(w, r, err)
+			return
+		}
+		serveJSON(w, r, rep)
+		return
+	}
 
 	err = clientImportPaths(
 		w,
@@ -445,6 +657,8 @@ func importPathsHTTP(w http.ResponseWriter, r *http.Request) {
 		del,
 		fetch,
 		storeName,
+		archiveTags,
+		link,
 		r.Form.Get("tagStr"),
 	)
 	if err != nil {
@@ -457,3 +671,82 @@ func serveHelpPage(w http.ResponseWriter, r *http.Request) {
 	setHeaders(w, htmlHeaders)
 	templates.WriteHelpPage(w)
 }
+
+// Serve all subscriptions as JSON
+func serveSubscriptions(w http.ResponseWriter, r *http.Request) {
+	subs, err := db.GetSubscriptions()
+	if err != nil {
+		send500(w, r, err)
+		return
+	}
+	if subs == nil {
+		subs = []common.Subscription{}
+	}
+	serveJSON(w, r, subs)
+}
+
+// Serve the images imported from archives with the passed name in archive order
+func serveArchiveMembers(w http.ResponseWriter, r *http.Request) {
+	members, err := db.GetArchiveMembers(extractParam(r, "name"))
+	if err != nil {
+		send500(w, r, err)
+		return
+	}
+	if members == nil {
+		members = []common.ArchiveMember{}
+	}
+	serveJSON(w, r, members)
+}
+
+// Add a subscription from the "fetcher", "query" and "interval" form values.
+// The interval is in seconds.
+func addSubscriptionHTTP(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		sendError(w, 400, err)
+		return
+	}
+	interval, err := strconv.ParseUint(r.Form.Get("interval"), 10, 32)
+	if err != nil {
+		sendError(w, 400, err)
+		return
+	}
+
+	s, err := addSubscription(
+		r.Form.Get("fetcher"),
+		r.Form.Get("query"),
+		time.Duration(interval)*time.Second,
+	)
+	if err != nil {
+		sendError(w, 400, err)
+		return
+	}
+	serveJSON(w, r, s)
+}
+
+func removeSubscriptionHTTP(w http.ResponseWriter, r *http.Request) {
+	err := removeSubscription(extractParam(r, "id"))
+	if err != nil {
+		httpError(w, r, err)
+	}
+}
+
+// Sync a subscription immediately and serve its updated state as JSON
+func syncSubscriptionHTTP(w http.ResponseWriter, r *http.Request) {
+	s, err := getSubscription(extractParam(r, "id"))
+	if err != nil {
+		httpError(w, r, err)
+		return
+	}
+	_, err = syncSubscription(s)
+	if err != nil {
+		// Sync errors are stored in the subscription itself
+		stderr.Printf("subscription %d: %s\n", s.ID, err)
+	}
+	s, err = db.GetSubscription(s.ID)
+	if err != nil {
+		send500(w, r, err)
+		return
+	}
+	serveJSON(w, r, s)
+}
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/bakape/hydron/db"
 	"github.com/dimfeld/httptreemux"
 )
 
@@ -35,6 +36,10 @@ type Chunk struct {
 	SHA1 string
 	Current int
 	Total int
+	// ID of the import job
+	Job int64
+	// Set, if the file failed to import
+	Error string `json:",omitempty"`
 }
 
 // Handle error and devulge code from error type or value
@@ -74,6 +79,31 @@ func send500(w http.ResponseWriter, r *http.Request, err error) {
 	stderr.Printf("server: %s: %s", r.RemoteAddr, err)
 }
 
+// Return the tag change batch specified by the "batch" form value of a parsed
+// request or create a new one, if none. Batches created for the request are
+// discarded by the returned function, if the request recorded no changes.
+func requestBatch(r *http.Request) (b db.Batch, done func(), err error) {
+	done = func() {}
+	s := r.Form.Get("batch")
+	if s == "" {
+		b, err = db.NewBatch(db.ActorHTTP)
+		if err == nil {
+			done = func() {
+				if err := db.DiscardEmptyBatch(b.ID); err != nil {
+					stderr.Printf("server: %s: %s", r.RemoteAddr, err)
+				}
+			}
+		}
+		return
+	}
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return
+	}
+	b, err = db.GetBatch(id)
+	return
+}
+
 // Extract URL paramater from request context
 func extractParam(r *http.Request, id string) string {
 	return httptreemux.ContextParams(r.Context())[id]
@@ -0,0 +1,188 @@
+package sidecar
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/bakape/boorufetch"
+	"github.com/bakape/hydron/common"
+	"github.com/bakape/hydron/tags"
+)
+
+// Tag types by gallery-dl and booru tag category
+var tagCategories = map[string]common.TagType{
+	"general":   common.Undefined,
+	"artist":    common.Author,
+	"character": common.Character,
+	"copyright": common.Series,
+	"series":    common.Series,
+	"meta":      common.Meta,
+	"metadata":  common.Meta,
+}
+
+// Category names written to exported gallery-dl metadata by tag type
+var exportCategories = map[common.TagType]string{
+	common.Undefined: "general",
+	common.Author:    "artist",
+	common.Character: "character",
+	common.Series:    "copyright",
+	common.Meta:      "metadata",
+}
+
+// URL fields of gallery-dl metadata and the kind of source they are
+var urlFields = [...]struct {
+	key  string
+	kind common.SourceKind
+}{
+	{"file_url", common.ImportSource},
+	{"url", common.ImportSource},
+	{"post_url", common.PostSource},
+	{"page_url", common.PostSource},
+	{"source", common.OriginalSource},
+}
+
+// Read gallery-dl metadata. The fields differ between extractors, so only
+// commonly used ones are read.
+func readJSON(r io.Reader) (m Metadata, err error) {
+	var fields map[string]interface{}
+	err = json.NewDecoder(r).Decode(&fields)
+	if err != nil {
+		return
+	}
+
+	// Categorized tags like "tags_artist" or "tag_string_artist" replace
+	// the uncategorized "tags" and "tag_string" fields, if any
+	categorized := false
+	for key, val := range fields {
+		cat := strings.TrimPrefix(key, "tags_")
+		if cat == key {
+			cat = strings.TrimPrefix(key, "tag_string_")
+			if cat == key {
+				continue
+			}
+		}
+		categorized = true
+		m.Tags = append(m.Tags, parseTagList(val, tagCategories[cat])...)
+	}
+	switch t := fields["tags"].(type) {
+	case map[string]interface{}:
+		// Categories as an object like {"artist": ["foo"]}
+		for cat, val := range t {
+			m.Tags = append(m.Tags, parseTagList(val, tagCategories[cat])...)
+		}
+	default:
+		if !categorized {
+			m.Tags = append(m.Tags, parseTagList(t, common.Undefined)...)
+			m.Tags = append(m.Tags,
+				parseTagList(fields["tag_string"], common.Undefined)...)
+		}
+	}
+	if s, ok := fields["rating"].(string); ok && s != "" {
+		var rating boorufetch.Rating
+		if rating.UnmarshalJSON([]byte(strconv.Quote(s))) == nil {
+			m.Tags = append(m.Tags, common.Tag{
+				TagBase: common.TagBase{
+					Type: common.Rating,
+					Tag:  rating.String(),
+				},
+				Source: common.User,
+			})
+		}
+	}
+
+	if s, ok := fields["title"].(string); ok {
+		m.Name = strings.TrimSpace(s)
+	}
+
+	for _, f := range urlFields {
+		if s, ok := fields[f.key].(string); ok {
+			m.addSource(s, f.kind)
+		}
+	}
+	// Sources written by hydron
+	if sources, ok := fields["sources"].([]interface{}); ok {
+		for _, s := range sources {
+			obj, ok := s.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			u, _ := obj["url"].(string)
+			var kind common.SourceKind
+			if k, ok := obj["kind"].(string); ok {
+				buf := []byte(strconv.Quote(k))
+				if kind.UnmarshalJSON(buf) != nil {
+					kind = common.ImportSource
+				}
+			}
+			m.addSource(u, kind)
+		}
+	}
+	return
+}
+
+// Parse a list of tags as either a JSON array or a space-separated string
+func parseTagList(val interface{}, typ common.TagType) (out []common.Tag) {
+	var list []string
+	switch v := val.(type) {
+	case string:
+		list = strings.Fields(v)
+	case []interface{}:
+		list = make([]string, 0, len(v))
+		for _, t := range v {
+			if s, ok := t.(string); ok {
+				list = append(list, s)
+			}
+		}
+	}
+	for _, s := range list {
+		if typ == common.Undefined {
+			// Uncategorized tags can still have a type prefix
+			out = append(out, tags.Normalize(s, common.User))
+			continue
+		}
+		out = append(out, common.Tag{
+			TagBase: common.TagBase{
+				Type: typ,
+				Tag:  tags.NormalizeString(s),
+			},
+			Source: common.User,
+		})
+	}
+	return
+}
+
+// Write the name, tags and sources of an image as gallery-dl metadata with
+// categorized tags
+func writeJSON(img common.Image) ([]byte, error) {
+	fields := map[string]interface{}{
+		"category":  "hydron",
+		"sha1":      img.SHA1,
+		"md5":       img.MD5,
+		"width":     img.Width,
+		"height":    img.Height,
+		"extension": common.Extensions[img.Type],
+	}
+	if img.SHA256 != "" {
+		fields["sha256"] = img.SHA256
+	}
+	if img.Name != "" {
+		fields["title"] = img.Name
+	}
+	for _, cat := range exportCategories {
+		fields["tags_"+cat] = []string{}
+	}
+	for _, t := range dedupTags(copyTags(img.Tags)) {
+		if t.Type == common.Rating {
+			fields["rating"] = t.Tag
+			continue
+		}
+		key := "tags_" + exportCategories[t.Type]
+		fields[key] = append(fields[key].([]string), t.Tag)
+	}
+	if len(img.Sources) != 0 {
+		fields["sources"] = img.Sources
+	}
+	return json.MarshalIndent(fields, "", "\t")
+}
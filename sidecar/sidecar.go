@@ -0,0 +1,240 @@
+// Package sidecar reads and writes metadata files stored next to media files
+package sidecar
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bakape/hydron/common"
+	"github.com/bakape/hydron/util"
+)
+
+// Sidecar file formats
+type Format uint8
+
+const (
+	// Hydrus-style tags, one per line, in "foo.jpg.txt"
+	Text Format = iota
+	// gallery-dl metadata in "foo.jpg.json"
+	JSON
+	// XMP metadata in "foo.xmp" or "foo.jpg.xmp"
+	XMP
+)
+
+var formatStr = [...]string{"txt", "json", "xmp"}
+
+func (f Format) String() string {
+	return formatStr[int(f)]
+}
+
+// Parse a sidecar format from its file extension without the dot
+func ParseFormat(s string) (f Format, ok bool) {
+	for i, str := range formatStr {
+		if str == s {
+			return Format(i), true
+		}
+	}
+	return
+}
+
+// Metadata read from sidecar files
+type Metadata struct {
+	Name    string
+	Tags    []common.Tag
+	Sources []common.Source
+}
+
+// Returns, if no metadata was read
+func (m Metadata) IsEmpty() bool {
+	return m.Name == "" && len(m.Tags) == 0 && len(m.Sources) == 0
+}
+
+// Merge metadata from another sidecar file. Only the first name read is kept.
+func (m *Metadata) merge(other Metadata) {
+	if m.Name == "" {
+		m.Name = other.Name
+	}
+	m.Tags = append(m.Tags, other.Tags...)
+	m.Sources = append(m.Sources, other.Sources...)
+}
+
+// Add a source URL, if it is a valid http(s) URL
+func (m *Metadata) addSource(u string, kind common.SourceKind) {
+	u = strings.TrimSpace(u)
+	if util.IsFetchable(u) {
+		m.Sources = append(m.Sources, common.Source{
+			URL:  u,
+			Kind: kind,
+		})
+	}
+}
+
+// Return the possible paths of sidecar files of a media file
+func paths(path string, f Format) []string {
+	if f == XMP {
+		return []string{
+			strings.TrimSuffix(path, filepath.Ext(path)) + ".xmp",
+			path + ".xmp",
+		}
+	}
+	return []string{path + "." + f.String()}
+}
+
+// Read all sidecar files of the media file at path.
+// Returns the merged metadata and the paths of the sidecar files read.
+func Read(path string) (m Metadata, read []string, err error) {
+	for _, f := range [...]Format{Text, JSON, XMP} {
+		for _, p := range paths(path, f) {
+			var (
+				file *os.File
+				meta Metadata
+			)
+			file, err = os.Open(p)
+			switch {
+			case os.IsNotExist(err):
+				err = nil
+				continue
+			case err != nil:
+				return
+			}
+			switch f {
+			case Text:
+				meta, err = readText(file)
+			case JSON:
+				meta, err = readJSON(file)
+			case XMP:
+				meta, err = readXMP(file)
+			}
+			file.Close()
+			if err != nil {
+				return m, read, fmt.Errorf("%s: %s", p, err)
+			}
+			m.merge(meta)
+			read = append(read, p)
+		}
+	}
+	m.Tags = dedupTags(m.Tags)
+	return
+}
+
+// Remove duplicate tags
+func dedupTags(src []common.Tag) []common.Tag {
+	if len(src) == 0 {
+		return src
+	}
+	out := src[:0]
+	seen := make(map[common.TagBase]bool, len(src))
+	for _, t := range src {
+		if t.Tag != "" && !seen[t.TagBase] {
+			seen[t.TagBase] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// Returns, if path is a sidecar file of an existing media file and should not
+// be imported itself
+func IsSidecar(path string) bool {
+	var media []string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".txt", ".json":
+		media = []string{strings.TrimSuffix(path, filepath.Ext(path))}
+	case ".xmp":
+		stem := strings.TrimSuffix(path, filepath.Ext(path))
+		if filepath.Ext(stem) != "" {
+			media = append(media, stem)
+		}
+		media = append(media, withStem(stem)...)
+	default:
+		return false
+	}
+	for _, m := range media {
+		if isMedia(m) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the paths of all files named like stem with any extension
+func withStem(stem string) (paths []string) {
+	dir, name := filepath.Split(stem)
+	infos, _ := ioutil.ReadDir(filepath.Clean(dir))
+	for _, info := range infos {
+		n := info.Name()
+		if strings.TrimSuffix(n, filepath.Ext(n)) == name {
+			paths = append(paths, filepath.Join(dir, n))
+		}
+	}
+	return
+}
+
+// Returns, if path is an existing regular file, that is not a sidecar file
+func isMedia(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular() &&
+		filepath.Ext(path) != "" && !isSidecarExt(filepath.Ext(path))
+}
+
+// Filter the sidecar files read for the media file at path to those, that can
+// be deleted together with it. XMP files named after the stem like "foo.xmp"
+// are kept, while other media files share the stem, as in RAW+JPEG pairs.
+func Deletable(path string, sidecars []string) (deletable []string) {
+	stem := strings.TrimSuffix(path, filepath.Ext(path))
+	for _, s := range sidecars {
+		if s == stem+".xmp" && sharesStem(path, stem) {
+			continue
+		}
+		deletable = append(deletable, s)
+	}
+	return
+}
+
+// Returns, if another media file than path is named like stem
+func sharesStem(path, stem string) bool {
+	for _, p := range withStem(stem) {
+		if p != path && isMedia(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func isSidecarExt(ext string) bool {
+	switch strings.ToLower(ext) {
+	case ".txt", ".json", ".xmp":
+		return true
+	}
+	return false
+}
+
+// Write sidecar files of the passed formats for an image next to the media file
+// at path
+func Write(path string, img common.Image, formats []Format) (err error) {
+	for _, f := range formats {
+		var buf []byte
+		switch f {
+		case Text:
+			buf = writeText(img)
+		case JSON:
+			buf, err = writeJSON(img)
+		case XMP:
+			buf, err = writeXMP(img)
+		}
+		if err != nil {
+			return
+		}
+		// Write XMP sidecars as "foo.jpg.xmp" to not clash with other files
+		// with the same name
+		p := path + "." + f.String()
+		err = ioutil.WriteFile(p, buf, 0644)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
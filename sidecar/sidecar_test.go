@@ -0,0 +1,311 @@
+package sidecar
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/bakape/hydron/common"
+)
+
+// Write files with contents to a temporary directory and return the path to
+// the directory
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, s := range files {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(s), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func userTag(typ common.TagType, tag string) common.Tag {
+	return common.Tag{
+		TagBase: common.TagBase{
+			Type: typ,
+			Tag:  tag,
+		},
+		Source: common.User,
+	}
+}
+
+// Sort tags for comparison, as JSON object keys are read in random order
+func sortTags(tags []common.Tag) {
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Type != tags[j].Type {
+			return tags[i].Type < tags[j].Type
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+}
+
+func TestReadText(t *testing.T) {
+	m, err := readText(strings.NewReader(
+		"creator:Jane Doe\n\nseries:vocaloid\n  long hair  \nfoo:bar\n" +
+			"rating:safe\n",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	std := []common.Tag{
+		userTag(common.Author, "jane_doe"),
+		userTag(common.Series, "vocaloid"),
+		userTag(common.Undefined, "long_hair"),
+		userTag(common.Undefined, "foo:bar"),
+		userTag(common.Rating, "safe"),
+	}
+	if !reflect.DeepEqual(m.Tags, std) {
+		t.Fatalf("\nexpected: %+v\ngot:      %+v", std, m.Tags)
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	cases := [...]struct {
+		name, json string
+		std        Metadata
+	}{
+		{
+			name: "categorized tags",
+			json: `{
+				"title": " Beach ",
+				"tags_artist": ["jane doe"],
+				"tags_character": ["hatsune miku"],
+				"tags_general": ["long_hair"],
+				"tags": ["ignored"],
+				"rating": "q",
+				"file_url": "https://example.com/a.jpg",
+				"post_url": "https://example.com/posts/1",
+				"source": "not a URL"
+			}`,
+			std: Metadata{
+				Name: "Beach",
+				Tags: []common.Tag{
+					userTag(common.Undefined, "long_hair"),
+					userTag(common.Author, "jane_doe"),
+					userTag(common.Character, "hatsune_miku"),
+					userTag(common.Rating, "questionable"),
+				},
+				Sources: []common.Source{
+					{
+						URL:  "https://example.com/a.jpg",
+						Kind: common.ImportSource,
+					},
+					{
+						URL:  "https://example.com/posts/1",
+						Kind: common.PostSource,
+					},
+				},
+			},
+		},
+		{
+			name: "tag string",
+			json: `{"tag_string": "long_hair artist:jane_doe"}`,
+			std: Metadata{
+				Tags: []common.Tag{
+					userTag(common.Undefined, "long_hair"),
+					userTag(common.Author, "jane_doe"),
+				},
+			},
+		},
+		{
+			name: "tag object",
+			json: `{"tags": {"copyright": ["vocaloid"], "meta": "tagme"}}`,
+			std: Metadata{
+				Tags: []common.Tag{
+					userTag(common.Series, "vocaloid"),
+					userTag(common.Meta, "tagme"),
+				},
+			},
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			m, err := readJSON(strings.NewReader(c.json))
+			if err != nil {
+				t.Fatal(err)
+			}
+			sortTags(m.Tags)
+			sortTags(c.std.Tags)
+			if !reflect.DeepEqual(m, c.std) {
+				t.Fatalf("\nexpected: %+v\ngot:      %+v", c.std, m)
+			}
+		})
+	}
+}
+
+func TestReadXMP(t *testing.T) {
+	m, err := readXMP(strings.NewReader(`<x:xmpmeta xmlns:x="adobe:ns:meta/">
+	<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+		<rdf:Description xmlns:dc="http://purl.org/dc/elements/1.1/"
+			dc:source="https://example.com/original.jpg">
+			<dc:title>
+				<rdf:Alt>
+					<rdf:li xml:lang="x-default">Beach</rdf:li>
+				</rdf:Alt>
+			</dc:title>
+			<dc:subject>
+				<rdf:Bag>
+					<rdf:li>sunset</rdf:li>
+					<rdf:li>character:hatsune miku</rdf:li>
+				</rdf:Bag>
+			</dc:subject>
+		</rdf:Description>
+	</rdf:RDF>
+</x:xmpmeta>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Name != "Beach" {
+		t.Fatalf("unexpected name: %q", m.Name)
+	}
+	std := []common.Tag{
+		userTag(common.Undefined, "sunset"),
+		userTag(common.Character, "hatsune_miku"),
+	}
+	if !reflect.DeepEqual(m.Tags, std) {
+		t.Fatalf("\nexpected: %+v\ngot:      %+v", std, m.Tags)
+	}
+	if len(m.Sources) != 1 ||
+		m.Sources[0].URL != "https://example.com/original.jpg" {
+		t.Fatalf("unexpected sources: %+v", m.Sources)
+	}
+}
+
+func TestRead(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.jpg":      "",
+		"a.jpg.txt":  "sunset\nlong hair\n",
+		"a.jpg.json": `{"title": "Beach", "tags": ["sunset"]}`,
+		"a.xmp": `<x:xmpmeta xmlns:x="adobe:ns:meta/">
+			<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+				<rdf:Description xmlns:dc="http://purl.org/dc/elements/1.1/"
+					dc:title="Other title" />
+			</rdf:RDF>
+		</x:xmpmeta>`,
+		"b.jpg": "",
+	})
+
+	m, read, err := Read(filepath.Join(dir, "a.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	std := Metadata{
+		Name: "Beach",
+		Tags: []common.Tag{
+			userTag(common.Undefined, "sunset"),
+			userTag(common.Undefined, "long_hair"),
+		},
+	}
+	if !reflect.DeepEqual(m, std) {
+		t.Fatalf("\nexpected: %+v\ngot:      %+v", std, m)
+	}
+	stdRead := []string{
+		filepath.Join(dir, "a.jpg.txt"),
+		filepath.Join(dir, "a.jpg.json"),
+		filepath.Join(dir, "a.xmp"),
+	}
+	if !reflect.DeepEqual(read, stdRead) {
+		t.Fatalf("\nexpected: %v\ngot:      %v", stdRead, read)
+	}
+
+	// No sidecar files
+	m, read, err = Read(filepath.Join(dir, "b.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.IsEmpty() || len(read) != 0 {
+		t.Fatalf("unexpected metadata: %+v %v", m, read)
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.jpg":      "",
+		"a.jpg.json": "{",
+	})
+	_, _, err := Read(filepath.Join(dir, "a.jpg"))
+	if err == nil || !strings.Contains(err.Error(), "a.jpg.json") {
+		t.Fatalf("expected error naming the sidecar file, got %v", err)
+	}
+}
+
+func TestIsSidecar(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.jpg":      "",
+		"a.jpg.txt":  "",
+		"a.jpg.json": "",
+		"a.xmp":      "",
+		"b.png":      "",
+		"b.png.xmp":  "",
+		"notes.txt":  "",
+		"c.json":     "",
+		"c.json.txt": "",
+	})
+	cases := [...]struct {
+		name string
+		std  bool
+	}{
+		{"a.jpg", false},
+		{"a.jpg.txt", true},
+		{"a.jpg.json", true},
+		{"a.xmp", true},
+		{"b.png.xmp", true},
+		{"notes.txt", false},
+		{"c.json", false},
+		{"c.json.txt", false},
+	}
+	for _, c := range cases {
+		if is := IsSidecar(filepath.Join(dir, c.name)); is != c.std {
+			t.Errorf("%s: expected %t, got %t", c.name, c.std, is)
+		}
+	}
+}
+
+func TestDeletable(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.jpg":      "",
+		"a.cr2":      "",
+		"a.xmp":      "",
+		"a.jpg.txt":  "",
+		"a.jpg.xmp":  "",
+		"b.jpg":      "",
+		"b.xmp":      "",
+		"b.jpg.json": "",
+	})
+	join := func(names ...string) (paths []string) {
+		for _, n := range names {
+			paths = append(paths, filepath.Join(dir, n))
+		}
+		return
+	}
+	cases := [...]struct {
+		name, media   string
+		sidecars, std []string
+	}{
+		{
+			name:     "shared stem",
+			media:    "a.jpg",
+			sidecars: []string{"a.jpg.txt", "a.xmp", "a.jpg.xmp"},
+			std:      []string{"a.jpg.txt", "a.jpg.xmp"},
+		},
+		{
+			name:     "unique stem",
+			media:    "b.jpg",
+			sidecars: []string{"b.jpg.json", "b.xmp"},
+			std:      []string{"b.jpg.json", "b.xmp"},
+		},
+	}
+	for _, c := range cases {
+		d := Deletable(filepath.Join(dir, c.media), join(c.sidecars...))
+		if std := join(c.std...); !reflect.DeepEqual(d, std) {
+			t.Errorf("%s:\nexpected: %v\ngot:      %v", c.name, std, d)
+		}
+	}
+}
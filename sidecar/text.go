@@ -0,0 +1,43 @@
+package sidecar
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/bakape/hydron/common"
+	"github.com/bakape/hydron/hydrus"
+)
+
+// Read Hydrus-style tags with one tag per line
+func readText(r io.Reader) (m Metadata, err error) {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+		m.Tags = append(m.Tags, common.Tag{
+			TagBase: hydrus.ParseTag(line),
+			Source:  common.User,
+		})
+	}
+	err = s.Err()
+	return
+}
+
+// Write the tags of an image as Hydrus-style tags with one tag per line
+func writeText(img common.Image) []byte {
+	var w bytes.Buffer
+	for _, t := range dedupTags(copyTags(img.Tags)) {
+		w.WriteString(hydrus.FormatTag(t.TagBase))
+		w.WriteByte('\n')
+	}
+	return w.Bytes()
+}
+
+// Copy tags, so they can be deduplicated in place
+func copyTags(src []common.Tag) []common.Tag {
+	return append([]common.Tag(nil), src...)
+}
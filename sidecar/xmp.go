@@ -0,0 +1,154 @@
+package sidecar
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+
+	"github.com/bakape/hydron/common"
+	"github.com/bakape/hydron/tags"
+)
+
+// Dublin Core XML namespace used by XMP
+const dcNS = "http://purl.org/dc/elements/1.1/"
+
+// Read keywords, title and source URLs from XMP metadata.
+// Reads "dc:subject" as tags, "dc:title" as the name and "dc:source" and
+// "dc:relation" as sources.
+func readXMP(r io.Reader) (m Metadata, err error) {
+	var (
+		d = xml.NewDecoder(r)
+		// Dublin Core property currently inside of
+		prop string
+		text strings.Builder
+	)
+	for {
+		var tok xml.Token
+		tok, err = d.Token()
+		switch err {
+		case nil:
+		case io.EOF:
+			return m, nil
+		default:
+			return
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space == dcNS {
+				prop = t.Name.Local
+			}
+			// Simple properties can be stored as attributes
+			for _, a := range t.Attr {
+				if a.Name.Space == dcNS {
+					m.addXMPValue(a.Name.Local, a.Value)
+				}
+			}
+			text.Reset()
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			switch {
+			case t.Name.Space == dcNS:
+				// Property without rdf:Bag, rdf:Seq or rdf:Alt
+				if s := strings.TrimSpace(text.String()); s != "" {
+					m.addXMPValue(prop, s)
+				}
+				prop = ""
+			case t.Name.Local == "li" && prop != "":
+				m.addXMPValue(prop, text.String())
+			}
+			text.Reset()
+		}
+	}
+}
+
+// Add the value of a Dublin Core property
+func (m *Metadata) addXMPValue(prop, val string) {
+	val = strings.TrimSpace(val)
+	if val == "" {
+		return
+	}
+	switch prop {
+	case "subject":
+		t := tags.Normalize(val, common.User)
+		if t.Tag != "" {
+			m.Tags = append(m.Tags, t)
+		}
+	case "title":
+		if m.Name == "" {
+			m.Name = val
+		}
+	case "source":
+		m.addSource(val, common.OriginalSource)
+	case "relation":
+		m.addSource(val, common.ImportSource)
+	}
+}
+
+// Write the name, tags and sources of an image as XMP metadata
+func writeXMP(img common.Image) ([]byte, error) {
+	var w bytes.Buffer
+	w.WriteString(`<?xpacket begin="` + "\ufeff" +
+		`" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description rdf:about="" xmlns:dc="` + dcNS + `">
+`)
+
+	if img.Name != "" {
+		w.WriteString("<dc:title><rdf:Alt><rdf:li xml:lang=\"x-default\">")
+		xml.EscapeText(&w, []byte(img.Name))
+		w.WriteString("</rdf:li></rdf:Alt></dc:title>\n")
+	}
+
+	t := dedupTags(copyTags(img.Tags))
+	if len(t) != 0 {
+		w.WriteString("<dc:subject><rdf:Bag>\n")
+		var buf bytes.Buffer
+		for _, t := range t {
+			buf.Reset()
+			t.WriteTo(&buf)
+			w.WriteString("<rdf:li>")
+			xml.EscapeText(&w, buf.Bytes())
+			w.WriteString("</rdf:li>\n")
+		}
+		w.WriteString("</rdf:Bag></dc:subject>\n")
+	}
+
+	// dc:source only holds a single value, so write the first original
+	// source there and all other sources as relations
+	var (
+		source    string
+		relations []string
+	)
+	for _, s := range img.Sources {
+		if source == "" && s.Kind == common.OriginalSource {
+			source = s.URL
+		} else {
+			relations = append(relations, s.URL)
+		}
+	}
+	if source != "" {
+		w.WriteString("<dc:source>")
+		xml.EscapeText(&w, []byte(source))
+		w.WriteString("</dc:source>\n")
+	}
+	if len(relations) != 0 {
+		w.WriteString("<dc:relation><rdf:Bag>\n")
+		for _, u := range relations {
+			w.WriteString("<rdf:li>")
+			xml.EscapeText(&w, []byte(u))
+			w.WriteString("</rdf:li>\n")
+		}
+		w.WriteString("</rdf:Bag></dc:relation>\n")
+	}
+
+	w.WriteString(`</rdf:Description>
+</rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>
+`)
+	return w.Bytes(), nil
+}
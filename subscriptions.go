@@ -0,0 +1,288 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bakape/hydron/common"
+	"github.com/bakape/hydron/db"
+	"github.com/bakape/hydron/fetch"
+	imp "github.com/bakape/hydron/import"
+)
+
+const (
+	// Number of posts to request from a booru per page
+	subscriptionPageSize = 100
+
+	// Minimum interval between subscription syncs
+	minSubscriptionInterval = time.Minute
+)
+
+// Prevents concurrent syncs of subscriptions from importing the same posts
+var subscriptionMu sync.Mutex
+
+// Validate and add a new subscription
+func addSubscription(fetcher, query string, interval time.Duration) (
+	s common.Subscription, err error,
+) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		err = errors.New("empty subscription query")
+		return
+	}
+	if interval < minSubscriptionInterval {
+		err = fmt.Errorf("subscription interval must be at least %s",
+			minSubscriptionInterval)
+		return
+	}
+	_, err = fetch.GetSearcher(fetcher)
+	if err != nil {
+		return
+	}
+
+	s = common.Subscription{
+		Fetcher:  fetcher,
+		Query:    query,
+		Interval: int64(interval / time.Second),
+	}
+	s.ID, err = db.AddSubscription(s)
+	return
+}
+
+// Sync all subscriptions with the passed IDs or all subscriptions, if none
+func syncSubscriptionsCLI(ids []string) (err error) {
+	var subs []common.Subscription
+	if len(ids) == 0 {
+		subs, err = db.GetSubscriptions()
+		if err != nil {
+			return
+		}
+	} else {
+		for _, s := range ids {
+			var sub common.Subscription
+			sub, err = getSubscription(s)
+			if err != nil {
+				return
+			}
+			subs = append(subs, sub)
+		}
+	}
+
+	p := progressLogger{
+		header: "syncing subscriptions",
+		total:  len(subs),
+	}
+	total := 0
+	for _, s := range subs {
+		n, err := syncSubscription(s)
+		total += n
+		if err != nil {
+			p.Err(fmt.Errorf("subscription %d: %s", s.ID, err))
+		} else {
+			p.Done()
+		}
+	}
+	p.Close()
+	fmt.Printf("imported %d files\n", total)
+	return nil
+}
+
+// Print all subscriptions
+func listSubscriptions() error {
+	subs, err := db.GetSubscriptions()
+	if err != nil {
+		return err
+	}
+	for _, s := range subs {
+		last := "never"
+		if s.LastSync != 0 {
+			last = time.Unix(s.LastSync, 0).Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%d\t%s\t%s\tevery %s\tsynced %s\timported %d\n",
+			s.ID, s.Fetcher, s.Query,
+			time.Duration(s.Interval)*time.Second, last, s.Imported)
+		if s.Error != "" {
+			fmt.Printf("\terror: %s\n", s.Error)
+		}
+	}
+	return nil
+}
+
+// Remove a subscription by string ID
+func removeSubscription(id string) (err error) {
+	i, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return
+	}
+	return db.RemoveSubscription(i)
+}
+
+// Retrieve a subscription by string ID
+func getSubscription(id string) (s common.Subscription, err error) {
+	i, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return
+	}
+	return db.GetSubscription(i)
+}
+
+// Periodically sync all subscriptions, that are due. Never returns.
+func pollSubscriptions() {
+	for {
+		subs, err := db.GetDueSubscriptions(time.Now())
+		if err != nil {
+			stderr.Printf("subscriptions: %s\n", err)
+		}
+		for _, s := range subs {
+			_, err = syncSubscription(s)
+			if err != nil {
+				stderr.Printf("subscription %d: %s\n", s.ID, err)
+			}
+		}
+		time.Sleep(minSubscriptionInterval)
+	}
+}
+
+// Import all new posts of a subscription and record the result.
+// Returns the number of imported files.
+func syncSubscription(s common.Subscription) (imported int, err error) {
+	subscriptionMu.Lock()
+	defer subscriptionMu.Unlock()
+
+	err = importSubscriptionPosts(s, &imported)
+	if dbErr := db.SetSubscriptionSynced(s.ID, err); dbErr != nil {
+		err = dbErr
+	}
+	return
+}
+
+// Import all new posts of a subscription and advance its cursor.
+// Posts, that fail to import, are skipped and reported in the returned error.
+// imported: incremented for each newly imported file
+func importSubscriptionPosts(s common.Subscription, imported *int) (
+	err error,
+) {
+	// Only import the newest page of posts on the first sync instead of the
+	// entire history of the query
+	initial := s.Cursor == 0
+
+	var (
+		b       db.Batch
+		skipped int
+		skipErr error
+	)
+	defer func() {
+		if err == nil && skipped != 0 {
+			err = fmt.Errorf("skipped %d posts, last error: %s", skipped,
+				skipErr)
+		}
+	}()
+	for {
+		var posts []*fetch.Post
+		posts, err = fetch.SearchPosts(s.Fetcher, s.Query, s.Cursor,
+			subscriptionPageSize)
+		if err != nil || len(posts) == 0 {
+			return
+		}
+		if b.ID == 0 {
+			b, err = db.NewBatch(db.ActorSubscription)
+			if err != nil {
+				return
+			}
+		}
+
+		n := 0
+		for _, p := range posts {
+			var ok bool
+			ok, err = importPost(b, p)
+			if err != nil {
+				fatal := isSyncError(err)
+				err = fmt.Errorf("post %d: %s", p.ID, err)
+				if fatal {
+					break
+				}
+				// Skip the post instead of retrying it on every sync
+				stderr.Printf("subscription %d: %s\n", s.ID, err)
+				skipped++
+				skipErr = err
+				err = nil
+			}
+			if ok {
+				n++
+			}
+			s.Cursor = p.ID
+		}
+		*imported += n
+
+		// Persist progress even on error, so the next sync resumes here
+		dbErr := db.SetSubscriptionCursor(s.ID, s.Cursor, n)
+		switch {
+		case err != nil:
+			return
+		case dbErr != nil:
+			return dbErr
+		case initial, len(posts) < subscriptionPageSize:
+			return
+		}
+	}
+}
+
+// Returns, if err is caused by the network, database or file system instead
+// of a single post, and should stop the sync
+func isSyncError(err error) bool {
+	var (
+		netErr  net.Error
+		pathErr *os.PathError
+	)
+	return errors.As(err, &netErr) || errors.As(err, &pathErr) ||
+		db.IsDriverError(err)
+}
+
+// Import the file of a booru post, if not imported yet, and apply the post's
+// tags and sources.
+// Returns, if a new file was imported.
+func importPost(b db.Batch, p *fetch.Post) (imported bool, err error) {
+	if p.FileURL == "" {
+		// Restricted post
+		return
+	}
+
+	id, err := db.GetImageIDByMD5(p.MD5)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		var img common.Image
+		img, err = importURL(p.FileURL, false, false, "")
+		switch err {
+		case nil:
+			imported = true
+			id = img.ID
+		case imp.ErrImported:
+			id = img.ID
+		case imp.ErrUnsupportedFile:
+			return false, nil
+		default:
+			return
+		}
+	default:
+		return
+	}
+
+	err = db.UpdateTags(b, id, p.Tags, p.Source)
+	if err != nil {
+		return
+	}
+	err = db.AddSources(id, p.Sources()...)
+	if err != nil {
+		return
+	}
+	err = db.SetFetchState(id, true)
+	return
+}
@@ -5,11 +5,12 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/bakape/hydron/common"
 )
 
-var systemRegex = regexp.MustCompile(`^([\w_]+)(=|>|>=|<|<=)(\w+)$`)
+var systemRegex = regexp.MustCompile(`^([\w_]+)(=|>|>=|<|<=)([\w-]+)$`)
 
 type SyntaxError string
 
@@ -53,6 +54,10 @@ func ParseFilters(query string, page *common.Page) (err error) {
 				addFilter(common.SHA1Field)
 			case "name":
 				addFilter(common.Name)
+			case "source_url":
+				addFilter(common.SourceURL)
+			case "exif":
+				addFilter(common.Exif)
 			case "order":
 				err = parseOrdering(arg, &page.Order)
 			case "limit":
@@ -73,7 +78,7 @@ func ParseFilters(query string, page *common.Page) (err error) {
 			Negative: isNegative(&t),
 			TagBase: common.TagBase{
 				Type: detectTagType(&t),
-				Tag:  normalizeString(t),
+				Tag:  NormalizeString(t),
 			},
 		})
 	}
@@ -115,6 +120,19 @@ func parseSystemTag(arg string) (sys common.SystemTag, err error) {
 			Value:      uint64(ext),
 		}
 		return
+	case "taken":
+		var t time.Time
+		t, err = time.Parse(common.DateFormat, m[3])
+		if err != nil || t.Unix() < 0 {
+			err = SyntaxError("invalid date: " + m[3])
+			return
+		}
+		sys = common.SystemTag{
+			Type:       common.Taken,
+			Comparator: m[2],
+			Value:      uint64(t.Unix()),
+		}
+		return
 	default:
 		err = SyntaxError(arg)
 		return
@@ -154,6 +172,8 @@ func parseOrdering(arg string, o *common.Order) error {
 		o.Type = common.ByTagCount
 	case "random":
 		o.Type = common.Random
+	case "taken":
+		o.Type = common.ByTaken
 	default:
 		return SyntaxError(arg)
 	}
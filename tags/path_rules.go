@@ -0,0 +1,211 @@
+package tags
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/bakape/hydron/common"
+	"github.com/bakape/hydron/files"
+)
+
+// Name of the path rule configuration file
+const pathRulesFile = "path_rules.json"
+
+var (
+	// Matches named captures in path rule globs like "{artist}"
+	globCaptureRegex = regexp.MustCompile(`^\{(\w+)\}`)
+
+	pathRulesMu sync.RWMutex
+	pathRules   PathRules
+)
+
+// Rule for tagging imported files by their path as read from the
+// configuration file. Exactly one of Match and Glob must be set.
+type PathRule struct {
+	// Regex the path must match
+	Match string `json:"match,omitempty"`
+	// Glob the path must match. "*" and "?" do not match "/", "**" matches
+	// anything and "{name}" captures a path segment.
+	Glob string `json:"glob,omitempty"`
+	// Space-separated tags to add with named captures like "$artist" expanded.
+	// A leading "~" in Match or Glob is expanded to the home directory.
+	Tags string `json:"tags"`
+}
+
+type pathRule struct {
+	match *regexp.Regexp
+	tags  []string
+}
+
+// Compiled path rules and the configuration they were compiled from
+type PathRules struct {
+	Conf  []PathRule
+	rules []pathRule
+}
+
+// Compile path rules from their configuration
+func CompilePathRules(conf []PathRule) (rs PathRules, err error) {
+	rs = PathRules{
+		Conf:  conf,
+		rules: make([]pathRule, 0, len(conf)),
+	}
+	for i, c := range conf {
+		var r pathRule
+		switch {
+		case (c.Match == "") == (c.Glob == ""):
+			err = fmt.Errorf("exactly one of match and glob must be set")
+		case c.Match != "":
+			r.match, err = regexp.Compile(expandHome(c.Match))
+		default:
+			r.match, err = compileGlob(expandHome(c.Glob))
+		}
+		if err != nil {
+			return rs, fmt.Errorf("%s: rule %d: %s", pathRulesFile, i, err)
+		}
+		r.tags = strings.Fields(c.Tags)
+		rs.rules = append(rs.rules, r)
+	}
+	return
+}
+
+// Replace a leading "~" with the home directory
+func expandHome(s string) string {
+	if !strings.HasPrefix(s, "~") {
+		return s
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return s
+	}
+	return filepath.ToSlash(home) + s[1:]
+}
+
+// Compile a path glob into a regex. Absolute globs match from the start of the
+// path, others from any directory. Globs ending with "/" match directory
+// prefixes.
+func compileGlob(glob string) (*regexp.Regexp, error) {
+	var w strings.Builder
+	if strings.HasPrefix(glob, "/") {
+		w.WriteByte('^')
+	} else {
+		w.WriteString("(?:^|/)")
+	}
+	for i := 0; i < len(glob); i++ {
+		switch glob[i] {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				w.WriteString(".*")
+				i++
+			} else {
+				w.WriteString("[^/]*")
+			}
+		case '?':
+			w.WriteString("[^/]")
+		case '{':
+			m := globCaptureRegex.FindStringSubmatch(glob[i:])
+			if m == nil {
+				return nil, fmt.Errorf("invalid capture in glob: %s", glob)
+			}
+			fmt.Fprintf(&w, "(?P<%s>[^/]+)", m[1])
+			i += len(m[0]) - 1
+		default:
+			w.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	if !strings.HasSuffix(glob, "/") {
+		w.WriteByte('$')
+	}
+	return regexp.Compile(w.String())
+}
+
+// Return the tags all matching rules assign to a file path
+func (rs PathRules) Tags(path string) []common.Tag {
+	if len(rs.rules) == 0 {
+		return nil
+	}
+
+	path = filepath.ToSlash(path)
+	var (
+		out  []common.Tag
+		seen = make(map[common.TagBase]bool)
+		buf  []byte
+	)
+	for _, r := range rs.rules {
+		m := r.match.FindStringSubmatchIndex(path)
+		if m == nil {
+			continue
+		}
+		// Expand each tag separately, so captures can contain spaces
+		for _, tmpl := range r.tags {
+			buf = r.match.ExpandString(buf[:0], tmpl, path, m)
+			t := Normalize(string(buf), common.User)
+			if t.Tag == "" || seen[t.TagBase] {
+				continue
+			}
+			seen[t.TagBase] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// Load path rules from the configuration file, if any
+func LoadPathRules() (err error) {
+	var conf []PathRule
+	err = files.ReadConfig(pathRulesFile, &conf)
+	if err != nil {
+		return
+	}
+	rs, err := CompilePathRules(conf)
+	if err != nil {
+		return
+	}
+	pathRulesMu.Lock()
+	pathRules = rs
+	pathRulesMu.Unlock()
+	return
+}
+
+// Write compiled path rules to the configuration file and apply them to any
+// further imports
+func SetPathRules(rs PathRules) (err error) {
+	if rs.Conf == nil {
+		rs.Conf = []PathRule{}
+	}
+	err = files.WriteConfig(pathRulesFile, rs.Conf)
+	if err != nil {
+		return
+	}
+	pathRulesMu.Lock()
+	pathRules = rs
+	pathRulesMu.Unlock()
+	return
+}
+
+// Return the currently applied path rules
+func GetPathRules() PathRules {
+	pathRulesMu.RLock()
+	defer pathRulesMu.RUnlock()
+	return pathRules
+}
+
+// Append the tags the current path rules assign to a file path to a string of
+// tags
+func AddPathTags(path, tagStr string) string {
+	t := GetPathRules().Tags(path)
+	if len(t) == 0 {
+		return tagStr
+	}
+	var w bytes.Buffer
+	w.WriteString(tagStr)
+	for _, t := range t {
+		w.WriteByte(' ')
+		t.WriteTo(&w)
+	}
+	return w.String()
+}
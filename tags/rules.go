@@ -0,0 +1,127 @@
+package tags
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/bakape/hydron/common"
+	"github.com/bakape/hydron/files"
+)
+
+// Rules applied to fetched tags loaded from the configuration file
+var rules []rule
+
+// Rule for dropping or rewriting a fetched tag as read from the configuration
+// file. Rules are applied in order, each to the output of the previous one.
+type ruleConf struct {
+	// Regex the tag must match
+	Match string
+	// Only match tags of this type, if set
+	Type string
+	// Remove the tag
+	Drop bool
+	// Replace the tag with this regex replacement template, if set
+	Rename string
+	// Move the tag to this type, if set
+	Move string
+}
+
+type rule struct {
+	match     *regexp.Regexp
+	matchType bool
+	typ       common.TagType
+	drop      bool
+	rename    string
+	move      bool
+	moveTo    common.TagType
+}
+
+// Load fetched tag rules from the configuration file, if any
+func LoadRules() (err error) {
+	var conf []ruleConf
+	err = files.ReadConfig("tag_rules.json", &conf)
+	if err != nil {
+		return
+	}
+
+	rules = make([]rule, 0, len(conf))
+	for i, c := range conf {
+		r := rule{
+			drop:   c.Drop,
+			rename: c.Rename,
+		}
+		r.match, err = regexp.Compile(c.Match)
+		if err != nil {
+			return fmt.Errorf("tag_rules.json: rule %d: %s", i, err)
+		}
+		if c.Type != "" {
+			r.matchType = true
+			r.typ, err = parseRuleType(i, c.Type)
+			if err != nil {
+				return
+			}
+		}
+		if c.Move != "" {
+			r.move = true
+			r.moveTo, err = parseRuleType(i, c.Move)
+			if err != nil {
+				return
+			}
+		}
+		rules = append(rules, r)
+	}
+	return
+}
+
+func parseRuleType(i int, s string) (typ common.TagType, err error) {
+	typ, ok := parseTagType(s)
+	if !ok {
+		err = fmt.Errorf("tag_rules.json: rule %d: invalid tag type: %s", i, s)
+	}
+	return
+}
+
+// Return, if any fetched tag rules are configured
+func HasRules() bool {
+	return len(rules) != 0
+}
+
+// Apply the configured rules to fetched tags and return the resulting tags.
+// Tags renamed to an empty string and duplicates are dropped.
+func ApplyRules(src []common.Tag) []common.Tag {
+	if len(rules) == 0 {
+		return src
+	}
+
+	out := make([]common.Tag, 0, len(src))
+	seen := make(map[common.TagBase]bool, len(src))
+tags:
+	for _, t := range src {
+		for _, r := range rules {
+			if r.matchType && t.Type != r.typ {
+				continue
+			}
+			if !r.match.MatchString(t.Tag) {
+				continue
+			}
+			if r.drop {
+				continue tags
+			}
+			if r.rename != "" {
+				t.Tag = NormalizeString(
+					r.match.ReplaceAllString(t.Tag, r.rename))
+				if t.Tag == "" {
+					continue tags
+				}
+			}
+			if r.move {
+				t.Type = r.moveTo
+			}
+		}
+		if !seen[t.TagBase] {
+			seen[t.TagBase] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
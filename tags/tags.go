@@ -2,8 +2,12 @@ package tags
 
 import (
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/bakape/hydron/common"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
 )
 
 // Convert any externally-input tags to the internal format
@@ -13,7 +17,7 @@ func Normalize(s string, source common.TagSource) common.Tag {
 		Source: source,
 		TagBase: common.TagBase{
 			Type: detectTagType(&s),
-			Tag:  normalizeString(s),
+			Tag:  NormalizeString(s),
 		},
 	}
 }
@@ -21,20 +25,9 @@ func Normalize(s string, source common.TagSource) common.Tag {
 func detectTagType(s *string) (typ common.TagType) {
 	i := strings.IndexByte(*s, ':')
 	if i != -1 {
-		switch (*s)[:i] {
-		case "undefined":
-			typ = common.Undefined
-		case "artist", "author":
-			typ = common.Author
-		case "series", "copyright":
-			typ = common.Series
-		case "character":
-			typ = common.Character
-		case "rating":
-			typ = common.Rating
-		case "meta":
-			typ = common.Meta
-		default:
+		var ok bool
+		typ, ok = parseTagType((*s)[:i])
+		if !ok {
 			return
 		}
 		*s = (*s)[i+1:]
@@ -42,25 +35,77 @@ func detectTagType(s *string) (typ common.TagType) {
 	return
 }
 
-func normalizeString(s string) string {
-	buf := []byte(s)
-	for i, b := range buf {
-		switch b {
+// Parse a tag type prefix without the trailing colon
+func parseTagType(s string) (typ common.TagType, ok bool) {
+	ok = true
+	switch s {
+	case "undefined":
+		typ = common.Undefined
+	case "artist", "author":
+		typ = common.Author
+	case "series", "copyright":
+		typ = common.Series
+	case "character":
+		typ = common.Character
+	case "rating":
+		typ = common.Rating
+	case "meta":
+		typ = common.Meta
+	default:
+		ok = false
+	}
+	return
+}
+
+// Normalize a tag string without a type prefix to the internal format.
+// Performs Unicode NFKC normalization and case folding and strips control and
+// zero-width characters.
+func NormalizeString(s string) string {
+	// Strip control and formatting characters like zero-width spaces and
+	// joiners first, as they can separate characters, that are composed by
+	// Unicode normalization
+	s = strings.Map(func(r rune) rune {
+		if isStripped(r) {
+			return -1
+		}
+		return r
+	}, s)
+
+	ascii := true
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			ascii = false
+			break
+		}
+	}
+	if !ascii {
+		// Folding can denormalize the string, so normalize again
+		s = norm.NFKC.String(cases.Fold().String(norm.NFKC.String(s)))
+	}
+
+	return strings.Map(func(r rune) rune {
+		switch {
 		// Replace spaces and NULL with underscores
-		case ' ', '\x00':
-			buf[i] = '_'
+		case r == '\x00' || unicode.IsSpace(r):
+			return '_'
 		// Simplifies JSON encoding. Tags should not contain quotation marks
 		// either way.
-		case '"':
-			buf[i] = '\''
+		case r == '"':
+			return '\''
+		// Lowercase letters
+		case 'A' <= r && r <= 'Z':
+			return r + 'a' - 'A'
 		default:
-			// Lowercase letters
-			if 'A' <= b && b <= 'Z' {
-				buf[i] += 'a' - 'A'
-			}
+			return r
 		}
-	}
-	return string(buf)
+	}, s)
+}
+
+// Returns, if r is a control or formatting character stripped from tags.
+// NULL and whitespace are replaced instead.
+func isStripped(r rune) bool {
+	return r != '\x00' && !unicode.IsSpace(r) &&
+		(unicode.Is(unicode.Cc, r) || unicode.Is(unicode.Cf, r))
 }
 
 // Split a sep-delimited list of tags and normalize each
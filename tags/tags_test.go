@@ -0,0 +1,50 @@
+package tags
+
+import (
+	"testing"
+	"unicode"
+)
+
+func TestNormalizeString(t *testing.T) {
+	cases := [...]struct {
+		in, std string
+	}{
+		{"Long Hair", "long_hair"},
+		{`"quoted"`, "'quoted'"},
+		{"a\x00b", "a_b"},
+		{"\uff26\uff35\uff2c\uff2c width", "full_width"},
+		{"Stra\u00dfe", "strasse"},
+		{"zero\u200bwidth", "zerowidth"},
+		{"e\u200d\u0301", "\u00e9"},
+		{"e\x07\u0301", "\u00e9"},
+	}
+	for _, c := range cases {
+		if s := NormalizeString(c.in); s != c.std {
+			t.Errorf("%q: expected %q, got %q", c.in, c.std, s)
+		}
+	}
+}
+
+func TestNormalizeStringIdempotent(t *testing.T) {
+	inputs := []string{
+		"e\u200d\u0301",
+		"A\u200b\u030a",
+		"\u1100\u2060\u1161",
+		"\u01c5\u00ad\u0308",
+		"\ufb03\ufeff\u0301",
+		"K\u200e\u0327",
+	}
+	// Format characters between each letter of the Latin-1 Supplement and
+	// combining marks
+	for r := rune(0xC0); r <= 0xFF; r++ {
+		if unicode.IsLetter(r) {
+			inputs = append(inputs, string(r)+"\u200d\u0301\u0323")
+		}
+	}
+	for _, in := range inputs {
+		once := NormalizeString(in)
+		if twice := NormalizeString(once); twice != once {
+			t.Errorf("%q: not idempotent: %q != %q", in, once, twice)
+		}
+	}
+}
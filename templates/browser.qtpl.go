@@ -8,276 +8,307 @@ package templates
 import "github.com/bakape/hydron/common"
 
 //line browser.qtpl:2
+import "github.com/bakape/hydron/files"
+
+//line browser.qtpl:3
 import "strconv"
 
-//line browser.qtpl:4
+//line browser.qtpl:5
 import (
 	qtio422016 "io"
 
 	qt422016 "github.com/valyala/quicktemplate"
 )
 
-//line browser.qtpl:4
+//line browser.qtpl:5
 var (
 	_ = qtio422016.Copy
 	_ = qt422016.AcquireByteBuffer
 )
 
-//line browser.qtpl:4
-func StreamBrowser(qw422016 *qt422016.Writer, page common.Page, imgs []common.CompactImage) {
 //line browser.qtpl:5
+func StreamBrowser(qw422016 *qt422016.Writer, page common.Page, imgs []common.CompactImage, correction *common.Page) {
+//line browser.qtpl:6
 	filters := page.Filters.String()
 
-//line browser.qtpl:6
+//line browser.qtpl:7
 	title := filters
 
-//line browser.qtpl:7
-	if title == "" {
 //line browser.qtpl:8
+	if title == "" {
+//line browser.qtpl:9
 		title = "hydron"
 
-//line browser.qtpl:9
-	}
 //line browser.qtpl:10
+	}
+//line browser.qtpl:11
 	streamhead(qw422016, title)
-//line browser.qtpl:10
+//line browser.qtpl:11
 	qw422016.N().S(`<body><nav id="top-banner"><div style="display: flex;"><form method="get"><input type="search" id="search" placeholder="Search" value="`)
-//line browser.qtpl:15
+//line browser.qtpl:16
 	qw422016.E().S(filters)
-//line browser.qtpl:15
+//line browser.qtpl:16
 	qw422016.N().S(`" name="q" autocomplete="off" list="search-suggestions"><script>var el = document.getElementById("search");el.selectionStart = el.selectionEnd = el.value.length;</script><datalist id="search-suggestions"></datalist><select name="order" tabindex="-1" title="Order by">`)
-//line browser.qtpl:22
-	for i := common.None; i <= common.Random; i++ {
-//line browser.qtpl:22
-		qw422016.N().S(`<option value="`)
 //line browser.qtpl:23
-		qw422016.N().D(int(i))
+	for i := common.None; i <= common.ByTaken; i++ {
 //line browser.qtpl:23
+		qw422016.N().S(`<option value="`)
+//line browser.qtpl:24
+		qw422016.N().D(int(i))
+//line browser.qtpl:24
 		qw422016.N().S(`"`)
-//line browser.qtpl:23
+//line browser.qtpl:24
 		if i == page.Order.Type {
-//line browser.qtpl:23
+//line browser.qtpl:24
 			qw422016.N().S(` `)
-//line browser.qtpl:23
+//line browser.qtpl:24
 			qw422016.N().S(`selected`)
-//line browser.qtpl:23
+//line browser.qtpl:24
 		}
-//line browser.qtpl:23
-		qw422016.N().S(`>`)
 //line browser.qtpl:24
+		qw422016.N().S(`>`)
+//line browser.qtpl:25
 		qw422016.N().S(orderLabels[int(i)])
-//line browser.qtpl:24
+//line browser.qtpl:25
 		qw422016.N().S(`</option>`)
-//line browser.qtpl:26
+//line browser.qtpl:27
 	}
-//line browser.qtpl:26
+//line browser.qtpl:27
 	qw422016.N().S(`</select><input type="checkbox" name="reverse" tabindex="-1" title="Reverse order"`)
-//line browser.qtpl:28
+//line browser.qtpl:29
 	if page.Order.Reverse {
-//line browser.qtpl:28
+//line browser.qtpl:29
 		qw422016.N().S(` `)
-//line browser.qtpl:28
+//line browser.qtpl:29
 		qw422016.N().S(`checked`)
-//line browser.qtpl:28
+//line browser.qtpl:29
 	}
-//line browser.qtpl:28
+//line browser.qtpl:29
 	qw422016.N().S(`></form><div id="options"><label style="padding-bottom: 1em;">Options</label><div id="opts-bar"><input type="text" id="opts-input" title="Text input for options" autocomplete="off"><br><select id="opts-select">`)
-//line browser.qtpl:36
+//line browser.qtpl:37
 	for i := common.FetchTags; i <= common.Delete; i++ {
-//line browser.qtpl:36
-		qw422016.N().S(`<option value="`)
 //line browser.qtpl:37
+		qw422016.N().S(`<option value="`)
+//line browser.qtpl:38
 		qw422016.N().D(int(i))
-//line browser.qtpl:37
-		qw422016.N().S(`">`)
 //line browser.qtpl:38
+		qw422016.N().S(`">`)
+//line browser.qtpl:39
 		qw422016.N().S(optionLabels[int(i)])
-//line browser.qtpl:38
+//line browser.qtpl:39
 		qw422016.N().S(`</option>`)
-//line browser.qtpl:40
+//line browser.qtpl:41
 	}
-//line browser.qtpl:40
-	qw422016.N().S(`</select><br><input type="button" id="opts-submit" value="Submit"><br><hr><a href="/import">Upload files</a><br><a href="help">Help</a></div></div>`)
-//line browser.qtpl:51
+//line browser.qtpl:41
+	qw422016.N().S(`</select><br><input type="button" id="opts-submit" value="Submit"><br><hr><a href="/import">Upload files</a><br><a href="help">Help</a></div></div><input type="range" id="zoom" min="100" max="`)
+//line browser.qtpl:52
+	qw422016.N().D(int(files.GetThumbConfig().Largest()))
+//line browser.qtpl:52
+	qw422016.N().S(`" step="10" value="`)
+//line browser.qtpl:52
+	qw422016.N().D(files.DefaultThumbSize)
+//line browser.qtpl:52
+	qw422016.N().S(`" tabindex="-1" title="Thumbnail size">`)
+//line browser.qtpl:53
 	streampagination(qw422016, page)
-//line browser.qtpl:51
-	qw422016.N().S(`</div><div style="width: 100%; height: 0.3em;"><div id="progress-bar"></div></div></nav><section id="browser" tabindex="1">`)
-//line browser.qtpl:58
-	for i, img := range imgs {
+//line browser.qtpl:53
+	qw422016.N().S(`</div><div style="width: 100%; height: 0.3em;"><div id="progress-bar"></div></div></nav>`)
+//line browser.qtpl:59
+	if correction != nil {
 //line browser.qtpl:59
+		qw422016.N().S(`<div id="did-you-mean">Did you mean`)
+//line browser.qtpl:61
+		qw422016.N().S(` `)
+//line browser.qtpl:61
+		qw422016.N().S(`<a href="`)
+//line browser.qtpl:62
+		qw422016.N().S(correction.URL())
+//line browser.qtpl:62
+		qw422016.N().S(`">`)
+//line browser.qtpl:63
+		qw422016.E().S(correction.Filters.String())
+//line browser.qtpl:63
+		qw422016.N().S(`</a>?</div>`)
+//line browser.qtpl:67
+	}
+//line browser.qtpl:67
+	qw422016.N().S(`<section id="browser" tabindex="1">`)
+//line browser.qtpl:69
+	for i, img := range imgs {
+//line browser.qtpl:70
 		StreamThumbnail(qw422016, img, page, i == 0)
-//line browser.qtpl:60
+//line browser.qtpl:71
 	}
-//line browser.qtpl:60
+//line browser.qtpl:71
 	qw422016.N().S(`</section><script src="/assets/main.js" async></script></body>`)
-//line browser.qtpl:64
+//line browser.qtpl:75
 }
 
-//line browser.qtpl:64
-func WriteBrowser(qq422016 qtio422016.Writer, page common.Page, imgs []common.CompactImage) {
-//line browser.qtpl:64
+//line browser.qtpl:75
+func WriteBrowser(qq422016 qtio422016.Writer, page common.Page, imgs []common.CompactImage, correction *common.Page) {
+//line browser.qtpl:75
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line browser.qtpl:64
-	StreamBrowser(qw422016, page, imgs)
-//line browser.qtpl:64
+//line browser.qtpl:75
+	StreamBrowser(qw422016, page, imgs, correction)
+//line browser.qtpl:75
 	qt422016.ReleaseWriter(qw422016)
-//line browser.qtpl:64
+//line browser.qtpl:75
 }
 
-//line browser.qtpl:64
-func Browser(page common.Page, imgs []common.CompactImage) string {
-//line browser.qtpl:64
+//line browser.qtpl:75
+func Browser(page common.Page, imgs []common.CompactImage, correction *common.Page) string {
+//line browser.qtpl:75
 	qb422016 := qt422016.AcquireByteBuffer()
-//line browser.qtpl:64
-	WriteBrowser(qb422016, page, imgs)
-//line browser.qtpl:64
+//line browser.qtpl:75
+	WriteBrowser(qb422016, page, imgs, correction)
+//line browser.qtpl:75
 	qs422016 := string(qb422016.B)
-//line browser.qtpl:64
+//line browser.qtpl:75
 	qt422016.ReleaseByteBuffer(qb422016)
-//line browser.qtpl:64
+//line browser.qtpl:75
 	return qs422016
-//line browser.qtpl:64
+//line browser.qtpl:75
 }
 
 // Links to different pages on a search page
 
-//line browser.qtpl:67
+//line browser.qtpl:78
 func streampagination(qw422016 *qt422016.Writer, page common.Page) {
-//line browser.qtpl:67
+//line browser.qtpl:78
 	qw422016.N().S(`<span id="page-links" class="spaced">`)
-//line browser.qtpl:69
+//line browser.qtpl:80
 	current := int(page.Page)
 
-//line browser.qtpl:70
+//line browser.qtpl:81
 	total := int(page.PageTotal)
 
-//line browser.qtpl:71
+//line browser.qtpl:82
 	if current != 0 {
-//line browser.qtpl:72
+//line browser.qtpl:83
 		if current-1 != 0 {
-//line browser.qtpl:73
+//line browser.qtpl:84
 			streampageLink(qw422016, page, 0, "<<")
-//line browser.qtpl:74
+//line browser.qtpl:85
 		}
-//line browser.qtpl:75
+//line browser.qtpl:86
 		streampageLink(qw422016, page, current-1, "<")
-//line browser.qtpl:76
+//line browser.qtpl:87
 	}
-//line browser.qtpl:77
+//line browser.qtpl:88
 	count := 0
 
-//line browser.qtpl:78
+//line browser.qtpl:89
 	for i := current - 5; i < total && count < 10; i++ {
-//line browser.qtpl:79
+//line browser.qtpl:90
 		if i < 0 {
-//line browser.qtpl:80
+//line browser.qtpl:91
 			continue
-//line browser.qtpl:81
+//line browser.qtpl:92
 		}
-//line browser.qtpl:82
+//line browser.qtpl:93
 		count++
 
-//line browser.qtpl:83
+//line browser.qtpl:94
 		if i != current {
-//line browser.qtpl:84
+//line browser.qtpl:95
 			streampageLink(qw422016, page, i, strconv.Itoa(i+1))
-//line browser.qtpl:85
+//line browser.qtpl:96
 		} else {
-//line browser.qtpl:85
+//line browser.qtpl:96
 			qw422016.N().S(`<b>`)
-//line browser.qtpl:86
+//line browser.qtpl:97
 			qw422016.N().D(i + 1)
-//line browser.qtpl:86
+//line browser.qtpl:97
 			qw422016.N().S(`</b>`)
-//line browser.qtpl:87
+//line browser.qtpl:98
 		}
-//line browser.qtpl:88
+//line browser.qtpl:99
 	}
-//line browser.qtpl:89
+//line browser.qtpl:100
 	if current != total-1 {
-//line browser.qtpl:90
+//line browser.qtpl:101
 		streampageLink(qw422016, page, current+1, ">")
-//line browser.qtpl:91
+//line browser.qtpl:102
 		if current+1 != total-1 {
-//line browser.qtpl:92
+//line browser.qtpl:103
 			streampageLink(qw422016, page, total-1, ">>")
-//line browser.qtpl:93
+//line browser.qtpl:104
 		}
-//line browser.qtpl:94
+//line browser.qtpl:105
 	}
-//line browser.qtpl:94
+//line browser.qtpl:105
 	qw422016.N().S(`</span>`)
-//line browser.qtpl:96
+//line browser.qtpl:107
 }
 
-//line browser.qtpl:96
+//line browser.qtpl:107
 func writepagination(qq422016 qtio422016.Writer, page common.Page) {
-//line browser.qtpl:96
+//line browser.qtpl:107
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line browser.qtpl:96
+//line browser.qtpl:107
 	streampagination(qw422016, page)
-//line browser.qtpl:96
+//line browser.qtpl:107
 	qt422016.ReleaseWriter(qw422016)
-//line browser.qtpl:96
+//line browser.qtpl:107
 }
 
-//line browser.qtpl:96
+//line browser.qtpl:107
 func pagination(page common.Page) string {
-//line browser.qtpl:96
+//line browser.qtpl:107
 	qb422016 := qt422016.AcquireByteBuffer()
-//line browser.qtpl:96
+//line browser.qtpl:107
 	writepagination(qb422016, page)
-//line browser.qtpl:96
+//line browser.qtpl:107
 	qs422016 := string(qb422016.B)
-//line browser.qtpl:96
+//line browser.qtpl:107
 	qt422016.ReleaseByteBuffer(qb422016)
-//line browser.qtpl:96
+//line browser.qtpl:107
 	return qs422016
-//line browser.qtpl:96
+//line browser.qtpl:107
 }
 
 // Link to a different paginated search page
 
-//line browser.qtpl:99
+//line browser.qtpl:110
 func streampageLink(qw422016 *qt422016.Writer, page common.Page, i int, text string) {
-//line browser.qtpl:100
+//line browser.qtpl:111
 	page.Page = uint(i)
 
-//line browser.qtpl:100
+//line browser.qtpl:111
 	qw422016.N().S(`<a href="`)
-//line browser.qtpl:101
+//line browser.qtpl:112
 	qw422016.N().S(page.URL())
-//line browser.qtpl:101
+//line browser.qtpl:112
 	qw422016.N().S(`" tabindex="2">`)
-//line browser.qtpl:102
+//line browser.qtpl:113
 	qw422016.N().S(text)
-//line browser.qtpl:102
+//line browser.qtpl:113
 	qw422016.N().S(`</a>`)
-//line browser.qtpl:104
+//line browser.qtpl:115
 }
 
-//line browser.qtpl:104
+//line browser.qtpl:115
 func writepageLink(qq422016 qtio422016.Writer, page common.Page, i int, text string) {
-//line browser.qtpl:104
+//line browser.qtpl:115
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line browser.qtpl:104
+//line browser.qtpl:115
 	streampageLink(qw422016, page, i, text)
-//line browser.qtpl:104
+//line browser.qtpl:115
 	qt422016.ReleaseWriter(qw422016)
-//line browser.qtpl:104
+//line browser.qtpl:115
 }
 
-//line browser.qtpl:104
+//line browser.qtpl:115
 func pageLink(page common.Page, i int, text string) string {
-//line browser.qtpl:104
+//line browser.qtpl:115
 	qb422016 := qt422016.AcquireByteBuffer()
-//line browser.qtpl:104
+//line browser.qtpl:115
 	writepageLink(qb422016, page, i, text)
-//line browser.qtpl:104
+//line browser.qtpl:115
 	qs422016 := string(qb422016.B)
-//line browser.qtpl:104
+//line browser.qtpl:115
 	qt422016.ReleaseByteBuffer(qb422016)
-//line browser.qtpl:104
+//line browser.qtpl:115
 	return qs422016
-//line browser.qtpl:104
+//line browser.qtpl:115
 }
@@ -29,48 +29,52 @@ func StreamHelpPage(qw422016 *qt422016.Writer) {
 //line help.qtpl:10
 	qw422016.N().S(` `)
 //line help.qtpl:10
-	qw422016.N().S(`Files imported this way will fetch tags from Danbooru.</article></div><hr><div><b>Search</b><article>Tags can include an order:$x parameter where $x is one of:<br>size, width, height, duration, tag_count, random.<br>Prefixing - before $x will reverse the order.<br>Using an order: tag will override the order selected in the dropdown box.</article><article>Tags can be prefixed with - to match a subset that does not include that tag.</article><article>Tags can include prefixed system tags for searching by file metadata:<br>size, width, height, duration, tag_count,<br>followed by one of these comparison operators:<br>>, <, =, >=, <=<br>and a positive integer.<br>e.g. system:width>1920 or system:tag_count=0<br>There is also the type system tag to search by file type.<br>e.g. system:type=gif</article><article>Files can be filtered by the following ratings:<br>safe, questionable, explicit.<br>e.g. rating:safe</article><article>The number of results per page can be controlled with the limit tag. The default amount is`)
-//line help.qtpl:54
+	qw422016.N().S(`Files imported this way will fetch tags from Danbooru.</article></div><hr><div><b>Search</b><article>Tags can include an order:$x parameter where $x is one of:<br>size, width, height, duration, tag_count, random, taken.<br>Prefixing - before $x will reverse the order.<br>Using an order: tag will override the order selected in the dropdown box.</article><article>Tags can be prefixed with - to match a subset that does not include that tag.</article><article>Tags can include prefixed system tags for searching by file metadata:<br>size, width, height, duration, tag_count,<br>followed by one of these comparison operators:<br>>, <, =, >=, <=<br>and a positive integer.<br>e.g. system:width>1920 or system:tag_count=0<br>There is also the type system tag to search by file type.<br>e.g. system:type=gif<br>The taken system tag compares the date a photo was taken to a YYYY-MM-DD date.<br>e.g. system:taken<2020-01-01</article><article>Tags can include exif:$field or exif:$field=$value to match metadata embedded in files, where $field is one of`)
+//line help.qtpl:52
 	qw422016.N().S(` `)
-//line help.qtpl:54
+//line help.qtpl:52
+	qw422016.N().S(`make, model, orientation or keyword and $value can contain * wildcards and _ in place of spaces.<br>e.g. exif:model=canon_eos_5d or exif:keyword=*beach*</article><article>Files can be filtered by the following ratings:<br>safe, questionable, explicit.<br>e.g. rating:safe</article><article>The number of results per page can be controlled with the limit tag. The default amount is`)
+//line help.qtpl:65
+	qw422016.N().S(` `)
+//line help.qtpl:65
 	qw422016.N().D(common.PageSize)
-//line help.qtpl:54
+//line help.qtpl:65
 	qw422016.N().S(`.<br>It takes an integer between 1 and`)
-//line help.qtpl:56
+//line help.qtpl:67
 	qw422016.N().S(` `)
-//line help.qtpl:56
+//line help.qtpl:67
 	qw422016.N().D(common.PageSize)
-//line help.qtpl:56
+//line help.qtpl:67
 	qw422016.N().S(`.<br>e.g. limit:50</article><article>Tags can be prefixed to match a specific tag category like artist (artist:$tag or author:$tag), series (series:$tag or copyright:$tag),`)
-//line help.qtpl:62
+//line help.qtpl:73
 	qw422016.N().S(` `)
-//line help.qtpl:62
+//line help.qtpl:73
 	qw422016.N().S(`character (character:$tag), and meta (meta:$tag), where $tag is the suffixing tag.<br>Example meta tags are meta:highres and meta:animated.</article></div><hr><div><b>Keyboard Shortcuts</b><article>The search page can be navigated via keyboard Shortcuts.</article><article>Ctrl+l brings focus to the search bar.<br>Ctrl+b removes focus from the search bar.</article><article>Ctrl+a toggles the value of all checkboxes.<br>Space toggles the highlighted result's checkbox.</article><article>The arrow keys can be used to move the highlight selection.<br>Home moves the highlight selection to the first result in the page, and End moves it to the last result in the page.<br>PgUp and PgDn navigate to the next and previous search results pages respectively.</article><article>Enter navigates to the highlighted result's image page.</article></div></body>`)
-//line help.qtpl:96
+//line help.qtpl:107
 }
 
-//line help.qtpl:96
+//line help.qtpl:107
 func WriteHelpPage(qq422016 qtio422016.Writer) {
-//line help.qtpl:96
+//line help.qtpl:107
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line help.qtpl:96
+//line help.qtpl:107
 	StreamHelpPage(qw422016)
-//line help.qtpl:96
+//line help.qtpl:107
 	qt422016.ReleaseWriter(qw422016)
-//line help.qtpl:96
+//line help.qtpl:107
 }
 
-//line help.qtpl:96
+//line help.qtpl:107
 func HelpPage() string {
-//line help.qtpl:96
+//line help.qtpl:107
 	qb422016 := qt422016.AcquireByteBuffer()
-//line help.qtpl:96
+//line help.qtpl:107
 	WriteHelpPage(qb422016)
-//line help.qtpl:96
+//line help.qtpl:107
 	qs422016 := string(qb422016.B)
-//line help.qtpl:96
+//line help.qtpl:107
 	qt422016.ReleaseByteBuffer(qb422016)
-//line help.qtpl:96
+//line help.qtpl:107
 	return qs422016
-//line help.qtpl:96
+//line help.qtpl:107
 }
@@ -7,299 +7,627 @@ package templates
 //line image.qtpl:1
 import "net/url"
 
+//line image.qtpl:2
+import "strings"
+
 //line image.qtpl:3
+import "time"
+
+//line image.qtpl:5
 import "github.com/bakape/hydron/common"
 
-//line image.qtpl:4
+//line image.qtpl:6
 import "github.com/bakape/hydron/files"
 
-//line image.qtpl:6
+//line image.qtpl:7
+import "github.com/bakape/hydron/util"
+
+//line image.qtpl:9
 import (
 	qtio422016 "io"
 
 	qt422016 "github.com/valyala/quicktemplate"
 )
 
-//line image.qtpl:6
+//line image.qtpl:9
 var (
 	_ = qtio422016.Copy
 	_ = qt422016.AcquireByteBuffer
 )
 
-//line image.qtpl:6
+//line image.qtpl:9
 func StreamThumbnail(qw422016 *qt422016.Writer, img common.CompactImage, page common.Page, highlight bool) {
-//line image.qtpl:6
+//line image.qtpl:9
 	qw422016.N().S(`<figure data-href="`)
-//line image.qtpl:7
+//line image.qtpl:10
 	qw422016.N().S(files.NetSourcePath(img.SHA1, img.Type))
-//line image.qtpl:7
+//line image.qtpl:10
 	qw422016.N().S(`"`)
-//line image.qtpl:7
+//line image.qtpl:10
 	if highlight {
-//line image.qtpl:7
+//line image.qtpl:10
 		qw422016.N().S(` `)
-//line image.qtpl:7
+//line image.qtpl:10
 		qw422016.N().S(`class="highlight"`)
-//line image.qtpl:7
+//line image.qtpl:10
 	}
-//line image.qtpl:7
+//line image.qtpl:10
 	qw422016.N().S(`><input type="checkbox" name="img:`)
-//line image.qtpl:8
+//line image.qtpl:11
 	qw422016.N().S(img.SHA1)
-//line image.qtpl:8
+//line image.qtpl:11
 	qw422016.N().S(`"><div class="background"></div><a href="/image/`)
-//line image.qtpl:10
+//line image.qtpl:13
 	qw422016.N().S(img.SHA1)
-//line image.qtpl:10
+//line image.qtpl:13
 	qw422016.N().S(`?`)
-//line image.qtpl:10
+//line image.qtpl:13
 	qw422016.N().S(page.Query())
-//line image.qtpl:10
+//line image.qtpl:13
 	qw422016.N().S(`"><img width="`)
-//line image.qtpl:11
+//line image.qtpl:14
 	qw422016.N().D(int(img.Thumb.Width))
-//line image.qtpl:11
+//line image.qtpl:14
 	qw422016.N().S(`" height="`)
-//line image.qtpl:11
+//line image.qtpl:14
 	qw422016.N().D(int(img.Thumb.Height))
-//line image.qtpl:11
+//line image.qtpl:14
+	qw422016.N().S(`" sizes="`)
+//line image.qtpl:14
+	qw422016.N().D(int(thumbDisplayWidth(img)))
+//line image.qtpl:14
+	qw422016.N().S(`px" srcset="`)
+//line image.qtpl:14
+	qw422016.N().S(thumbSrcset(img))
+//line image.qtpl:14
 	qw422016.N().S(`" src="`)
-//line image.qtpl:11
-	qw422016.N().S(files.NetThumbPath(img.SHA1))
-//line image.qtpl:11
-	qw422016.N().S(`"></a></figure>`)
 //line image.qtpl:14
+	qw422016.N().S(files.NetThumbPath(img.SHA1, files.GetThumbConfig().Default()))
+//line image.qtpl:14
+	qw422016.N().S(`"`)
+//line image.qtpl:14
+	if img.Preview {
+//line image.qtpl:14
+		qw422016.N().S(` `)
+//line image.qtpl:14
+		qw422016.N().S(`data-preview="`)
+//line image.qtpl:14
+		qw422016.N().S(files.NetPreviewPath(img.SHA1))
+//line image.qtpl:14
+		qw422016.N().S(`"`)
+//line image.qtpl:14
+	}
+//line image.qtpl:14
+	qw422016.N().S(`></a>`)
+//line image.qtpl:16
+	if isAnimated(img.Type) {
+//line image.qtpl:16
+		qw422016.N().S(`<span class="media-badge">`)
+//line image.qtpl:17
+		qw422016.N().S(strings.ToUpper(common.Extensions[img.Type]))
+//line image.qtpl:17
+		qw422016.N().S(`</span>`)
+//line image.qtpl:18
+	}
+//line image.qtpl:18
+	qw422016.N().S(`</figure>`)
+//line image.qtpl:20
 }
 
-//line image.qtpl:14
+//line image.qtpl:20
 func WriteThumbnail(qq422016 qtio422016.Writer, img common.CompactImage, page common.Page, highlight bool) {
-//line image.qtpl:14
+//line image.qtpl:20
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line image.qtpl:14
+//line image.qtpl:20
 	StreamThumbnail(qw422016, img, page, highlight)
-//line image.qtpl:14
+//line image.qtpl:20
 	qt422016.ReleaseWriter(qw422016)
-//line image.qtpl:14
+//line image.qtpl:20
 }
 
-//line image.qtpl:14
+//line image.qtpl:20
 func Thumbnail(img common.CompactImage, page common.Page, highlight bool) string {
-//line image.qtpl:14
+//line image.qtpl:20
 	qb422016 := qt422016.AcquireByteBuffer()
-//line image.qtpl:14
+//line image.qtpl:20
 	WriteThumbnail(qb422016, img, page, highlight)
-//line image.qtpl:14
+//line image.qtpl:20
 	qs422016 := string(qb422016.B)
-//line image.qtpl:14
+//line image.qtpl:20
 	qt422016.ReleaseByteBuffer(qb422016)
-//line image.qtpl:14
+//line image.qtpl:20
 	return qs422016
-//line image.qtpl:14
+//line image.qtpl:20
 }
 
-//line image.qtpl:16
-func StreamImagePage(qw422016 *qt422016.Writer, img common.Image, page common.Page) {
-//line image.qtpl:17
+//line image.qtpl:22
+func StreamImagePage(qw422016 *qt422016.Writer, img common.Image, history []common.TagChange, page common.Page) {
+//line image.qtpl:23
 	title := img.Name
 
-//line image.qtpl:18
+//line image.qtpl:24
 	if title == "" {
-//line image.qtpl:19
+//line image.qtpl:25
 		title = "hydron"
 
-//line image.qtpl:20
+//line image.qtpl:26
 	}
-//line image.qtpl:21
+//line image.qtpl:27
 	streamhead(qw422016, title)
-//line image.qtpl:21
+//line image.qtpl:27
 	qw422016.N().S(`<body><div id="image-view"><section id="tags">`)
-//line image.qtpl:25
+//line image.qtpl:31
 	if img.Name != "" {
-//line image.qtpl:25
+//line image.qtpl:31
 		qw422016.N().S(`<span class="image-name"><a href="/search?q=`)
-//line image.qtpl:27
+//line image.qtpl:33
 		qw422016.E().S(url.QueryEscape("name:") + img.Name)
-//line image.qtpl:27
+//line image.qtpl:33
 		qw422016.N().S(`" title="Search for name">Name:`)
-//line image.qtpl:28
+//line image.qtpl:34
 		qw422016.N().S(` `)
-//line image.qtpl:28
+//line image.qtpl:34
 		qw422016.E().S(img.Name)
-//line image.qtpl:28
+//line image.qtpl:34
 		qw422016.N().S(`</a></span>`)
-//line image.qtpl:31
+//line image.qtpl:37
 	}
-//line image.qtpl:32
+//line image.qtpl:38
 	org := organizeTags(img.Tags)
 
-//line image.qtpl:33
+//line image.qtpl:39
 	streamrenderTags(qw422016, org[common.Character], page)
-//line image.qtpl:34
+//line image.qtpl:40
 	streamrenderTags(qw422016, org[common.Series], page)
-//line image.qtpl:35
+//line image.qtpl:41
 	streamrenderTags(qw422016, org[common.Author], page)
-//line image.qtpl:36
+//line image.qtpl:42
 	streamrenderTags(qw422016, org[common.Rating], page)
-//line image.qtpl:37
+//line image.qtpl:43
 	streamrenderTags(qw422016, org[common.Meta], page)
-//line image.qtpl:38
+//line image.qtpl:44
 	streamrenderTags(qw422016, org[common.Undefined], page)
-//line image.qtpl:38
+//line image.qtpl:45
+	streamrenderSources(qw422016, img)
+//line image.qtpl:46
+	if img.Taken != 0 || len(img.Metadata) != 0 {
+//line image.qtpl:47
+		streamrenderMetadata(qw422016, img)
+//line image.qtpl:48
+	}
+//line image.qtpl:49
+	if len(history) != 0 {
+//line image.qtpl:50
+		streamrenderHistory(qw422016, history)
+//line image.qtpl:51
+	}
+//line image.qtpl:51
 	qw422016.N().S(`</section><div id="media-container">`)
-//line image.qtpl:41
+//line image.qtpl:54
 	src := files.NetSourcePath(img.SHA1, img.Type)
 
-//line image.qtpl:42
+//line image.qtpl:55
 	switch common.GetMediaType(img.Type) {
-//line image.qtpl:43
+//line image.qtpl:56
 	case common.MediaImage:
-//line image.qtpl:43
-		qw422016.N().S(`<img src="`)
-//line image.qtpl:44
-		qw422016.N().S(src)
-//line image.qtpl:44
-		qw422016.N().S(`">`)
-//line image.qtpl:45
+//line image.qtpl:57
+		if isBrowserImage(img.Type) {
+//line image.qtpl:57
+			qw422016.N().S(`<img src="`)
+//line image.qtpl:58
+			qw422016.N().S(src)
+//line image.qtpl:58
+			qw422016.N().S(`">`)
+//line image.qtpl:59
+		} else {
+//line image.qtpl:59
+			qw422016.N().S(`<a href="`)
+//line image.qtpl:60
+			qw422016.N().S(src)
+//line image.qtpl:60
+			qw422016.N().S(`" title="Download"><img src="`)
+//line image.qtpl:61
+			qw422016.N().S(files.NetThumbPath(img.SHA1, files.GetThumbConfig().Largest()))
+//line image.qtpl:61
+			qw422016.N().S(`"></a>`)
+//line image.qtpl:63
+		}
+//line image.qtpl:64
 	case common.MediaVideo:
-//line image.qtpl:45
+//line image.qtpl:64
 		qw422016.N().S(`<video src="`)
-//line image.qtpl:46
+//line image.qtpl:65
 		qw422016.N().S(src)
-//line image.qtpl:46
+//line image.qtpl:65
 		qw422016.N().S(`" autoplay loop controls>`)
-//line image.qtpl:47
+//line image.qtpl:66
 	default:
-//line image.qtpl:47
+//line image.qtpl:66
 		qw422016.N().S(`<b>Display not supported for this file format</b>`)
-//line image.qtpl:49
+//line image.qtpl:68
 	}
-//line image.qtpl:49
+//line image.qtpl:68
 	qw422016.N().S(`</div></div></body>`)
-//line image.qtpl:53
+//line image.qtpl:72
 }
 
-//line image.qtpl:53
-func WriteImagePage(qq422016 qtio422016.Writer, img common.Image, page common.Page) {
-//line image.qtpl:53
+//line image.qtpl:72
+func WriteImagePage(qq422016 qtio422016.Writer, img common.Image, history []common.TagChange, page common.Page) {
+//line image.qtpl:72
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line image.qtpl:53
-	StreamImagePage(qw422016, img, page)
-//line image.qtpl:53
+//line image.qtpl:72
+	StreamImagePage(qw422016, img, history, page)
+//line image.qtpl:72
 	qt422016.ReleaseWriter(qw422016)
-//line image.qtpl:53
+//line image.qtpl:72
 }
 
-//line image.qtpl:53
-func ImagePage(img common.Image, page common.Page) string {
-//line image.qtpl:53
+//line image.qtpl:72
+func ImagePage(img common.Image, history []common.TagChange, page common.Page) string {
+//line image.qtpl:72
 	qb422016 := qt422016.AcquireByteBuffer()
-//line image.qtpl:53
-	WriteImagePage(qb422016, img, page)
-//line image.qtpl:53
+//line image.qtpl:72
+	WriteImagePage(qb422016, img, history, page)
+//line image.qtpl:72
 	qs422016 := string(qb422016.B)
-//line image.qtpl:53
+//line image.qtpl:72
 	qt422016.ReleaseByteBuffer(qb422016)
-//line image.qtpl:53
+//line image.qtpl:72
 	return qs422016
-//line image.qtpl:53
+//line image.qtpl:72
 }
 
 // Render tag adition and direct tag query links
 
-//line image.qtpl:56
+//line image.qtpl:75
 func streamrenderTags(qw422016 *qt422016.Writer, tags []common.Tag, page common.Page) {
-//line image.qtpl:57
+//line image.qtpl:76
 	page.Page = 0
 
-//line image.qtpl:58
+//line image.qtpl:77
 	init := page.Filters
 
-//line image.qtpl:59
+//line image.qtpl:78
 	for _, t := range tags {
-//line image.qtpl:60
+//line image.qtpl:79
 		page.Filters = init
 
-//line image.qtpl:61
+//line image.qtpl:80
 		filter := common.TagFilter{TagBase: t.TagBase}
 
-//line image.qtpl:62
+//line image.qtpl:81
 		page.Filters.Tag = append(page.Filters.Tag, filter)
 
-//line image.qtpl:62
+//line image.qtpl:81
 		qw422016.N().S(`<span class="spaced tag-`)
-//line image.qtpl:63
+//line image.qtpl:82
 		qw422016.N().Z(common.BufferWriter(t.Type))
-//line image.qtpl:63
+//line image.qtpl:82
 		qw422016.N().S(`"><a href="`)
-//line image.qtpl:64
+//line image.qtpl:83
 		qw422016.N().S(page.URL())
-//line image.qtpl:64
+//line image.qtpl:83
 		qw422016.N().S(`" class="char-button" title="Add to search">+</a>`)
-//line image.qtpl:67
+//line image.qtpl:86
 		page.Filters.Tag[len(page.Filters.Tag)-1].Negative = true
 
-//line image.qtpl:67
+//line image.qtpl:86
 		qw422016.N().S(`<a href="`)
-//line image.qtpl:68
+//line image.qtpl:87
 		qw422016.N().S(page.URL())
-//line image.qtpl:68
+//line image.qtpl:87
 		qw422016.N().S(`" class="char-button" title="Remove from search">-</a>`)
-//line image.qtpl:71
+//line image.qtpl:90
 		page.Filters = common.FilterSet{
 			Tag: []common.TagFilter{filter},
 		}
 
-//line image.qtpl:73
+//line image.qtpl:92
 		qw422016.N().S(`<a href="`)
-//line image.qtpl:74
+//line image.qtpl:93
 		qw422016.N().S(page.URL())
-//line image.qtpl:74
+//line image.qtpl:93
 		qw422016.N().S(`" title="Search for`)
-//line image.qtpl:74
+//line image.qtpl:93
 		qw422016.N().S(` `)
-//line image.qtpl:74
+//line image.qtpl:93
 		qw422016.E().S(t.Tag)
-//line image.qtpl:74
+//line image.qtpl:93
 		qw422016.N().S(`">`)
-//line image.qtpl:75
+//line image.qtpl:94
 		if t.Type == common.Rating {
-//line image.qtpl:75
+//line image.qtpl:94
 			qw422016.N().S(`rating:`)
-//line image.qtpl:76
+//line image.qtpl:95
 			qw422016.N().S(` `)
-//line image.qtpl:77
+//line image.qtpl:96
 		}
-//line image.qtpl:78
+//line image.qtpl:97
 		qw422016.E().S(t.Tag)
-//line image.qtpl:78
+//line image.qtpl:97
 		qw422016.N().S(`</a></span>`)
-//line image.qtpl:81
+//line image.qtpl:100
 	}
-//line image.qtpl:82
+//line image.qtpl:101
 }
 
-//line image.qtpl:82
+//line image.qtpl:101
 func writerenderTags(qq422016 qtio422016.Writer, tags []common.Tag, page common.Page) {
-//line image.qtpl:82
+//line image.qtpl:101
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line image.qtpl:82
+//line image.qtpl:101
 	streamrenderTags(qw422016, tags, page)
-//line image.qtpl:82
+//line image.qtpl:101
 	qt422016.ReleaseWriter(qw422016)
-//line image.qtpl:82
+//line image.qtpl:101
 }
 
-//line image.qtpl:82
+//line image.qtpl:101
 func renderTags(tags []common.Tag, page common.Page) string {
-//line image.qtpl:82
+//line image.qtpl:101
 	qb422016 := qt422016.AcquireByteBuffer()
-//line image.qtpl:82
+//line image.qtpl:101
 	writerenderTags(qb422016, tags, page)
-//line image.qtpl:82
+//line image.qtpl:101
 	qs422016 := string(qb422016.B)
-//line image.qtpl:82
+//line image.qtpl:101
 	qt422016.ReleaseByteBuffer(qb422016)
-//line image.qtpl:82
+//line image.qtpl:101
 	return qs422016
-//line image.qtpl:82
+//line image.qtpl:101
+}
+
+// Render source URLs of an image and a form for adding more
+
+//line image.qtpl:104
+func streamrenderSources(qw422016 *qt422016.Writer, img common.Image) {
+//line image.qtpl:104
+	qw422016.N().S(`<div class="sources">`)
+//line image.qtpl:106
+	for _, s := range img.Sources {
+//line image.qtpl:106
+		qw422016.N().S(`<div>`)
+//line image.qtpl:108
+		if util.IsFetchable(s.URL) {
+//line image.qtpl:108
+			qw422016.N().S(`<a href="`)
+//line image.qtpl:109
+			qw422016.E().S(s.URL)
+//line image.qtpl:109
+			qw422016.N().S(`" rel="noreferrer" target="_blank">`)
+//line image.qtpl:109
+			qw422016.E().S(s.URL)
+//line image.qtpl:109
+			qw422016.N().S(`</a>`)
+//line image.qtpl:110
+		} else {
+//line image.qtpl:111
+			qw422016.E().S(s.URL)
+//line image.qtpl:112
+		}
+//line image.qtpl:113
+		qw422016.N().S(` `)
+//line image.qtpl:113
+		qw422016.N().S(`(`)
+//line image.qtpl:113
+		qw422016.E().S(s.Kind.String())
+//line image.qtpl:113
+		qw422016.N().S(`)</div>`)
+//line image.qtpl:115
+	}
+//line image.qtpl:115
+	qw422016.N().S(`<form method="post" action="/api/images/`)
+//line image.qtpl:116
+	qw422016.N().S(img.SHA1)
+//line image.qtpl:116
+	qw422016.N().S(`/sources"><input type="hidden" name="redirect" value="true"><input type="text" name="url" placeholder="Add source URL..." autocomplete="off"></form></div>`)
+//line image.qtpl:121
+}
+
+//line image.qtpl:121
+func writerenderSources(qq422016 qtio422016.Writer, img common.Image) {
+//line image.qtpl:121
+	qw422016 := qt422016.AcquireWriter(qq422016)
+//line image.qtpl:121
+	streamrenderSources(qw422016, img)
+//line image.qtpl:121
+	qt422016.ReleaseWriter(qw422016)
+//line image.qtpl:121
+}
+
+//line image.qtpl:121
+func renderSources(img common.Image) string {
+//line image.qtpl:121
+	qb422016 := qt422016.AcquireByteBuffer()
+//line image.qtpl:121
+	writerenderSources(qb422016, img)
+//line image.qtpl:121
+	qs422016 := string(qb422016.B)
+//line image.qtpl:121
+	qt422016.ReleaseByteBuffer(qb422016)
+//line image.qtpl:121
+	return qs422016
+//line image.qtpl:121
+}
+
+// Render embedded file metadata with links to search for each field
+
+//line image.qtpl:124
+func streamrenderMetadata(qw422016 *qt422016.Writer, img common.Image) {
+//line image.qtpl:124
+	qw422016.N().S(`<div class="metadata">`)
+//line image.qtpl:126
+	if img.Taken != 0 {
+//line image.qtpl:127
+		date := time.Unix(img.Taken, 0).UTC()
+
+//line image.qtpl:127
+		qw422016.N().S(`<div>taken:`)
+//line image.qtpl:129
+		qw422016.N().S(` `)
+//line image.qtpl:129
+		qw422016.N().S(`<a href="/search?q=`)
+//line image.qtpl:130
+		qw422016.E().S(url.QueryEscape("system:taken=" + date.Format(common.DateFormat)))
+//line image.qtpl:130
+		qw422016.N().S(`" title="Search for date taken">`)
+//line image.qtpl:131
+		qw422016.E().S(date.Format("2006-01-02 15:04:05"))
+//line image.qtpl:131
+		qw422016.N().S(`</a></div>`)
+//line image.qtpl:134
+	}
+//line image.qtpl:135
+	for _, f := range img.Metadata {
+//line image.qtpl:136
+		q := "exif:" + f.Key + "=" + strings.Replace(f.Value, " ", "_", -1)
+
+//line image.qtpl:136
+		qw422016.N().S(`<div>`)
+//line image.qtpl:138
+		qw422016.E().S(f.Key)
+//line image.qtpl:138
+		qw422016.N().S(`:`)
+//line image.qtpl:138
+		qw422016.N().S(` `)
+//line image.qtpl:138
+		qw422016.N().S(`<a href="/search?q=`)
+//line image.qtpl:139
+		qw422016.E().S(url.QueryEscape(q))
+//line image.qtpl:139
+		qw422016.N().S(`" title="Search for`)
+//line image.qtpl:139
+		qw422016.N().S(` `)
+//line image.qtpl:139
+		qw422016.E().S(f.Key)
+//line image.qtpl:139
+		qw422016.N().S(`">`)
+//line image.qtpl:140
+		qw422016.E().S(f.Value)
+//line image.qtpl:140
+		qw422016.N().S(`</a></div>`)
+//line image.qtpl:143
+	}
+//line image.qtpl:143
+	qw422016.N().S(`</div>`)
+//line image.qtpl:145
+}
+
+//line image.qtpl:145
+func writerenderMetadata(qq422016 qtio422016.Writer, img common.Image) {
+//line image.qtpl:145
+	qw422016 := qt422016.AcquireWriter(qq422016)
+//line image.qtpl:145
+	streamrenderMetadata(qw422016, img)
+//line image.qtpl:145
+	qt422016.ReleaseWriter(qw422016)
+//line image.qtpl:145
+}
+
+//line image.qtpl:145
+func renderMetadata(img common.Image) string {
+//line image.qtpl:145
+	qb422016 := qt422016.AcquireByteBuffer()
+//line image.qtpl:145
+	writerenderMetadata(qb422016, img)
+//line image.qtpl:145
+	qs422016 := string(qb422016.B)
+//line image.qtpl:145
+	qt422016.ReleaseByteBuffer(qb422016)
+//line image.qtpl:145
+	return qs422016
+//line image.qtpl:145
+}
+
+// Render the tag edit history of an image
+
+//line image.qtpl:148
+func streamrenderHistory(qw422016 *qt422016.Writer, history []common.TagChange) {
+//line image.qtpl:148
+	qw422016.N().S(`<details class="tag-history"><summary>History</summary>`)
+//line image.qtpl:151
+	for _, c := range history {
+//line image.qtpl:151
+		qw422016.N().S(`<div`)
+//line image.qtpl:152
+		if c.Reverted {
+//line image.qtpl:152
+			qw422016.N().S(` `)
+//line image.qtpl:152
+			qw422016.N().S(`class="reverted" title="Reverted"`)
+//line image.qtpl:152
+		}
+//line image.qtpl:152
+		qw422016.N().S(`>`)
+//line image.qtpl:153
+		qw422016.E().S(time.Unix(c.Time, 0).Format("2006-01-02 15:04:05"))
+//line image.qtpl:154
+		qw422016.N().S(` `)
+//line image.qtpl:155
+		if c.Added {
+//line image.qtpl:155
+			qw422016.N().S(`+`)
+//line image.qtpl:157
+		} else {
+//line image.qtpl:157
+			qw422016.N().S(`-`)
+//line image.qtpl:159
+		}
+//line image.qtpl:160
+		qw422016.E().Z(common.BufferWriter(c.TagBase))
+//line image.qtpl:161
+		qw422016.N().S(` `)
+//line image.qtpl:161
+		qw422016.N().S(`(`)
+//line image.qtpl:162
+		qw422016.E().S(c.Source.String())
+//line image.qtpl:162
+		qw422016.N().S(`,`)
+//line image.qtpl:162
+		qw422016.N().S(` `)
+//line image.qtpl:162
+		qw422016.E().S(c.Actor)
+//line image.qtpl:162
+		qw422016.N().S(`,`)
+//line image.qtpl:162
+		qw422016.N().S(` `)
+//line image.qtpl:162
+		qw422016.N().S(`batch`)
+//line image.qtpl:162
+		qw422016.N().S(` `)
+//line image.qtpl:162
+		qw422016.N().DL(c.Batch)
+//line image.qtpl:162
+		qw422016.N().S(`)</div>`)
+//line image.qtpl:164
+	}
+//line image.qtpl:164
+	qw422016.N().S(`</details>`)
+//line image.qtpl:166
+}
+
+//line image.qtpl:166
+func writerenderHistory(qq422016 qtio422016.Writer, history []common.TagChange) {
+//line image.qtpl:166
+	qw422016 := qt422016.AcquireWriter(qq422016)
+//line image.qtpl:166
+	streamrenderHistory(qw422016, history)
+//line image.qtpl:166
+	qt422016.ReleaseWriter(qw422016)
+//line image.qtpl:166
+}
+
+//line image.qtpl:166
+func renderHistory(history []common.TagChange) string {
+//line image.qtpl:166
+	qb422016 := qt422016.AcquireByteBuffer()
+//line image.qtpl:166
+	writerenderHistory(qb422016, history)
+//line image.qtpl:166
+	qs422016 := string(qb422016.B)
+//line image.qtpl:166
+	qt422016.ReleaseByteBuffer(qb422016)
+//line image.qtpl:166
+	return qs422016
+//line image.qtpl:166
 }
@@ -22,32 +22,32 @@ func StreamImportPage(qw422016 *qt422016.Writer) {
 //line import.qtpl:2
 	streamhead(qw422016, "Import")
 //line import.qtpl:2
-	qw422016.N().S(`<body class="fit-page"><div id="import"><label>Import from filepaths. Only one path per line.</label><textarea id="path" placeholder="Import paths..." autocomplete="off"></textarea><label>Add tags to imported files.</label><input type="text" id="input-tags" placeholder="Add tags..." autocomplete="off"><label>Delete imported files: <input type="checkbox" id="delete"></label><label>Fetch tags for imported files: <input type="checkbox" id="fetch-tags"></label><label>Store filename of imported files: <input type="checkbox" id="store-name"></label><input type="button" id="submit" value="Submit"><div style="width: 100%; height: 0.3em;"><div id="progress-bar"></div></div></div><section id="browser" tabindex="1" style="overflow-y: scroll; padding: 8px;"></section><script src="/assets/import.js" async></script></body>`)
-//line import.qtpl:20
+	qw422016.N().S(`<body class="fit-page"><div id="import"><label>Import from filepaths or URLs. Only one per line.</label><textarea id="path" placeholder="Import paths or URLs..." autocomplete="off"></textarea><label>Add tags to imported files.</label><input type="text" id="input-tags" placeholder="Add tags..." autocomplete="off"><label>Delete imported files: <input type="checkbox" id="delete"></label><label>Fetch tags for imported files: <input type="checkbox" id="fetch-tags"></label><label>Store filename of imported files: <input type="checkbox" id="store-name"></label><label>Tag and order files from archives: <input type="checkbox" id="archive-tags"></label><label>Link files instead of copying, if on the same file system: <input type="checkbox" id="link"></label><label>Only report what would be imported: <input type="checkbox" id="dry-run"></label><input type="button" id="submit" value="Submit"><div id="dry-run-summary"></div><table id="dry-run-report"></table><label>Path rules. Tag files by their path as a JSON list of <code>{"match": REGEX, "tags": TAGS}</code> or <code>{"glob": GLOB, "tags": TAGS}</code> rules.</label><textarea id="path-rules" placeholder="Path rules..." autocomplete="off" spellcheck="false"></textarea><div><input type="button" id="save-rules" value="Save rules"><input type="button" id="preview-rules" value="Preview tags"></div><table id="rules-preview"></table><div style="width: 100%; height: 0.3em;"><div id="progress-bar"></div></div></div><section id="browser" tabindex="1" style="overflow-y: scroll; padding: 8px;"></section><script src="/assets/import.js" async></script></body>`)
+//line import.qtpl:32
 }
 
-//line import.qtpl:20
+//line import.qtpl:32
 func WriteImportPage(qq422016 qtio422016.Writer) {
-//line import.qtpl:20
+//line import.qtpl:32
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line import.qtpl:20
+//line import.qtpl:32
 	StreamImportPage(qw422016)
-//line import.qtpl:20
+//line import.qtpl:32
 	qt422016.ReleaseWriter(qw422016)
-//line import.qtpl:20
+//line import.qtpl:32
 }
 
-//line import.qtpl:20
+//line import.qtpl:32
 func ImportPage() string {
-//line import.qtpl:20
+//line import.qtpl:32
 	qb422016 := qt422016.AcquireByteBuffer()
-//line import.qtpl:20
+//line import.qtpl:32
 	WriteImportPage(qb422016)
-//line import.qtpl:20
+//line import.qtpl:32
 	qs422016 := string(qb422016.B)
-//line import.qtpl:20
+//line import.qtpl:32
 	qt422016.ReleaseByteBuffer(qb422016)
-//line import.qtpl:20
+//line import.qtpl:32
 	return qs422016
-//line import.qtpl:20
+//line import.qtpl:32
 }
@@ -3,14 +3,17 @@
 package templates
 
 import (
+	"fmt"
 	"sort"
+	"strings"
 
 	"github.com/bakape/hydron/common"
+	"github.com/bakape/hydron/files"
 )
 
 // Human-readable labels for image ordering types
 var orderLabels = [...]string{"None", "Size", "Width", "Height", "Duration",
-	"Tag count", "Random"}
+	"Tag count", "Random", "Date taken"}
 
 // Human-readable labels for option types
 var optionLabels = [...]string{"Fetch tags", "Add tags", "Remove tags",
@@ -61,3 +64,46 @@ func organizeTags(src []common.Tag) map[common.TagType][]common.Tag {
 	}
 	return out
 }
+
+// Returns the srcset attribute of an image's thumbnails in all configured sizes
+func thumbSrcset(img common.CompactImage) string {
+	var (
+		b    strings.Builder
+		last uint64
+	)
+	for _, size := range files.GetThumbConfig().Sizes {
+		// Images smaller than a size have identical thumbnails for it
+		w := files.ScaleThumbDims(img.Thumb, size).Width
+		if w == last {
+			continue
+		}
+		last = w
+		if b.Len() != 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%s %dw", files.NetThumbPath(img.SHA1, size), w)
+	}
+	return b.String()
+}
+
+// Returns the width an image's thumbnail is displayed at on the search page
+// before zooming
+func thumbDisplayWidth(img common.CompactImage) uint64 {
+	return files.ScaleThumbDims(img.Thumb, files.DefaultThumbSize).Width
+}
+
+// Returns, if files of type are animated and labeled on the search page
+func isAnimated(typ common.FileType) bool {
+	return typ == common.GIF || common.GetMediaType(typ) == common.MediaVideo
+}
+
+// Returns, if browsers can display images of type. Other images are displayed
+// as their largest thumbnail on the image page.
+func isBrowserImage(typ common.FileType) bool {
+	switch typ {
+	case common.HEIC, common.JXL:
+		return false
+	default:
+		return true
+	}
+}
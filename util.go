@@ -3,7 +3,9 @@ package main
 import (
 	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"path"
 	"strings"
 
 	"github.com/bakape/hydron/common"
@@ -48,8 +50,9 @@ func (p *progressLogger) Close() {
 
 // Helper type for multi-value channel
 type Result struct {
-	img common.Image
-	err error
+	path string
+	img  common.Image
+	err  error
 }
 
 // Convert import page's input string into string array of file paths
@@ -57,6 +60,7 @@ func parsePaths(input string) []string {
 	var store []string
 	tmp := strings.Split(input, "\n")
 	for _, s := range tmp {
+		s = strings.TrimSpace(s)
 		if s != "" {
 			store = append(store, s)
 		}
@@ -64,3 +68,17 @@ func parsePaths(input string) []string {
 
 	return store
 }
+
+// Extract the file name without extension from the path of a URL
+func urlFileName(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	name := path.Base(parsed.Path)
+	switch name {
+	case ".", "/":
+		return ""
+	}
+	return strings.TrimSuffix(name, path.Ext(name))
+}
@@ -27,3 +27,18 @@ func Waterfall(fns ...func() error) (err error) {
 	}
 	return
 }
+
+// Returns, if s is a hex-encoded SHA1 hash
+func IsSHA1(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9', r >= 'a' && r <= 'f':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bakape/hydron/files"
+	imp "github.com/bakape/hydron/import"
+	"github.com/bakape/hydron/sidecar"
+	"github.com/fsnotify/fsnotify"
+)
+
+// Time a file must not be written to, before it is imported
+const watchDebounce = 2 * time.Second
+
+// Extensions of incomplete downloads, that are never imported
+var partialDownloadExts = map[string]bool{
+	".part":       true,
+	".crdownload": true,
+	".download":   true,
+	".partial":    true,
+	".tmp":        true,
+}
+
+// Folder to watch for new files with import options
+type watchFolder struct {
+	Path      string `json:"path"`
+	Tags      string `json:"tags"`
+	Delete    bool   `json:"delete"`
+	StoreName bool   `json:"store_name"`
+	FetchTags bool   `json:"fetch_tags"`
+	Link      bool   `json:"link"`
+}
+
+// Imports new files appearing in watched folders
+type folderWatcher struct {
+	*fsnotify.Watcher
+
+	mu sync.Mutex
+	// Options of the watched folders by path of each watched directory
+	folders map[string]*watchFolder
+	// Pending imports of files still being written to
+	pending map[string]*time.Timer
+	// Files ready to be imported
+	ready chan watchImport
+}
+
+// File ready to be imported with the options of its watched folder
+type watchImport struct {
+	path   string
+	folder *watchFolder
+}
+
+// Read the folders to watch from the configuration file, if any
+func loadWatchConfig() (folders []watchFolder, err error) {
+	err = files.ReadConfig("watch.json", &folders)
+	return
+}
+
+// Watch folders and import new files. Watches the folders from watch.json, if
+// no paths set. Never returns, unless an error occurs.
+func watchFolders(paths []string, opts watchFolder) (err error) {
+	var folders []watchFolder
+	if len(paths) == 0 {
+		folders, err = loadWatchConfig()
+		if err != nil {
+			return
+		}
+		if len(folders) == 0 {
+			return fmt.Errorf("no folders to watch set in watch.json")
+		}
+	} else {
+		for _, p := range paths {
+			f := opts
+			f.Path = p
+			folders = append(folders, f)
+		}
+	}
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return
+	}
+	defer fsw.Close()
+	w := folderWatcher{
+		Watcher: fsw,
+		folders: make(map[string]*watchFolder),
+		pending: make(map[string]*time.Timer),
+		ready:   make(chan watchImport),
+	}
+
+	// Import files in parallel
+	n := runtime.NumCPU() + 1
+	for i := 0; i < n; i++ {
+		go w.importFiles()
+	}
+
+	for i := range folders {
+		f := &folders[i]
+		f.Path, err = filepath.Abs(f.Path)
+		if err != nil {
+			return
+		}
+		_, err = w.addTree(f.Path, f)
+		if err != nil {
+			return
+		}
+		stderr.Printf("watching %s\n", f.Path)
+	}
+
+	for {
+		select {
+		case e := <-w.Events:
+			w.handleEvent(e)
+		case err := <-w.Errors:
+			stderr.Printf("watch: %s\n", err)
+		}
+	}
+}
+
+// Recursively watch a directory and all its subdirectories.
+// Returns the paths of all files in the directory.
+func (w *folderWatcher) addTree(dir string, f *watchFolder) (
+	paths []string, err error,
+) {
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error,
+	) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			paths = append(paths, path)
+			return nil
+		}
+		err = w.Add(path)
+		if err != nil {
+			return err
+		}
+		w.mu.Lock()
+		w.folders[path] = f
+		w.mu.Unlock()
+		return nil
+	})
+	return
+}
+
+func (w *folderWatcher) handleEvent(e fsnotify.Event) {
+	switch {
+	case e.Op&(fsnotify.Create|fsnotify.Write) != 0:
+		info, err := os.Stat(e.Name)
+		if err != nil {
+			// Removed or renamed in the meantime
+			return
+		}
+		w.mu.Lock()
+		f := w.folders[filepath.Dir(e.Name)]
+		w.mu.Unlock()
+		if f == nil {
+			return
+		}
+
+		if !info.IsDir() {
+			w.schedule(e.Name, f)
+			return
+		}
+
+		// Directories moved into the folder can already contain files
+		paths, err := w.addTree(e.Name, f)
+		if err != nil {
+			stderr.Printf("watch: %s\n", err)
+		}
+		for _, p := range paths {
+			w.schedule(p, f)
+		}
+	case e.Op&(fsnotify.Remove|fsnotify.Rename) != 0:
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if t := w.pending[e.Name]; t != nil {
+			t.Stop()
+			delete(w.pending, e.Name)
+		}
+		// Removed watches are cleaned up by fsnotify itself
+		delete(w.folders, e.Name)
+	}
+}
+
+// Schedule a file for importing, once it has not been written to for
+// watchDebounce
+func (w *folderWatcher) schedule(path string, f *watchFolder) {
+	name := filepath.Base(path)
+	if strings.HasPrefix(name, ".") ||
+		partialDownloadExts[strings.ToLower(filepath.Ext(name))] ||
+		sidecar.IsSidecar(path) {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if t := w.pending[path]; t != nil {
+		t.Reset(watchDebounce)
+		return
+	}
+	var t *time.Timer
+	t = time.AfterFunc(watchDebounce, func() {
+		info, err := os.Stat(path)
+		w.mu.Lock()
+		if err == nil {
+			// Written to after the timer was last reset
+			if d := watchDebounce - time.Since(info.ModTime()); d > 0 {
+				t.Reset(d)
+				w.mu.Unlock()
+				return
+			}
+		}
+		delete(w.pending, path)
+		w.mu.Unlock()
+		if err != nil {
+			return
+		}
+
+		w.ready <- watchImport{path, f}
+	})
+	w.pending[path] = t
+}
+
+// Import files, that are ready to be imported
+func (w *folderWatcher) importFiles() {
+	for file := range w.ready {
+		f := file.folder
+		_, err := importPath(file.path, f.Delete, f.FetchTags, f.StoreName,
+			false, f.Link, f.Tags)
+		switch err {
+		case nil:
+			fmt.Printf("imported %s\n", file.path)
+		case imp.ErrImported:
+			fmt.Printf("already imported %s\n", file.path)
+		default:
+			stderr.Printf("watch: %s: %s\n", file.path, err)
+		}
+	}
+}